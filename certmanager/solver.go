@@ -0,0 +1,217 @@
+// Package certmanager implements a cert-manager DNS-01 webhook solver for LuaDNS.
+//
+// Solver follows the cert-manager webhook.Solver interface (Name, Present,
+// CleanUp and Initialize). ChallengeRequest mirrors the cert-manager
+// v1alpha1.ChallengeRequest JSON representation and secrets are read through
+// SecretGetter, so the package doesn't depend on Kubernetes libraries. The
+// cert-manager-webhook-luadns command wraps Solver in a webhook built with the
+// cert-manager webhook library, passing a SecretGetter backed by its
+// Kubernetes client to Initialize.
+//
+// Issuer configuration:
+//
+//	solvers:
+//	- dns01:
+//	    webhook:
+//	      groupName: acme.example.com
+//	      solverName: luadns
+//	      config:
+//	        email: joe@example.com
+//	        apiKeySecretRef:
+//	          name: luadns-credentials
+//	          key: api-key
+//	        zone: example.org # optional, overrides the resolved zone
+//	        ttl: 60            # optional
+package certmanager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	api "github.com/luadns/luadns-go"
+)
+
+// DefaultTTL is the TTL of challenge records.
+const DefaultTTL = 300
+
+// ChallengeRequest represents a DNS-01 challenge sent by cert-manager.
+type ChallengeRequest struct {
+	UID                     string          `json:"uid"`
+	Action                  string          `json:"action"`
+	Type                    string          `json:"type"`
+	DNSName                 string          `json:"dnsName"`
+	Key                     string          `json:"key"`
+	ResourceNamespace       string          `json:"resourceNamespace"`
+	ResolvedFQDN            string          `json:"resolvedFQDN,omitempty"`
+	ResolvedZone            string          `json:"resolvedZone,omitempty"`
+	AllowAmbientCredentials bool            `json:"allowAmbientCredentials"`
+	Config                  json.RawMessage `json:"config,omitempty"`
+}
+
+// SecretKeySelector references a key of a Kubernetes secret.
+type SecretKeySelector struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
+}
+
+// Config represents the per-issuer solver configuration.
+type Config struct {
+	Email           string            `json:"email"`
+	EmailSecretRef  SecretKeySelector `json:"emailSecretRef"` // alternative to Email
+	APIKeySecretRef SecretKeySelector `json:"apiKeySecretRef"`
+	Zone            string            `json:"zone,omitempty"` // overrides the resolved zone
+	TTL             uint32            `json:"ttl,omitempty"`
+}
+
+// SecretGetter reads secret data from a namespace.
+type SecretGetter interface {
+	GetSecret(ctx context.Context, namespace, name string) (map[string][]byte, error)
+}
+
+// Solver implements a cert-manager DNS-01 webhook solver.
+type Solver struct {
+	// NewClient builds API clients, it defaults to api.NewClient.
+	NewClient func(email, apiKey string) *api.Client
+
+	secrets SecretGetter
+}
+
+// Name returns the solver name used in issuer configurations.
+func (s *Solver) Name() string {
+	return "luadns"
+}
+
+// Initialize is called once when the webhook starts.
+func (s *Solver) Initialize(secrets SecretGetter, stopCh <-chan struct{}) error {
+	if secrets == nil {
+		return errors.New("luadns: secret getter is required")
+	}
+	s.secrets = secrets
+	return nil
+}
+
+// Present creates the challenge TXT record, it does nothing if the record
+// already exists.
+func (s *Solver) Present(ch *ChallengeRequest) error {
+	ctx := context.Background()
+
+	c, cfg, zone, err := s.setup(ctx, ch)
+	if err != nil {
+		return err
+	}
+
+	records, err := c.ListAllRecords(ctx, zone)
+	if err != nil {
+		return fmt.Errorf("luadns: %w", err)
+	}
+	for _, r := range records {
+		if r.Type == api.TypeTXT && strings.EqualFold(r.Name, api.Fqdn(ch.ResolvedFQDN)) && r.Content == ch.Key {
+			return nil
+		}
+	}
+
+	ttl := cfg.TTL
+	if ttl == 0 {
+		ttl = DefaultTTL
+	}
+	_, err = c.CreateRecord(ctx, zone, &api.Record{Name: api.Fqdn(ch.ResolvedFQDN), Type: api.TypeTXT, Content: ch.Key, TTL: ttl})
+	if err != nil {
+		return fmt.Errorf("luadns: failed to create record: %w", err)
+	}
+	return nil
+}
+
+// CleanUp deletes the challenge TXT record matching the challenge key,
+// records of other challenges on the same name are kept.
+func (s *Solver) CleanUp(ch *ChallengeRequest) error {
+	ctx := context.Background()
+
+	c, _, zone, err := s.setup(ctx, ch)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.DeleteManyRecords(ctx, zone, []*api.RR{{Name: api.Fqdn(ch.ResolvedFQDN), Type: api.TypeTXT, Content: ch.Key}})
+	if err != nil {
+		return fmt.Errorf("luadns: failed to delete record: %w", err)
+	}
+	return nil
+}
+
+// setup decodes the issuer config, reads credentials and finds the zone.
+func (s *Solver) setup(ctx context.Context, ch *ChallengeRequest) (*api.Client, *Config, *api.Zone, error) {
+	cfg, err := LoadConfig(ch.Config)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	email := cfg.Email
+	if email == "" {
+		if email, err = s.secret(ctx, ch.ResourceNamespace, cfg.EmailSecretRef); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	key, err := s.secret(ctx, ch.ResourceNamespace, cfg.APIKeySecretRef)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	newClient := s.NewClient
+	if newClient == nil {
+		newClient = func(email, apiKey string) *api.Client {
+			return api.NewClient(email, apiKey)
+		}
+	}
+	c := newClient(email, key)
+
+	name := cfg.Zone
+	if name == "" {
+		name = ch.ResolvedZone
+	}
+
+	zones, err := c.ListAllZones(ctx)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("luadns: %w", err)
+	}
+	for _, z := range zones {
+		if strings.EqualFold(api.Fqdn(z.Name), api.Fqdn(name)) {
+			return c, cfg, z, nil
+		}
+	}
+	return nil, nil, nil, fmt.Errorf("luadns: zone %s not found", name)
+}
+
+// secret returns the value of a secret key.
+func (s *Solver) secret(ctx context.Context, namespace string, ref SecretKeySelector) (string, error) {
+	if s.secrets == nil {
+		return "", errors.New("luadns: solver not initialized")
+	}
+	if ref.Name == "" || ref.Key == "" {
+		return "", errors.New("luadns: secret reference requires name and key")
+	}
+
+	data, err := s.secrets.GetSecret(ctx, namespace, ref.Name)
+	if err != nil {
+		return "", fmt.Errorf("luadns: failed to get secret %s/%s: %w", namespace, ref.Name, err)
+	}
+	value, ok := data[ref.Key]
+	if !ok {
+		return "", fmt.Errorf("luadns: key %s not found in secret %s/%s", ref.Key, namespace, ref.Name)
+	}
+	return strings.TrimSpace(string(value)), nil
+}
+
+// LoadConfig decodes the solver configuration of an issuer.
+func LoadConfig(data json.RawMessage) (*Config, error) {
+	cfg := &Config{}
+	if len(data) == 0 {
+		return cfg, nil
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("luadns: error decoding solver config: %w", err)
+	}
+	return cfg, nil
+}
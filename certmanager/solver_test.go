@@ -0,0 +1,97 @@
+package certmanager_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	api "github.com/luadns/luadns-go"
+	"github.com/luadns/luadns-go/certmanager"
+	"github.com/luadns/luadns-go/internal/fakeapi"
+	"github.com/stretchr/testify/assert"
+)
+
+type secrets map[string]map[string][]byte
+
+func (s secrets) GetSecret(ctx context.Context, namespace, name string) (map[string][]byte, error) {
+	data, ok := s[namespace+"/"+name]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return data, nil
+}
+
+func TestSolver(t *testing.T) {
+	server := fakeapi.New()
+	defer server.Close()
+
+	zone := server.AddZone("example.org")
+
+	s := &certmanager.Solver{NewClient: func(email, apiKey string) *api.Client {
+		assert.Equal(t, fakeapi.Email, email)
+		assert.Equal(t, fakeapi.APIKey, apiKey)
+		return server.Client()
+	}}
+	assert.Equal(t, "luadns", s.Name())
+	assert.NoError(t, s.Initialize(secrets{
+		"certs/luadns": {"email": []byte(fakeapi.Email + "\n"), "api-key": []byte(fakeapi.APIKey)},
+	}, nil))
+
+	config := []byte(`{"emailSecretRef":{"name":"luadns","key":"email"},"apiKeySecretRef":{"name":"luadns","key":"api-key"},"ttl":60}`)
+	ch := func(key string) *certmanager.ChallengeRequest {
+		return &certmanager.ChallengeRequest{
+			DNSName:           "*.example.org",
+			Key:               key,
+			ResourceNamespace: "certs",
+			ResolvedFQDN:      "_acme-challenge.example.org.",
+			ResolvedZone:      "example.org.",
+			Config:            config,
+		}
+	}
+
+	txt := func() []string {
+		list := []string{}
+		for _, r := range server.Records(zone.ID) {
+			if r.Type == api.TypeTXT {
+				list = append(list, r.String())
+			}
+		}
+		return list
+	}
+
+	assert.NoError(t, s.Present(ch("a")))
+	assert.NoError(t, s.Present(ch("a")))
+	assert.NoError(t, s.Present(ch("b")))
+	assert.Equal(t, []string{
+		"_acme-challenge.example.org. 60 IN TXT a",
+		"_acme-challenge.example.org. 60 IN TXT b",
+	}, txt())
+
+	assert.NoError(t, s.CleanUp(ch("a")))
+	assert.Equal(t, []string{"_acme-challenge.example.org. 60 IN TXT b"}, txt())
+}
+
+func TestSolverErrors(t *testing.T) {
+	server := fakeapi.New()
+	defer server.Close()
+
+	server.AddZone("example.org")
+	s := &certmanager.Solver{NewClient: func(email, apiKey string) *api.Client { return server.Client() }}
+	ch := &certmanager.ChallengeRequest{ResourceNamespace: "certs", ResolvedFQDN: "_acme-challenge.www.example.org.", ResolvedZone: "www.example.org."}
+
+	ch.Config = []byte(`{"email":"joe@example.com","apiKeySecretRef":{"name":"luadns","key":"api-key"}}`)
+	assert.EqualError(t, s.Present(ch), "luadns: solver not initialized")
+
+	assert.NoError(t, s.Initialize(secrets{"certs/luadns": {"api-key": []byte("x")}}, nil))
+	assert.EqualError(t, s.Present(ch), "luadns: zone www.example.org. not found")
+
+	// Zone override.
+	ch.Config = []byte(`{"email":"joe@example.com","apiKeySecretRef":{"name":"luadns","key":"api-key"},"zone":"example.org"}`)
+	assert.NoError(t, s.Present(ch))
+
+	ch.Config = []byte(`{"email":"joe@example.com","apiKeySecretRef":{"name":"luadns","key":"token"}}`)
+	assert.EqualError(t, s.Present(ch), "luadns: key token not found in secret certs/luadns")
+
+	ch.Config = []byte(`{"ttl":"x"}`)
+	assert.ErrorContains(t, s.Present(ch), "luadns: error decoding solver config")
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 )
 
 // OptFunc represents a configuration function which are used to configure the REST API client.
@@ -16,6 +17,14 @@ func SetBaseURL(url string) OptFunc {
 	}
 }
 
+// WrapTransport wraps the HTTP transport used for API requests, `wrap`
+// receives the authenticating transport (used to observe or limit requests).
+func WrapTransport(wrap func(http.RoundTripper) http.RoundTripper) OptFunc {
+	return func(c *Client) {
+		c.client.client.Transport = wrap(c.client.client.Transport)
+	}
+}
+
 // RestCallFunc represents a call to REST API.
 type RestCallFunc func(ctx context.Context) ([]byte, error)
 
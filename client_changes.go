@@ -0,0 +1,211 @@
+package luadns
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrChangeSkipped is reported for changes not applied because a previous API call failed.
+var ErrChangeSkipped = errors.New("change skipped after a previous error")
+
+// recordWriter represents the record operations used to apply changes.
+type recordWriter interface {
+	ListAllRecords(ctx context.Context, zone *Zone) ([]*Record, error)
+	CreateManyRecords(ctx context.Context, zone *Zone, recs []*RR) ([]*Record, error)
+	UpdateManyRecords(ctx context.Context, zone *Zone, recs []*RR) ([]*Record, error)
+	DeleteManyRecords(ctx context.Context, zone *Zone, recs []*RR) ([]*Record, error)
+	UpdateRecord(ctx context.Context, zone *Zone, recordID int64, attrs *Record) (*Record, error)
+}
+
+// ChangeResult represents the outcome of a single change.
+type ChangeResult struct {
+	Change  *Change
+	Records []*Record // records returned by the API for this change
+	Err     error
+}
+
+// ApplyChanges applies a change set to `zone` using as few API calls as possible:
+//
+//   - deletions are sent in a single DeleteManyRecords call (exact match),
+//   - (name, type) sets having updates are replaced in a single UpdateManyRecords
+//     call, along with their creations and deletions. The records kept in
+//     these sets are read from the zone, ChangeSet.Unchanged isn't required,
+//   - updates moving a record to another (name, type) set use UpdateRecord,
+//   - remaining creations are sent in a single CreateManyRecords call.
+//
+// A result is returned for every change. When a call fails its changes report
+// the error, changes not yet applied report ErrChangeSkipped and the first
+// error is returned.
+func (c *Client) ApplyChanges(ctx context.Context, zone *Zone, changes ChangeSet) ([]*ChangeResult, error) {
+	return applyChanges(ctx, c, zone, changes)
+}
+
+func applyChanges(ctx context.Context, c recordWriter, zone *Zone, changes ChangeSet) ([]*ChangeResult, error) {
+	results := make([]*ChangeResult, len(changes.Changes))
+	for i, ch := range changes.Changes {
+		results[i] = &ChangeResult{Change: ch}
+	}
+
+	// Find (name, type) sets replaced as a whole.
+	replaced := map[string]bool{}
+	for _, ch := range changes.Changes {
+		if ch.Action == ChangeUpdate && setKey(ch.Desired) == setKey(ch.Current) {
+			replaced[setKey(ch.Desired)] = true
+		}
+	}
+
+	var deletes, sets, moves, creates []*ChangeResult
+	for _, res := range results {
+		ch := res.Change
+		switch {
+		case ch.Action == ChangeDelete && replaced[setKey(ch.Current)]:
+			sets = append(sets, res)
+		case ch.Action == ChangeDelete:
+			deletes = append(deletes, res)
+		case ch.Action == ChangeCreate && replaced[setKey(ch.Desired)]:
+			sets = append(sets, res)
+		case ch.Action == ChangeCreate:
+			creates = append(creates, res)
+		case setKey(ch.Desired) == setKey(ch.Current):
+			sets = append(sets, res)
+		default:
+			moves = append(moves, res)
+		}
+	}
+
+	steps := []struct {
+		results []*ChangeResult
+		batch   bool // single call, all changes fail together
+		apply   func() error
+	}{
+		{deletes, true, func() error {
+			rrs := []*RR{}
+			for _, res := range deletes {
+				rrs = append(rrs, exactRR(res.Change.Current))
+			}
+			records, err := c.DeleteManyRecords(ctx, zone, rrs)
+			if err != nil {
+				return err
+			}
+			for _, res := range deletes {
+				res.Records = matchRecords(res.Change.Current, records)
+			}
+			return nil
+		}},
+		{sets, true, func() error {
+			live, err := c.ListAllRecords(ctx, zone)
+			if err != nil {
+				return err
+			}
+
+			// Keep live records of replaced sets not changed by the change set.
+			rrs := []*RR{}
+			for _, r := range live {
+				if !replaced[setKey(r)] {
+					continue
+				}
+				changed := false
+				for _, res := range sets {
+					if cur := res.Change.Current; cur != nil && sameRecord(cur, r) {
+						changed = true
+					}
+				}
+				if !changed {
+					rrs = append(rrs, exactRR(r))
+				}
+			}
+			for _, res := range sets {
+				if res.Change.Desired != nil {
+					rrs = append(rrs, exactRR(res.Change.Desired))
+				}
+			}
+			records, err := c.UpdateManyRecords(ctx, zone, rrs)
+			if err != nil {
+				return err
+			}
+			for _, res := range sets {
+				if res.Change.Desired != nil {
+					res.Records = matchRecords(res.Change.Desired, records)
+				}
+			}
+			return nil
+		}},
+		{moves, false, func() error {
+			for i, res := range moves {
+				record, err := c.UpdateRecord(ctx, zone, res.Change.Current.ID, res.Change.Desired)
+				if err != nil {
+					res.Err = err
+					for _, next := range moves[i+1:] {
+						next.Err = ErrChangeSkipped
+					}
+					return err
+				}
+				res.Records = []*Record{record}
+			}
+			return nil
+		}},
+		{creates, true, func() error {
+			rrs := []*RR{}
+			for _, res := range creates {
+				rrs = append(rrs, exactRR(res.Change.Desired))
+			}
+			records, err := c.CreateManyRecords(ctx, zone, rrs)
+			if err != nil {
+				return err
+			}
+			for _, res := range creates {
+				res.Records = matchRecords(res.Change.Desired, records)
+			}
+			return nil
+		}},
+	}
+
+	var failed error
+	for _, step := range steps {
+		if len(step.results) == 0 {
+			continue
+		}
+		if failed != nil {
+			for _, res := range step.results {
+				res.Err = ErrChangeSkipped
+			}
+			continue
+		}
+
+		if err := step.apply(); err != nil {
+			failed = err
+			if step.batch {
+				for _, res := range step.results {
+					res.Err = err
+				}
+			}
+		}
+	}
+
+	return results, failed
+}
+
+// exactRR converts a record to a RR matching exactly its name, type, content and TTL.
+func exactRR(r *Record) *RR {
+	return &RR{Name: Fqdn(r.Name), Type: r.Type, Content: r.Content, TTL: r.TTL}
+}
+
+// sameRecord reports whether `a` and `b` are the same record, by ID or by
+// name, type, content and TTL (IDs change when sets are replaced).
+func sameRecord(a, b *Record) bool {
+	if a.ID != 0 && a.ID == b.ID {
+		return true
+	}
+	return setKey(a) == setKey(b) && canonicalContent(a.Type, a.Content) == canonicalContent(b.Type, b.Content) && a.TTL == b.TTL
+}
+
+// matchRecords returns records returned by the API matching `r`.
+func matchRecords(r *Record, records []*Record) []*Record {
+	matched := []*Record{}
+	for _, rec := range records {
+		if setKey(rec) == setKey(r) && canonicalContent(rec.Type, rec.Content) == canonicalContent(r.Type, r.Content) {
+			matched = append(matched, rec)
+		}
+	}
+	return matched
+}
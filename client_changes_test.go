@@ -0,0 +1,191 @@
+package luadns_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/luadns/luadns-go"
+	"github.com/luadns/luadns-go/internal/fakeapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyChanges(t *testing.T) {
+	server := fakeapi.New()
+	defer server.Close()
+
+	zone := server.AddZone("example.org",
+		&luadns.Record{Name: "example.org.", Type: "A", Content: "1.1.1.1", TTL: 3600},
+		&luadns.Record{Name: "example.org.", Type: "A", Content: "2.2.2.2", TTL: 3600},
+		&luadns.Record{Name: "www.example.org.", Type: "CNAME", Content: "example.org.", TTL: 3600},
+		&luadns.Record{Name: "old.example.org.", Type: "A", Content: "3.3.3.3", TTL: 3600},
+		&luadns.Record{Name: "old.example.org.", Type: "TXT", Content: "old", TTL: 3600},
+	)
+	c := server.Client()
+	ctx := context.Background()
+
+	current, err := c.ListAllRecords(ctx, zone)
+	assert.NoError(t, err)
+
+	desired := []*luadns.Record{
+		{Name: "example.org.", Type: "A", Content: "1.1.1.1", TTL: 3600},
+		{Name: "example.org.", Type: "A", Content: "4.4.4.4", TTL: 3600},
+		{Name: "www.example.org.", Type: "CNAME", Content: "example.org.", TTL: 300},
+		{Name: "new.example.org.", Type: "A", Content: "5.5.5.5", TTL: 3600},
+		{Name: "new.example.org.", Type: "AAAA", Content: "2001:db8::1", TTL: 3600},
+	}
+	changes := luadns.Diff(desired, current, &luadns.DiffOptions{Zone: zone.Name})
+	assert.Len(t, changes.Changes, 6)
+
+	server.ResetRequests()
+	results, err := c.ApplyChanges(ctx, zone, changes)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"POST /zones/101/records/delete_many",
+		"GET /zones/101/records",
+		"PATCH /zones/101/records",
+		"POST /zones/101/records/create_many",
+	}, server.Requests())
+
+	assert.Len(t, results, 6)
+	for _, res := range results {
+		assert.NoError(t, res.Err)
+		assert.Len(t, res.Records, 1, res.Change.Action)
+	}
+
+	records, err := c.ListAllRecords(ctx, zone)
+	assert.NoError(t, err)
+	assert.True(t, luadns.Diff(desired, records, &luadns.DiffOptions{Zone: zone.Name}).Empty())
+}
+
+func TestApplyChangesEndpoint(t *testing.T) {
+	fixtures := map[string]string{
+		"POST /zones/5/records/delete_many": "/zones/5/records/delete_many",
+		"PUT /zones/5/records/115014348":    "/zones/5/records/115014348.update",
+		"POST /zones/5/records/create_many": "/zones/5/records/create_many",
+		"PATCH /zones/5/records":            "/zones/5/records.update_many",
+		"GET /zones/5/records":              "/zones/5/records.index",
+	}
+	requests := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		sendHTTPFixture(t, fixtures[r.Method+" "+r.URL.Path], w, r)
+	}))
+	defer server.Close()
+
+	c := luadns.NewClient("joe@example.com", "password", luadns.SetBaseURL(server.URL))
+	changes := luadns.ChangeSet{Changes: []*luadns.Change{
+		{Action: luadns.ChangeDelete, Current: &luadns.Record{ID: 185177166, Name: "foo.example.org.", Type: "TXT", Content: "bar", TTL: 3600}},
+		{
+			Action:  luadns.ChangeUpdate,
+			Desired: &luadns.Record{Name: "example.org.", Type: "A", Content: "2.2.2.2", TTL: 86400},
+			Current: &luadns.Record{ID: 115014348, Name: "www.example.org.", Type: "A", Content: "1.1.1.1", TTL: 86400},
+		},
+		{Action: luadns.ChangeCreate, Desired: &luadns.Record{Name: "foo.example.org.", Type: "TXT", Content: "foo", TTL: 3600}},
+	}}
+
+	results, err := c.ApplyChanges(context.Background(), &luadns.Zone{ID: 5, Name: "example.org"}, changes)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"POST /zones/5/records/delete_many",
+		"PUT /zones/5/records/115014348",
+		"POST /zones/5/records/create_many",
+	}, requests)
+	if assert.Len(t, results, 3) {
+		assert.Equal(t, int64(185177166), results[0].Records[0].ID)
+		assert.Equal(t, int64(115014348), results[1].Records[0].ID)
+		assert.Equal(t, int64(185177165), results[2].Records[0].ID)
+	}
+
+	// Updates within a (name, type) set replace the set.
+	requests = requests[:0]
+	changes = luadns.ChangeSet{Changes: []*luadns.Change{{
+		Action:  luadns.ChangeUpdate,
+		Desired: &luadns.Record{Name: "foo.example.org.", Type: "TXT", Content: "bar", TTL: 3600},
+		Current: &luadns.Record{ID: 185177100, Name: "foo.example.org.", Type: "TXT", Content: "foo", TTL: 3600},
+	}}}
+	results, err = c.ApplyChanges(context.Background(), &luadns.Zone{ID: 5, Name: "example.org"}, changes)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"GET /zones/5/records", "PATCH /zones/5/records"}, requests)
+	assert.Equal(t, int64(185177166), results[0].Records[0].ID)
+}
+
+func TestApplyChangesKeepsSetRecords(t *testing.T) {
+	server := fakeapi.New()
+	defer server.Close()
+
+	zone := server.AddZone("example.org",
+		&luadns.Record{Name: "example.org.", Type: "A", Content: "1.1.1.1", TTL: 3600},
+		&luadns.Record{Name: "example.org.", Type: "A", Content: "2.2.2.2", TTL: 3600},
+	)
+	current := server.Records(zone.ID)[3]
+
+	// Hand-built change sets don't list unchanged records.
+	changes := luadns.ChangeSet{Changes: []*luadns.Change{{
+		Action:  luadns.ChangeUpdate,
+		Desired: &luadns.Record{Name: "example.org.", Type: "A", Content: "3.3.3.3", TTL: 3600},
+		Current: current,
+	}}}
+	_, err := server.Client().ApplyChanges(context.Background(), zone, changes)
+	assert.NoError(t, err)
+
+	contents := []string{}
+	for _, r := range server.Records(zone.ID)[3:] {
+		contents = append(contents, r.Content)
+	}
+	assert.ElementsMatch(t, []string{"2.2.2.2", "3.3.3.3"}, contents)
+}
+
+func TestApplyChangesMovesRecords(t *testing.T) {
+	server := fakeapi.New()
+	defer server.Close()
+
+	zone := server.AddZone("example.org", &luadns.Record{Name: "www.example.org.", Type: "A", Content: "1.1.1.1", TTL: 3600})
+	current := server.Records(zone.ID)[3]
+
+	changes := luadns.ChangeSet{Changes: []*luadns.Change{{
+		Action:  luadns.ChangeUpdate,
+		Desired: &luadns.Record{Name: "web.example.org.", Type: "A", Content: "1.1.1.1", TTL: 3600},
+		Current: current,
+	}}}
+
+	server.ResetRequests()
+	results, err := server.Client().ApplyChanges(context.Background(), zone, changes)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"PUT /zones/101/records/105"}, server.Requests())
+	assert.Equal(t, current.ID, results[0].Records[0].ID)
+	assert.Equal(t, "web.example.org.", results[0].Records[0].Name)
+}
+
+func TestApplyChangesFailure(t *testing.T) {
+	server := fakeapi.New()
+	defer server.Close()
+
+	zone := server.AddZone("example.org", &luadns.Record{Name: "old.example.org.", Type: "A", Content: "1.1.1.1", TTL: 3600})
+	current := server.Records(zone.ID)
+
+	desired := []*luadns.Record{
+		{Name: "new.example.org.", Type: "A", Content: "1.1.1.1", TTL: 3600},
+		{Name: "new.example.org.", Type: "TXT", Content: "new", TTL: 3600},
+	}
+	changes := luadns.Diff(desired, current, &luadns.DiffOptions{Zone: zone.Name})
+
+	server.Fail = func(r *http.Request) int {
+		if r.URL.Path == "/zones/101/records/delete_many" {
+			return http.StatusBadRequest
+		}
+		return 0
+	}
+
+	results, err := server.Client().ApplyChanges(context.Background(), zone, changes)
+	assert.EqualError(t, err, "Invalid data for content: rejected")
+	assert.Len(t, results, 3)
+	for _, res := range results {
+		if res.Change.Action == luadns.ChangeDelete {
+			assert.Equal(t, err, res.Err)
+		} else {
+			assert.Equal(t, luadns.ErrChangeSkipped, res.Err)
+		}
+	}
+}
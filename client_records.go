@@ -181,3 +181,22 @@ func (c *Client) DeleteManyRecords(ctx context.Context, zone *Zone, recs []*RR)
 
 	return records, nil
 }
+
+// ListAllRecords returns all zone records following the pagination headers.
+func (c *Client) ListAllRecords(ctx context.Context, zone *Zone) ([]*Record, error) {
+	records := []*Record{}
+
+	for page := uint64(1); ; page++ {
+		var meta ListMeta
+
+		recs, err := c.ListRecords(ctx, zone, &ListParams{Page: page}, GetListMeta(&meta))
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, recs...)
+
+		if len(recs) == 0 || page >= meta.PagesCount {
+			return records, nil
+		}
+	}
+}
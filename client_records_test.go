@@ -29,6 +29,20 @@ func TestListRecordsEndpoint(t *testing.T) {
 	assert.Equal(t, record.TTL, uint32(3600))
 }
 
+func TestListAllRecordsEndpoint(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sendHTTPFixture(t, "/zones/5/records.index:page-"+r.URL.Query().Get("page"), w, r)
+	}))
+	defer server.Close()
+
+	c := luadns.NewClient("joe@example.com", "password", luadns.SetBaseURL(server.URL))
+	records, err := c.ListAllRecords(context.Background(), &luadns.Zone{ID: 5})
+	assert.NoError(t, err)
+	assert.Len(t, records, 11)
+	assert.Equal(t, records[0].ID, int64(115014343))
+	assert.Equal(t, records[10].ID, int64(115014352))
+}
+
 func TestCreateRecordEndpoint(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		sendHTTPFixture(t, "/zones/5/records.create", w, r)
@@ -99,3 +99,22 @@ func (c *Client) DeleteZone(ctx context.Context, zoneID int64) (*Zone, error) {
 
 	return &zone, nil
 }
+
+// ListAllZones returns all user zones following the pagination headers.
+func (c *Client) ListAllZones(ctx context.Context) ([]*Zone, error) {
+	zones := []*Zone{}
+
+	for page := uint64(1); ; page++ {
+		var meta ListMeta
+
+		items, err := c.ListZones(ctx, &ListParams{Page: page}, GetListMeta(&meta))
+		if err != nil {
+			return nil, err
+		}
+		zones = append(zones, items...)
+
+		if len(items) == 0 || page >= meta.PagesCount {
+			return zones, nil
+		}
+	}
+}
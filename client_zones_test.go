@@ -27,6 +27,21 @@ func TestListZonesEndpoint(t *testing.T) {
 	assert.Equal(t, zone.Name, "example.org")
 }
 
+func TestListAllZonesEndpoint(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sendHTTPFixture(t, "/zones.index:page-"+r.URL.Query().Get("page"), w, r)
+	}))
+	defer server.Close()
+
+	c := luadns.NewClient("joe@example.com", "password", luadns.SetBaseURL(server.URL))
+
+	zones, err := c.ListAllZones(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, zones, 2)
+	assert.Equal(t, zones[0].Name, "example.org")
+	assert.Equal(t, zones[1].Name, "example.net")
+}
+
 func TestCreateZoneEndpoint(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		sendHTTPFixture(t, "/zones.create", w, r)
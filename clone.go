@@ -0,0 +1,155 @@
+package luadns
+
+import (
+	"context"
+	"strings"
+)
+
+// CloneOptions represents options used by CloneZone.
+type CloneOptions struct {
+	IgnoreTypes []string // record types not copied
+	ChunkSize   int      // records sent per CreateManyRecords call, defaults to 100
+}
+
+// CloneZone copies `src` records into the zone named `dstName`. The
+// destination zone is created if needed, with the tags and template of `src`.
+//
+// Owner names and in-zone targets (CNAME, MX, SRV, ... content ending with
+// the source origin) are rewritten to the destination origin. Records
+// generated by LuaDNS are skipped, the others are created in bulk.
+//
+// An existing destination zone is synchronized instead: its records are
+// diffed against the copied records and only the changes are applied, so
+// cloning again doesn't duplicate records. Destination records missing from
+// `src` are deleted. The created and updated records are returned.
+func (c *Client) CloneZone(ctx context.Context, src *Zone, dstName string, opts *CloneOptions) (*Zone, []*Record, error) {
+	if opts == nil {
+		opts = &CloneOptions{}
+	}
+
+	records, err := c.ListAllRecords(ctx, src)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dst, err := c.findZone(ctx, dstName)
+	if err != nil {
+		return nil, nil, err
+	}
+	exists := dst != nil
+	if !exists {
+		dst, err = c.CreateZone(ctx, &Zone{Name: strings.TrimSuffix(dstName, "."), Tags: src.Tags, TemplateID: src.TemplateID})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	recs := []*RR{}
+	for _, r := range records {
+		if r.IsGenerated(src.Name) || hasType(opts.IgnoreTypes, r.Type) {
+			continue
+		}
+		recs = append(recs, &RR{
+			Name:    rewriteOrigin(r.Name, src.Name, dst.Name),
+			Type:    r.Type,
+			Content: rewriteTargets(r.Type, r.Content, src.Name, dst.Name),
+			TTL:     r.TTL,
+		})
+	}
+
+	if exists {
+		written, err := c.syncRecords(ctx, dst, recs, opts)
+		return dst, written, err
+	}
+
+	created, err := createInChunks(ctx, c, dst, recs, opts.ChunkSize)
+	return dst, created, err
+}
+
+// syncRecords applies the changes turning `dst` records into `recs`.
+func (c *Client) syncRecords(ctx context.Context, dst *Zone, recs []*RR, opts *CloneOptions) ([]*Record, error) {
+	current, err := c.ListAllRecords(ctx, dst)
+	if err != nil {
+		return nil, err
+	}
+
+	desired := []*Record{}
+	for _, rr := range recs {
+		desired = append(desired, &Record{Name: rr.Name, Type: rr.Type, Content: rr.Content, TTL: rr.TTL})
+	}
+	changes := Diff(desired, current, &DiffOptions{Zone: dst.Name, IgnoreTypes: opts.IgnoreTypes})
+	if changes.Empty() {
+		return []*Record{}, nil
+	}
+
+	results, err := c.ApplyChanges(ctx, dst, changes)
+	written := []*Record{}
+	for _, res := range results {
+		if res.Err == nil && res.Change.Action != ChangeDelete {
+			written = append(written, res.Records...)
+		}
+	}
+	return written, err
+}
+
+// findZone returns the zone named `name` or nil when it doesn't exist.
+func (c *Client) findZone(ctx context.Context, name string) (*Zone, error) {
+	zones, err := c.ListAllZones(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, z := range zones {
+		if strings.EqualFold(Fqdn(z.Name), Fqdn(name)) {
+			return z, nil
+		}
+	}
+	return nil, nil
+}
+
+// hasType reports whether `typ` is listed in `types`.
+func hasType(types []string, typ string) bool {
+	for _, t := range types {
+		if strings.EqualFold(t, typ) {
+			return true
+		}
+	}
+	return false
+}
+
+// rewriteOrigin replaces the `from` origin of `name` with `to`, names outside
+// `from` are returned unchanged.
+func rewriteOrigin(name, from, to string) string {
+	fqdn, from, to := Fqdn(name), Fqdn(from), Fqdn(to)
+
+	lower := strings.ToLower(fqdn)
+	switch {
+	case lower == strings.ToLower(from):
+		return to
+	case strings.HasSuffix(lower, "."+strings.ToLower(from)):
+		return fqdn[:len(fqdn)-len(from)] + to
+	}
+	return name
+}
+
+// rewriteTargets rewrites domain names found in record content from the
+// `from` origin to `to`.
+func rewriteTargets(typ, content, from, to string) string {
+	idx := -1
+	switch strings.ToUpper(typ) {
+	case TypeCNAME, TypeNS, TypePTR, TypeALIAS, "DNAME":
+		idx = 0
+	case TypeMX:
+		idx = 1
+	case TypeSRV:
+		idx = 3
+	}
+
+	fields := strings.Fields(content)
+	if idx < 0 || idx >= len(fields) {
+		return content
+	}
+
+	fields[idx] = rewriteOrigin(fields[idx], from, to)
+	return strings.Join(fields, " ")
+}
@@ -0,0 +1,76 @@
+package luadns_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/luadns/luadns-go"
+	"github.com/luadns/luadns-go/internal/fakeapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCloneZone(t *testing.T) {
+	server := fakeapi.New()
+	defer server.Close()
+
+	src := server.AddZone("example.org",
+		&luadns.Record{Name: "example.org.", Type: "A", Content: "1.1.1.1", TTL: 300},
+		&luadns.Record{Name: "example.org.", Type: "MX", Content: "10 mail.Example.org.", TTL: 300},
+		&luadns.Record{Name: "www.example.org.", Type: "CNAME", Content: "example.org.", TTL: 300},
+		&luadns.Record{Name: "cdn.example.org.", Type: "CNAME", Content: "cdn.example.com.", TTL: 300},
+		&luadns.Record{Name: "_sip._tcp.example.org.", Type: "SRV", Content: "10 5 5060 sip.example.org.", TTL: 300},
+		&luadns.Record{Name: "example.org.", Type: "TXT", Content: "example.org", TTL: 300},
+	)
+	c := server.Client()
+	ctx := context.Background()
+
+	_, err := c.UpdateZone(ctx, src.ID, &luadns.Zone{Name: src.Name, Tags: []string{"brand"}, TemplateID: 7})
+	assert.NoError(t, err)
+	src, err = c.GetZone(ctx, src.ID)
+	assert.NoError(t, err)
+
+	dst, created, err := c.CloneZone(ctx, src, "example.net", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "example.net", dst.Name)
+	assert.Equal(t, []string{"brand"}, dst.Tags)
+	assert.Equal(t, int64(7), dst.TemplateID)
+	assert.Len(t, created, 6)
+
+	records := []string{}
+	for _, r := range server.Records(dst.ID) {
+		if !r.IsGenerated(dst.Name) {
+			records = append(records, r.String())
+		}
+	}
+	assert.Equal(t, []string{
+		"example.net. 300 IN A 1.1.1.1",
+		"example.net. 300 IN MX 10 mail.example.net.",
+		"www.example.net. 300 IN CNAME example.net.",
+		"cdn.example.net. 300 IN CNAME cdn.example.com.",
+		"_sip._tcp.example.net. 300 IN SRV 10 5 5060 sip.example.net.",
+		"example.net. 300 IN TXT example.org",
+	}, records)
+
+	// Existing destination zones are reused.
+	other := server.AddZone("example.com")
+	dst, _, err = c.CloneZone(ctx, src, "example.com.", &luadns.CloneOptions{IgnoreTypes: []string{"txt", "MX"}})
+	assert.NoError(t, err)
+	assert.Equal(t, other.ID, dst.ID)
+	assert.Len(t, server.Records(other.ID), 3+4)
+
+	// Cloning again applies only the changes.
+	_, err = c.CreateRecord(ctx, dst, &luadns.Record{Name: "extra.example.com.", Type: "A", Content: "9.9.9.9", TTL: 300})
+	assert.NoError(t, err)
+	server.ResetRequests()
+	_, written, err := c.CloneZone(ctx, src, "example.com", &luadns.CloneOptions{IgnoreTypes: []string{"txt", "MX"}})
+	assert.NoError(t, err)
+	assert.Empty(t, written)
+	assert.Len(t, server.Records(other.ID), 3+4)
+	assert.Equal(t, []string{
+		"GET /zones/101/records",
+		"GET /zones",
+		"GET /zones/" + strconv.FormatInt(other.ID, 10) + "/records",
+		"POST /zones/" + strconv.FormatInt(other.ID, 10) + "/records/delete_many",
+	}, server.Requests())
+}
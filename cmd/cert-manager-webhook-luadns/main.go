@@ -0,0 +1,89 @@
+// Command cert-manager-webhook-luadns runs the LuaDNS DNS-01 solver as a
+// cert-manager webhook.
+//
+// The webhook API group is read from GROUP_NAME, it must match the
+// `groupName` of issuer solvers. Secrets referenced by issuer configurations
+// are read with the webhook service account.
+//
+// The command is a separate module: the cert-manager webhook library and the
+// Kubernetes client require a newer Go release than the luadns-go library.
+package main
+
+import (
+	"context"
+	"os"
+
+	"github.com/cert-manager/cert-manager/pkg/acme/webhook"
+	"github.com/cert-manager/cert-manager/pkg/acme/webhook/apis/acme/v1alpha1"
+	"github.com/cert-manager/cert-manager/pkg/acme/webhook/cmd"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"github.com/luadns/luadns-go/certmanager"
+)
+
+func main() {
+	groupName := os.Getenv("GROUP_NAME")
+	if groupName == "" {
+		panic("GROUP_NAME must be specified")
+	}
+
+	cmd.RunWebhookServer(groupName, &solver{})
+}
+
+// solver adapts certmanager.Solver to the cert-manager webhook.Solver interface.
+type solver struct {
+	certmanager.Solver
+}
+
+var _ webhook.Solver = &solver{}
+
+// Initialize builds a Kubernetes client reading issuer secrets.
+func (s *solver) Initialize(config *rest.Config, stopCh <-chan struct{}) error {
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+	return s.Solver.Initialize(&secretGetter{client: client}, stopCh)
+}
+
+func (s *solver) Present(ch *v1alpha1.ChallengeRequest) error {
+	return s.Solver.Present(challengeRequest(ch))
+}
+
+func (s *solver) CleanUp(ch *v1alpha1.ChallengeRequest) error {
+	return s.Solver.CleanUp(challengeRequest(ch))
+}
+
+// challengeRequest converts a cert-manager challenge request.
+func challengeRequest(ch *v1alpha1.ChallengeRequest) *certmanager.ChallengeRequest {
+	req := &certmanager.ChallengeRequest{
+		UID:                     string(ch.UID),
+		Action:                  string(ch.Action),
+		Type:                    ch.Type,
+		DNSName:                 ch.DNSName,
+		Key:                     ch.Key,
+		ResourceNamespace:       ch.ResourceNamespace,
+		ResolvedFQDN:            ch.ResolvedFQDN,
+		ResolvedZone:            ch.ResolvedZone,
+		AllowAmbientCredentials: ch.AllowAmbientCredentials,
+	}
+	if ch.Config != nil {
+		req.Config = ch.Config.Raw
+	}
+	return req
+}
+
+// secretGetter reads secrets with the Kubernetes API.
+type secretGetter struct {
+	client kubernetes.Interface
+}
+
+func (g *secretGetter) GetSecret(ctx context.Context, namespace, name string) (map[string][]byte, error) {
+	secret, err := g.client.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return secret.Data, nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/cert-manager/cert-manager/pkg/acme/webhook/apis/acme/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+
+	"github.com/luadns/luadns-go/certmanager"
+)
+
+func TestChallengeRequest(t *testing.T) {
+	ch := &v1alpha1.ChallengeRequest{
+		UID:               "1234",
+		Action:            v1alpha1.ChallengeActionPresent,
+		Type:              "dns-01",
+		DNSName:           "example.org",
+		Key:               "token",
+		ResourceNamespace: "default",
+		ResolvedFQDN:      "_acme-challenge.example.org.",
+		ResolvedZone:      "example.org.",
+		Config:            &apiextensionsv1.JSON{Raw: []byte(`{"email":"joe@example.com"}`)},
+	}
+
+	assert.Equal(t, &certmanager.ChallengeRequest{
+		UID:               "1234",
+		Action:            "Present",
+		Type:              "dns-01",
+		DNSName:           "example.org",
+		Key:               "token",
+		ResourceNamespace: "default",
+		ResolvedFQDN:      "_acme-challenge.example.org.",
+		ResolvedZone:      "example.org.",
+		Config:            []byte(`{"email":"joe@example.com"}`),
+	}, challengeRequest(ch))
+}
@@ -0,0 +1,79 @@
+// Command external-dns-luadns implements the external-dns webhook provider
+// protocol for LuaDNS.
+//
+// The webhook listens on localhost:8888 (as expected by external-dns), the
+// health check is served on /healthz of the -health address. Credentials are
+// read from LUADNS_API_USERNAME and LUADNS_API_TOKEN.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	api "github.com/luadns/luadns-go"
+)
+
+const (
+	baseURL = "https://api.luadns.com/v1"
+)
+
+// stringsFlag represents a repeatable flag.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(s string) error {
+	*f = append(*f, s)
+	return nil
+}
+
+var (
+	url       string
+	listen    string
+	health    string
+	batchSize int
+	dryRun    bool
+	include   stringsFlag
+	exclude   stringsFlag
+)
+
+func main() {
+	flag.StringVar(&url, "url", baseURL, "base URL")
+	flag.StringVar(&listen, "listen", "localhost:8888", "webhook listen address")
+	flag.StringVar(&health, "health", ":8080", "health check listen address")
+	flag.IntVar(&batchSize, "batch-size", defaultBatchSize, "records sent per bulk API call")
+	flag.BoolVar(&dryRun, "dry-run", false, "don't apply changes")
+	flag.Var(&include, "domain-filter", "limit to domains (repeatable)")
+	flag.Var(&exclude, "exclude-domains", "exclude domains (repeatable)")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [options]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	email, key := os.Getenv("LUADNS_API_USERNAME"), os.Getenv("LUADNS_API_TOKEN")
+	if email == "" || key == "" {
+		log.Fatalln("LUADNS_API_USERNAME and LUADNS_API_TOKEN are required")
+	}
+
+	c := api.NewClient(email, key, api.SetBaseURL(url))
+	c.UserAgent("external-dns-luadns")
+	p := &Provider{Client: c, Include: include, Exclude: exclude, BatchSize: batchSize, DryRun: dryRun}
+
+	go func() {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("ok"))
+		})
+		log.Fatalln(http.ListenAndServe(health, mux))
+	}()
+
+	log.Println("Listening on", listen)
+	log.Fatalln(http.ListenAndServe(listen, Handler(p)))
+}
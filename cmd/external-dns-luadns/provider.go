@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	api "github.com/luadns/luadns-go"
+)
+
+// supportedTypes lists record types exposed to external-dns.
+var supportedTypes = []string{api.TypeA, api.TypeAAAA, api.TypeCNAME, api.TypeTXT, api.TypeMX, api.TypeSRV, api.TypeNS}
+
+// defaultBatchSize is the number of records sent in a single bulk API call.
+const defaultBatchSize = 100
+
+// Provider translates external-dns endpoints to LuaDNS records.
+type Provider struct {
+	Client    *api.Client
+	Include   []string // managed domains, all zones when empty
+	Exclude   []string // excluded domains
+	BatchSize int
+	DryRun    bool
+}
+
+// DomainFilter returns the domain filter sent to external-dns on negotiation.
+func (p *Provider) DomainFilter() *DomainFilter {
+	return &DomainFilter{Include: p.Include, Exclude: p.Exclude}
+}
+
+// Records returns the endpoints of managed zones.
+func (p *Provider) Records(ctx context.Context) ([]*Endpoint, error) {
+	zones, err := p.zones(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoints := []*Endpoint{}
+	for _, z := range zones {
+		records, err := p.Client.ListAllRecords(ctx, z)
+		if err != nil {
+			return nil, err
+		}
+
+		sets := map[string]*Endpoint{}
+		keys := []string{}
+		for _, r := range records {
+			name := strings.TrimSuffix(strings.ToLower(r.Name), ".")
+			if r.IsGenerated(z.Name) || !supported(r.Type) || !p.managed(name) {
+				continue
+			}
+
+			key := name + " " + r.Type
+			ep, ok := sets[key]
+			if !ok {
+				ep = &Endpoint{DNSName: name, RecordType: r.Type, RecordTTL: int64(r.TTL)}
+				sets[key] = ep
+				keys = append(keys, key)
+			}
+			ep.Targets = append(ep.Targets, toTarget(r.Type, r.Content))
+		}
+
+		sort.Strings(keys)
+		for _, key := range keys {
+			endpoints = append(endpoints, sets[key])
+		}
+	}
+
+	return endpoints, nil
+}
+
+// AdjustEndpoints normalizes endpoints the way Records returns them, so
+// external-dns doesn't detect changes on each synchronization.
+func (p *Provider) AdjustEndpoints(endpoints []*Endpoint) []*Endpoint {
+	adjusted := []*Endpoint{}
+	for _, ep := range endpoints {
+		ep.DNSName = strings.TrimSuffix(strings.ToLower(ep.DNSName), ".")
+		for i, t := range ep.Targets {
+			ep.Targets[i] = toTarget(ep.RecordType, toContent(ep.RecordType, t))
+		}
+		ep.ProviderSpecific = nil
+		adjusted = append(adjusted, ep)
+	}
+	return adjusted
+}
+
+// ApplyChanges applies external-dns changes zone by zone, deletions first,
+// then updates (replacing whole record sets) and creations, in batches.
+func (p *Provider) ApplyChanges(ctx context.Context, changes *Changes) error {
+	zones, err := p.zones(ctx)
+	if err != nil {
+		return err
+	}
+
+	type zoneChanges struct {
+		creates, updates, deletes []*api.RR
+	}
+	byZone := map[*api.Zone]*zoneChanges{}
+	add := func(ep *Endpoint, list func(zc *zoneChanges) *[]*api.RR) {
+		z := api.FindZone(zones, ep.DNSName)
+		if z == nil || !p.managed(ep.DNSName) {
+			return
+		}
+		zc, ok := byZone[z]
+		if !ok {
+			zc = &zoneChanges{}
+			byZone[z] = zc
+		}
+		*list(zc) = append(*list(zc), toRRs(ep)...)
+	}
+
+	for _, ep := range changes.Delete {
+		add(ep, func(zc *zoneChanges) *[]*api.RR { return &zc.deletes })
+	}
+	for _, ep := range changes.UpdateNew {
+		add(ep, func(zc *zoneChanges) *[]*api.RR { return &zc.updates })
+	}
+	for _, ep := range changes.Create {
+		add(ep, func(zc *zoneChanges) *[]*api.RR { return &zc.creates })
+	}
+
+	if p.DryRun {
+		return nil
+	}
+
+	for _, z := range zones {
+		zc, ok := byZone[z]
+		if !ok {
+			continue
+		}
+		if err := p.batches(zc.deletes, func(rrs []*api.RR) error {
+			_, err := p.Client.DeleteManyRecords(ctx, z, rrs)
+			return err
+		}); err != nil {
+			return err
+		}
+		if err := p.batches(zc.updates, func(rrs []*api.RR) error {
+			_, err := p.Client.UpdateManyRecords(ctx, z, rrs)
+			return err
+		}); err != nil {
+			return err
+		}
+		if err := p.batches(zc.creates, func(rrs []*api.RR) error {
+			_, err := p.Client.CreateManyRecords(ctx, z, rrs)
+			return err
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// batches calls `fn` with batches of at most BatchSize RRs, RRs of a
+// (name, type) set are kept in the same batch.
+func (p *Provider) batches(rrs []*api.RR, fn func(rrs []*api.RR) error) error {
+	size := p.BatchSize
+	if size <= 0 {
+		size = defaultBatchSize
+	}
+
+	for len(rrs) > 0 {
+		n := size
+		if n > len(rrs) {
+			n = len(rrs)
+		}
+		for n < len(rrs) && rrs[n].Name == rrs[n-1].Name && rrs[n].Type == rrs[n-1].Type {
+			n++
+		}
+
+		if err := fn(rrs[:n]); err != nil {
+			return err
+		}
+		rrs = rrs[n:]
+	}
+	return nil
+}
+
+// zones returns the account zones matching the domain filter.
+func (p *Provider) zones(ctx context.Context) ([]*api.Zone, error) {
+	zones, err := p.Client.ListAllZones(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	managed := []*api.Zone{}
+	for _, z := range zones {
+		name := strings.ToLower(z.Name)
+		if len(p.Include) == 0 {
+			managed = append(managed, z)
+			continue
+		}
+		for _, d := range p.Include {
+			d = strings.TrimSuffix(strings.ToLower(d), ".")
+			if inDomain(name, d) || inDomain(d, name) {
+				managed = append(managed, z)
+				break
+			}
+		}
+	}
+	return managed, nil
+}
+
+// managed reports whether `name` matches the domain filter.
+func (p *Provider) managed(name string) bool {
+	name = strings.TrimSuffix(strings.ToLower(name), ".")
+	for _, d := range p.Exclude {
+		if inDomain(name, strings.TrimSuffix(strings.ToLower(d), ".")) {
+			return false
+		}
+	}
+	if len(p.Include) == 0 {
+		return true
+	}
+	for _, d := range p.Include {
+		if inDomain(name, strings.TrimSuffix(strings.ToLower(d), ".")) {
+			return true
+		}
+	}
+	return false
+}
+
+// inDomain reports whether `name` equals `domain` or is a subdomain of it.
+func inDomain(name, domain string) bool {
+	return name == domain || strings.HasSuffix(name, "."+domain)
+}
+
+func supported(typ string) bool {
+	for _, t := range supportedTypes {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// toRRs converts an endpoint to API RRs.
+func toRRs(ep *Endpoint) []*api.RR {
+	rrs := []*api.RR{}
+	for _, t := range ep.Targets {
+		rrs = append(rrs, &api.RR{
+			Name:    api.Fqdn(strings.ToLower(ep.DNSName)),
+			Type:    ep.RecordType,
+			Content: toContent(ep.RecordType, t),
+			TTL:     uint32(ep.RecordTTL),
+		})
+	}
+	return rrs
+}
+
+// toContent converts an endpoint target to record content: TXT targets are
+// unquoted, host names are fully qualified.
+func toContent(typ, target string) string {
+	switch typ {
+	case api.TypeTXT:
+		if len(target) >= 2 && strings.HasPrefix(target, `"`) && strings.HasSuffix(target, `"`) {
+			return target[1 : len(target)-1]
+		}
+		return target
+	case api.TypeCNAME, api.TypeNS, api.TypeMX, api.TypeSRV:
+		fields := strings.Fields(target)
+		if len(fields) > 0 {
+			fields[len(fields)-1] = api.Fqdn(fields[len(fields)-1])
+		}
+		return strings.Join(fields, " ")
+	}
+	return target
+}
+
+// toTarget converts record content to an endpoint target: TXT targets are
+// quoted, host names have no trailing dot.
+func toTarget(typ, content string) string {
+	switch typ {
+	case api.TypeTXT:
+		return `"` + content + `"`
+	case api.TypeCNAME, api.TypeNS, api.TypeMX, api.TypeSRV:
+		fields := strings.Fields(content)
+		if len(fields) > 0 {
+			fields[len(fields)-1] = strings.TrimSuffix(fields[len(fields)-1], ".")
+		}
+		return strings.Join(fields, " ")
+	}
+	return content
+}
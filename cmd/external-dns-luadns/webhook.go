@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// mediaType is the external-dns webhook protocol media type.
+const mediaType = "application/external.dns.webhook+json;version=1"
+
+// Endpoint represents an external-dns endpoint, a (name, type) record set.
+type Endpoint struct {
+	DNSName          string             `json:"dnsName,omitempty"`
+	Targets          []string           `json:"targets,omitempty"`
+	RecordType       string             `json:"recordType,omitempty"`
+	SetIdentifier    string             `json:"setIdentifier,omitempty"`
+	RecordTTL        int64              `json:"recordTTL,omitempty"`
+	Labels           map[string]string  `json:"labels,omitempty"`
+	ProviderSpecific []ProviderProperty `json:"providerSpecific,omitempty"`
+}
+
+// ProviderProperty represents a provider specific endpoint property.
+type ProviderProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Changes represents endpoints changes computed by external-dns.
+type Changes struct {
+	Create    []*Endpoint `json:"Create,omitempty"`
+	UpdateOld []*Endpoint `json:"UpdateOld,omitempty"`
+	UpdateNew []*Endpoint `json:"UpdateNew,omitempty"`
+	Delete    []*Endpoint `json:"Delete,omitempty"`
+}
+
+// DomainFilter represents the domains managed by the provider, returned on negotiation.
+type DomainFilter struct {
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// Handler returns the webhook protocol HTTP handler.
+func Handler(p *Provider) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" || r.Method != http.MethodGet {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(w, http.StatusOK, p.DomainFilter())
+	})
+
+	mux.HandleFunc("/records", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			endpoints, err := p.Records(r.Context())
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, endpoints)
+		case http.MethodPost:
+			var changes Changes
+			if err := json.NewDecoder(r.Body).Decode(&changes); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := p.ApplyChanges(r.Context(), &changes); err != nil {
+				writeError(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+
+	mux.HandleFunc("/adjustendpoints", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var endpoints []*Endpoint
+		if err := json.NewDecoder(r.Body).Decode(&endpoints); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, http.StatusOK, p.AdjustEndpoints(endpoints))
+	})
+
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", mediaType)
+	w.Header().Set("Vary", "Content-Type")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	log.Println(err)
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
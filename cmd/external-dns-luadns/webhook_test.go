@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+
+	api "github.com/luadns/luadns-go"
+	"github.com/luadns/luadns-go/internal/fakeapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func request(t *testing.T, h http.Handler, method, path string, body any) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		assert.NoError(t, json.NewEncoder(&buf).Encode(body))
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Accept", mediaType)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestWebhook(t *testing.T) {
+	server := fakeapi.New()
+	defer server.Close()
+
+	org := server.AddZone("example.org",
+		&api.Record{Name: "www.example.org.", Type: "A", Content: "1.1.1.1", TTL: 300},
+		&api.Record{Name: "www.example.org.", Type: "A", Content: "2.2.2.2", TTL: 300},
+		&api.Record{Name: "app.example.org.", Type: "CNAME", Content: "www.example.org.", TTL: 300},
+		&api.Record{Name: "a-www.example.org.", Type: "TXT", Content: "heritage=external-dns,external-dns/owner=default", TTL: 300},
+		&api.Record{Name: "old.example.org.", Type: "A", Content: "3.3.3.3", TTL: 300},
+		&api.Record{Name: "www.private.example.org.", Type: "A", Content: "10.0.0.1", TTL: 300},
+	)
+	server.AddZone("example.net", &api.Record{Name: "www.example.net.", Type: "A", Content: "1.1.1.1", TTL: 300})
+
+	p := &Provider{Client: server.Client(), Include: []string{"example.org"}, Exclude: []string{"private.example.org"}, BatchSize: 1}
+	h := Handler(p)
+
+	// Negotiate.
+	w := request(t, h, http.MethodGet, "/", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, mediaType, w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"include":["example.org"],"exclude":["private.example.org"]}`, w.Body.String())
+
+	// Records.
+	w = request(t, h, http.MethodGet, "/records", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var endpoints []*Endpoint
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &endpoints))
+	assert.Equal(t, []*Endpoint{
+		{DNSName: "a-www.example.org", RecordType: "TXT", Targets: []string{`"heritage=external-dns,external-dns/owner=default"`}, RecordTTL: 300},
+		{DNSName: "app.example.org", RecordType: "CNAME", Targets: []string{"www.example.org"}, RecordTTL: 300},
+		{DNSName: "old.example.org", RecordType: "A", Targets: []string{"3.3.3.3"}, RecordTTL: 300},
+		{DNSName: "www.example.org", RecordType: "A", Targets: []string{"1.1.1.1", "2.2.2.2"}, RecordTTL: 300},
+	}, endpoints)
+
+	// Adjust endpoints.
+	w = request(t, h, http.MethodPost, "/adjustendpoints", []*Endpoint{{DNSName: "New.example.org.", RecordType: "CNAME", Targets: []string{"www.example.org."}}})
+	assert.JSONEq(t, `[{"dnsName":"new.example.org","recordType":"CNAME","targets":["www.example.org"]}]`, w.Body.String())
+
+	// Apply changes.
+	changes := &Changes{
+		Create: []*Endpoint{
+			{DNSName: "new.example.org", RecordType: "CNAME", Targets: []string{"www.example.org"}, RecordTTL: 300},
+			{DNSName: "a-new.example.org", RecordType: "TXT", Targets: []string{`"heritage=external-dns,external-dns/owner=default"`}, RecordTTL: 300},
+			{DNSName: "www.private.example.org", RecordType: "A", Targets: []string{"10.0.0.2"}, RecordTTL: 300},
+		},
+		UpdateOld: []*Endpoint{{DNSName: "www.example.org", RecordType: "A", Targets: []string{"1.1.1.1", "2.2.2.2"}, RecordTTL: 300}},
+		UpdateNew: []*Endpoint{{DNSName: "www.example.org", RecordType: "A", Targets: []string{"1.1.1.1", "4.4.4.4"}, RecordTTL: 300}},
+		Delete:    []*Endpoint{{DNSName: "old.example.org", RecordType: "A", Targets: []string{"3.3.3.3"}, RecordTTL: 300}},
+	}
+	w = request(t, h, http.MethodPost, "/records", changes)
+	assert.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+
+	records := []string{}
+	for _, r := range server.Records(org.ID)[3:] {
+		records = append(records, r.String())
+	}
+	sort.Strings(records)
+	assert.Equal(t, []string{
+		"a-new.example.org. 300 IN TXT heritage=external-dns,external-dns/owner=default",
+		"a-www.example.org. 300 IN TXT heritage=external-dns,external-dns/owner=default",
+		"app.example.org. 300 IN CNAME www.example.org.",
+		"new.example.org. 300 IN CNAME www.example.org.",
+		"www.example.org. 300 IN A 1.1.1.1",
+		"www.example.org. 300 IN A 4.4.4.4",
+		"www.private.example.org. 300 IN A 10.0.0.1",
+	}, records)
+
+	// API errors are reported to external-dns.
+	server.Fail = func(r *http.Request) int { return http.StatusForbidden }
+	w = request(t, h, http.MethodGet, "/records", nil)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestBatches(t *testing.T) {
+	p := &Provider{BatchSize: 2}
+	rrs := toRRs(&Endpoint{DNSName: "a.example.org", RecordType: "A", Targets: []string{"1.1.1.1", "2.2.2.2", "3.3.3.3"}})
+	rrs = append(rrs, toRRs(&Endpoint{DNSName: "b.example.org", RecordType: "A", Targets: []string{"1.1.1.1"}})...)
+
+	sizes := []int{}
+	assert.NoError(t, p.batches(rrs, func(rrs []*api.RR) error {
+		sizes = append(sizes, len(rrs))
+		return nil
+	}))
+	assert.Equal(t, []int{3, 1}, sizes)
+}
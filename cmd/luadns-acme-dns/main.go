@@ -0,0 +1,67 @@
+// Command luadns-acme-dns is an acme-dns compatible delegation server for
+// LuaDNS.
+//
+// Machines requesting certificates register with the server and receive
+// credentials limited to the TXT records of their subdomain, so the LuaDNS
+// API key stays on the server. Registrations are stored in the -db file.
+// Credentials are read from LUADNS_API_USERNAME and LUADNS_API_TOKEN.
+//
+//	luadns-acme-dns -domain auth.example.org -db /var/lib/luadns-acme-dns.json
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	api "github.com/luadns/luadns-go"
+)
+
+const (
+	baseURL = "https://api.luadns.com/v1"
+)
+
+var (
+	url                 string
+	listen              string
+	domain              string
+	db                  string
+	ttl                 uint
+	disableRegistration bool
+)
+
+func main() {
+	flag.StringVar(&url, "url", baseURL, "base URL")
+	flag.StringVar(&listen, "listen", ":8080", "HTTP listen address")
+	flag.StringVar(&domain, "domain", "", "domain holding registrations, in a LuaDNS zone")
+	flag.StringVar(&db, "db", "luadns-acme-dns.json", "registrations file")
+	flag.UintVar(&ttl, "ttl", 60, "TXT record TTL")
+	flag.BoolVar(&disableRegistration, "disable-registration", false, "reject new registrations")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [options]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	email, key := os.Getenv("LUADNS_API_USERNAME"), os.Getenv("LUADNS_API_TOKEN")
+	if email == "" || key == "" {
+		log.Fatalln("LUADNS_API_USERNAME and LUADNS_API_TOKEN are required")
+	}
+	if domain == "" {
+		log.Fatalln("-domain is required")
+	}
+
+	store, err := OpenStore(db)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	c := api.NewClient(email, key, api.SetBaseURL(url))
+	c.UserAgent("luadns-acme-dns")
+	s := &Server{Client: c, Store: store, Domain: domain, TTL: uint32(ttl), DisableRegistration: disableRegistration}
+
+	log.Println("Listening on", listen)
+	log.Fatalln(http.ListenAndServe(listen, Handler(s)))
+}
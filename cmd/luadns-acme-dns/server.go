@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+
+	api "github.com/luadns/luadns-go"
+)
+
+// Server implements the acme-dns HTTP API, see
+// https://github.com/joohoi/acme-dns#api.
+//
+// Every registration gets a random subdomain of Domain, its TXT records are
+// written to <subdomain>.<Domain> (the returned fulldomain) like acme-dns.
+// Certificate clients delegate validation with a CNAME record:
+//
+//	_acme-challenge.www.example.org. CNAME <subdomain>.auth.example.org.
+type Server struct {
+	Client              *api.Client
+	Store               *Store
+	Domain              string // domain holding registrations, in a LuaDNS zone
+	TTL                 uint32
+	DisableRegistration bool
+
+	mu    sync.Mutex
+	zone  *api.Zone
+	locks map[string]*sync.Mutex // serialize updates per registration
+}
+
+type registerRequest struct {
+	AllowFrom []string `json:"allowfrom"`
+}
+
+type registerResponse struct {
+	Username   string   `json:"username"`
+	Password   string   `json:"password"`
+	FullDomain string   `json:"fulldomain"`
+	Subdomain  string   `json:"subdomain"`
+	AllowFrom  []string `json:"allowfrom"`
+}
+
+type updateRequest struct {
+	Subdomain string `json:"subdomain"`
+	TXT       string `json:"txt"`
+}
+
+type updateResponse struct {
+	TXT string `json:"txt"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// Handler returns the HTTP handler serving the acme-dns API.
+func Handler(s *Server) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/register", s.register)
+	mux.HandleFunc("/update", s.update)
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	return mux
+}
+
+func (s *Server) register(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if s.DisableRegistration {
+		writeJSON(w, http.StatusForbidden, errorResponse{"registration_disabled"})
+		return
+	}
+
+	var req registerRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{"malformed_json_payload"})
+			return
+		}
+	}
+	for _, cidr := range req.AllowFrom {
+		if _, err := netip.ParsePrefix(cidr); err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{"invalid_allowfrom_cidr"})
+			return
+		}
+	}
+
+	password := randomString(30)
+	reg := &Registration{
+		Username:     newUUID(),
+		PasswordHash: hashPassword(password),
+		Subdomain:    newUUID(),
+		AllowFrom:    req.AllowFrom,
+	}
+	if err := s.Store.Add(reg); err != nil {
+		log.Println(err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"db_error"})
+		return
+	}
+
+	allowFrom := reg.AllowFrom
+	if allowFrom == nil {
+		allowFrom = []string{}
+	}
+	writeJSON(w, http.StatusCreated, registerResponse{
+		Username:   reg.Username,
+		Password:   password,
+		FullDomain: s.fullDomain(reg.Subdomain),
+		Subdomain:  reg.Subdomain,
+		AllowFrom:  allowFrom,
+	})
+}
+
+func (s *Server) update(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	reg := s.Store.Authenticate(r.Header.Get("X-Api-User"), r.Header.Get("X-Api-Key"))
+	if reg == nil || !allowed(reg.AllowFrom, r.RemoteAddr) {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"forbidden"})
+		return
+	}
+
+	var req updateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"malformed_json_payload"})
+		return
+	}
+	if req.Subdomain != reg.Subdomain {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"forbidden"})
+		return
+	}
+	if !validTXT(req.TXT) {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"bad_txt"})
+		return
+	}
+
+	// Updates of a registration are applied one at a time, the previous
+	// value is read once the last update is saved.
+	lock := s.lock(reg.Username)
+	lock.Lock()
+	defer lock.Unlock()
+
+	// Keep the previous value, wildcard and apex certificates are validated together.
+	values := []string{req.TXT}
+	if previous := s.Store.Values(reg.Username); len(previous) > 0 && previous[0] != req.TXT {
+		values = append(values, previous[0])
+	}
+
+	if err := s.write(r.Context(), reg.Subdomain, values); err != nil {
+		log.Println(err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"api_error"})
+		return
+	}
+	if err := s.Store.SetValues(reg.Username, values); err != nil {
+		log.Println(err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"db_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, updateResponse{TXT: req.TXT})
+}
+
+// write replaces the TXT record set of a subdomain.
+func (s *Server) write(ctx context.Context, subdomain string, values []string) error {
+	zone, err := s.findZone(ctx)
+	if err != nil {
+		return err
+	}
+
+	name := api.Fqdn(s.fullDomain(subdomain))
+	rrs := []*api.RR{}
+	for _, v := range values {
+		rrs = append(rrs, &api.RR{Name: name, Type: api.TypeTXT, Content: v, TTL: s.TTL})
+	}
+	_, err = s.Client.UpdateManyRecords(ctx, zone, rrs)
+	return err
+}
+
+// findZone returns the account zone holding Domain (longest match), the zone
+// is looked up once.
+func (s *Server) findZone(ctx context.Context) (*api.Zone, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.zone != nil {
+		return s.zone, nil
+	}
+
+	zones, err := s.Client.ListAllZones(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.zone = api.FindZone(zones, s.Domain)
+	if s.zone == nil {
+		return nil, fmt.Errorf("no zone found for %s", s.Domain)
+	}
+	return s.zone, nil
+}
+
+// lock returns the mutex serializing updates of a registration.
+func (s *Server) lock(username string) *sync.Mutex {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.locks == nil {
+		s.locks = map[string]*sync.Mutex{}
+	}
+	if _, ok := s.locks[username]; !ok {
+		s.locks[username] = &sync.Mutex{}
+	}
+	return s.locks[username]
+}
+
+// fullDomain returns the name of the TXT record of a subdomain.
+func (s *Server) fullDomain(subdomain string) string {
+	return subdomain + "." + strings.TrimSuffix(strings.ToLower(s.Domain), ".")
+}
+
+// allowed reports whether the client address is in the allowed ranges, any
+// address is allowed when there are no ranges.
+func allowed(cidrs []string, remoteAddr string) bool {
+	if len(cidrs) == 0 {
+		return true
+	}
+
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+
+	for _, cidr := range cidrs {
+		if prefix, err := netip.ParsePrefix(cidr); err == nil && prefix.Contains(addr.Unmap()) {
+			return true
+		}
+	}
+	return false
+}
+
+// validTXT reports whether `txt` is a DNS-01 challenge value (base64url
+// encoded SHA-256 digest).
+func validTXT(txt string) bool {
+	if len(txt) != 43 {
+		return false
+	}
+	_, err := base64.RawURLEncoding.DecodeString(txt)
+	return err == nil
+}
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+
+	h := hex.EncodeToString(b[:])
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+// randomString returns a random URL safe string of `n` bytes of entropy.
+func randomString(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
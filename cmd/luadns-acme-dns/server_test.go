@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	api "github.com/luadns/luadns-go"
+	"github.com/luadns/luadns-go/internal/fakeapi"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	txt1 = "61rBZ_4knHblO0MNoxFsXZ_eTFUHum0B6IVRbhvUn5I"
+	txt2 = "LPsIwTo7o8BoG0-vjCyGQGBWSVIPxI-i_X336eUOQZo"
+)
+
+func request(t *testing.T, h http.Handler, path string, headers map[string]string, body any) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		assert.NoError(t, json.NewEncoder(&buf).Encode(body))
+	}
+	req := httptest.NewRequest(http.MethodPost, path, &buf)
+	req.RemoteAddr = "192.0.2.10:1234"
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestServer(t *testing.T) {
+	server := fakeapi.New()
+	defer server.Close()
+
+	org := server.AddZone("example.org",
+		&api.Record{Name: "www.example.org.", Type: "A", Content: "1.1.1.1", TTL: 300},
+	)
+
+	db := filepath.Join(t.TempDir(), "registrations.json")
+	store, err := OpenStore(db)
+	assert.NoError(t, err)
+
+	s := &Server{Client: server.Client(), Store: store, Domain: "auth.example.org", TTL: 60}
+	h := Handler(s)
+
+	// Register.
+	w := request(t, h, "/register", nil, nil)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var reg registerResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &reg))
+	assert.Len(t, reg.Username, 36)
+	assert.Len(t, reg.Password, 40)
+	assert.Equal(t, reg.Subdomain+".auth.example.org", reg.FullDomain)
+	assert.Equal(t, []string{}, reg.AllowFrom)
+
+	auth := map[string]string{"X-Api-User": reg.Username, "X-Api-Key": reg.Password}
+
+	// Update keeps the previous value.
+	for _, txt := range []string{txt1, txt2} {
+		w = request(t, h, "/update", auth, updateRequest{Subdomain: reg.Subdomain, TXT: txt})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"txt":"`+txt+`"}`, w.Body.String())
+	}
+
+	var contents []string
+	for _, r := range server.Records(org.ID)[3:] {
+		contents = append(contents, r.Name+" "+r.Type+" "+r.Content)
+	}
+	assert.Equal(t, []string{
+		"www.example.org. A 1.1.1.1",
+		reg.FullDomain + ". TXT " + txt2,
+		reg.FullDomain + ". TXT " + txt1,
+	}, contents)
+
+	// Registrations are reloaded from the file.
+	store, err = OpenStore(db)
+	assert.NoError(t, err)
+	if r := store.Authenticate(reg.Username, reg.Password); assert.NotNil(t, r) {
+		assert.Equal(t, []string{txt2, txt1}, r.Values)
+	}
+	assert.Nil(t, store.Authenticate(reg.Username, "invalid"))
+}
+
+// TestServerStockClient follows acme-dns clients: the challenge name is
+// delegated to fulldomain with a CNAME, TXT records are looked up there.
+func TestServerStockClient(t *testing.T) {
+	server := fakeapi.New()
+	defer server.Close()
+
+	org := server.AddZone("example.org")
+	store, err := OpenStore("")
+	assert.NoError(t, err)
+	h := Handler(&Server{Client: server.Client(), Store: store, Domain: "auth.example.org", TTL: 60})
+
+	var reg map[string]any
+	assert.NoError(t, json.Unmarshal(request(t, h, "/register", nil, nil).Body.Bytes(), &reg))
+	fulldomain := reg["fulldomain"].(string)
+	assert.Equal(t, reg["subdomain"].(string)+".auth.example.org", fulldomain)
+
+	_, err = server.Client().CreateRecord(context.Background(), org, &api.Record{
+		Name: "_acme-challenge.www.example.org.", Type: api.TypeCNAME, Content: fulldomain + ".", TTL: 300,
+	})
+	assert.NoError(t, err)
+
+	auth := map[string]string{"X-Api-User": reg["username"].(string), "X-Api-Key": reg["password"].(string)}
+	body := map[string]string{"subdomain": reg["subdomain"].(string), "txt": txt1}
+	assert.Equal(t, http.StatusOK, request(t, h, "/update", auth, body).Code)
+
+	// Resolve the challenge name through the CNAME.
+	records := server.Records(org.ID)
+	target := ""
+	for _, r := range records {
+		if r.Name == "_acme-challenge.www.example.org." && r.Type == api.TypeCNAME {
+			target = r.Content
+		}
+	}
+	var values []string
+	for _, r := range records {
+		if r.Name == target && r.Type == api.TypeTXT {
+			values = append(values, r.Content)
+		}
+	}
+	assert.Equal(t, []string{txt1}, values)
+}
+
+func TestServerConcurrentUpdates(t *testing.T) {
+	server := fakeapi.New()
+	defer server.Close()
+
+	org := server.AddZone("example.org")
+	store, err := OpenStore("")
+	assert.NoError(t, err)
+	h := Handler(&Server{Client: server.Client(), Store: store, Domain: "auth.example.org", TTL: 60})
+
+	var reg registerResponse
+	assert.NoError(t, json.Unmarshal(request(t, h, "/register", nil, nil).Body.Bytes(), &reg))
+	auth := map[string]string{"X-Api-User": reg.Username, "X-Api-Key": reg.Password}
+
+	// Concurrent updates (wildcard and apex challenges) keep both values.
+	var wg sync.WaitGroup
+	for _, txt := range []string{txt1, txt2} {
+		txt := txt
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Equal(t, http.StatusOK, request(t, h, "/update", auth, updateRequest{Subdomain: reg.Subdomain, TXT: txt}).Code)
+		}()
+	}
+	wg.Wait()
+
+	assert.ElementsMatch(t, []string{txt1, txt2}, store.Values(reg.Username))
+	assert.Len(t, server.Records(org.ID), 3+2)
+}
+
+func TestServerErrors(t *testing.T) {
+	server := fakeapi.New()
+	defer server.Close()
+	server.AddZone("example.org")
+
+	store, _ := OpenStore("")
+	s := &Server{Client: server.Client(), Store: store, Domain: "auth.example.org"}
+	h := Handler(s)
+
+	w := request(t, h, "/register", nil, registerRequest{AllowFrom: []string{"invalid"}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"error":"invalid_allowfrom_cidr"}`, w.Body.String())
+
+	w = request(t, h, "/register", nil, registerRequest{AllowFrom: []string{"198.51.100.0/24"}})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var reg registerResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &reg))
+	auth := map[string]string{"X-Api-User": reg.Username, "X-Api-Key": reg.Password}
+
+	w = request(t, h, "/register", nil, nil)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var other registerResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &other))
+	otherAuth := map[string]string{"X-Api-User": other.Username, "X-Api-Key": other.Password}
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		body    any
+		code    int
+		err     string
+	}{
+		{"no credentials", nil, updateRequest{Subdomain: other.Subdomain, TXT: txt1}, http.StatusUnauthorized, "forbidden"},
+		{"bad password", map[string]string{"X-Api-User": other.Username, "X-Api-Key": "invalid"}, updateRequest{Subdomain: other.Subdomain, TXT: txt1}, http.StatusUnauthorized, "forbidden"},
+		{"address not allowed", auth, updateRequest{Subdomain: reg.Subdomain, TXT: txt1}, http.StatusUnauthorized, "forbidden"},
+		{"other subdomain", otherAuth, updateRequest{Subdomain: reg.Subdomain, TXT: txt1}, http.StatusUnauthorized, "forbidden"},
+		{"bad txt", otherAuth, updateRequest{Subdomain: other.Subdomain, TXT: "short"}, http.StatusBadRequest, "bad_txt"},
+		{"malformed", otherAuth, "invalid", http.StatusBadRequest, "malformed_json_payload"},
+	}
+	for _, tt := range tests {
+		w := request(t, h, "/update", tt.headers, tt.body)
+		assert.Equal(t, tt.code, w.Code, tt.name)
+		assert.JSONEq(t, `{"error":"`+tt.err+`"}`, w.Body.String(), tt.name)
+	}
+
+	// Zone not found.
+	s.Domain = "auth.example.net"
+	w = request(t, h, "/update", otherAuth, updateRequest{Subdomain: other.Subdomain, TXT: txt1})
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	// Registration disabled.
+	s.DisableRegistration = true
+	w = request(t, h, "/register", nil, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestAllowed(t *testing.T) {
+	assert.True(t, allowed(nil, "192.0.2.10:1234"))
+	assert.True(t, allowed([]string{"192.0.2.0/24"}, "192.0.2.10:1234"))
+	assert.True(t, allowed([]string{"2001:db8::/32"}, "[2001:db8::1]:1234"))
+	assert.False(t, allowed([]string{"198.51.100.0/24"}, "192.0.2.10:1234"))
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Registration represents an acme-dns account, allowed to update the TXT
+// record of its subdomain.
+type Registration struct {
+	Username     string   `json:"username"`
+	PasswordHash string   `json:"password_hash"` // SHA-256 of the random password
+	Subdomain    string   `json:"subdomain"`
+	AllowFrom    []string `json:"allowfrom,omitempty"` // CIDR ranges allowed to update
+	Values       []string `json:"values,omitempty"`    // last TXT values, two at most
+}
+
+// Store keeps registrations in a local JSON file.
+type Store struct {
+	filename string
+
+	mu            sync.Mutex
+	registrations map[string]*Registration // by username
+}
+
+// OpenStore reads registrations from a file, the file is created on the
+// first registration. With an empty filename registrations are kept in memory.
+func OpenStore(filename string) (*Store, error) {
+	s := &Store{filename: filename, registrations: map[string]*Registration{}}
+	if filename == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var registrations []*Registration
+	if err := json.Unmarshal(data, &registrations); err != nil {
+		return nil, err
+	}
+	for _, r := range registrations {
+		s.registrations[r.Username] = r
+	}
+	return s, nil
+}
+
+// Add saves a new registration.
+func (s *Store) Add(r *Registration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.registrations[r.Username] = r
+	if err := s.save(); err != nil {
+		delete(s.registrations, r.Username)
+		return err
+	}
+	return nil
+}
+
+// Authenticate returns a copy of the registration matching the credentials,
+// nil when they're invalid.
+func (s *Store) Authenticate(username, password string) *Registration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.registrations[username]
+	if !ok || subtle.ConstantTimeCompare([]byte(r.PasswordHash), []byte(hashPassword(password))) != 1 {
+		return nil
+	}
+
+	reg := *r
+	return &reg
+}
+
+// Values returns the last TXT values of a registration.
+func (s *Store) Values(username string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.registrations[username]
+	if !ok {
+		return nil
+	}
+	return append([]string{}, r.Values...)
+}
+
+// SetValues saves the last TXT values of a registration.
+func (s *Store) SetValues(username string, values []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.registrations[username]
+	if !ok {
+		return errors.New("registration not found")
+	}
+
+	previous := r.Values
+	r.Values = values
+	if err := s.save(); err != nil {
+		r.Values = previous
+		return err
+	}
+	return nil
+}
+
+// save writes registrations atomically, the lock must be held.
+func (s *Store) save() error {
+	if s.filename == "" {
+		return nil
+	}
+
+	registrations := []*Registration{}
+	for _, r := range s.registrations {
+		registrations = append(registrations, r)
+	}
+	data, err := json.MarshalIndent(registrations, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.filename), filepath.Base(s.filename)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.filename)
+}
+
+// hashPassword returns the SHA-256 of a password, passwords are random so
+// they don't need a slow hash.
+func hashPassword(password string) string {
+	sum := sha256.Sum256([]byte(password))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,167 @@
+// Command luadns-ddns is a dynamic DNS updater for LuaDNS.
+//
+// It finds the current public IPv4/IPv6 addresses, from an HTTP echo endpoint
+// or from local interfaces, and updates the A/AAAA records of the hosts when
+// they change. Addresses are checked every -interval, use -once to run a
+// single check from cron. Credentials are read from LUADNS_API_USERNAME and
+// LUADNS_API_TOKEN.
+//
+//	luadns-ddns -host home.example.org -ipv6 -state /var/lib/luadns-ddns.json
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	api "github.com/luadns/luadns-go"
+)
+
+const (
+	baseURL = "https://api.luadns.com/v1"
+
+	minBackoff = 30 * time.Second
+)
+
+// stringsFlag represents a repeatable flag.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(s string) error {
+	*f = append(*f, s)
+	return nil
+}
+
+var (
+	url        string
+	hosts      stringsFlag
+	ttl        uint
+	ipv4       bool
+	ipv6       bool
+	source     string
+	iface      string
+	ipv4URL    string
+	ipv6URL    string
+	stateFile  string
+	maxAge     time.Duration
+	interval   time.Duration
+	maxBackoff time.Duration
+	once       bool
+)
+
+func main() {
+	flag.StringVar(&url, "url", baseURL, "base URL")
+	flag.Var(&hosts, "host", "hostname to update (repeatable)")
+	flag.UintVar(&ttl, "ttl", 300, "record TTL")
+	flag.BoolVar(&ipv4, "ipv4", true, "update A records")
+	flag.BoolVar(&ipv6, "ipv6", false, "update AAAA records")
+	flag.StringVar(&source, "source", "http", "address source: http or interface")
+	flag.StringVar(&iface, "interface", "", "network interface, all interfaces when empty")
+	flag.StringVar(&ipv4URL, "ipv4-url", "https://api.ipify.org", "IPv4 echo endpoint")
+	flag.StringVar(&ipv6URL, "ipv6-url", "https://api6.ipify.org", "IPv6 echo endpoint")
+	flag.StringVar(&stateFile, "state", "", "state file caching published addresses")
+	flag.DurationVar(&maxAge, "max-age", 24*time.Hour, "check live records again after this delay, even when addresses are unchanged")
+	flag.DurationVar(&interval, "interval", 5*time.Minute, "delay between checks")
+	flag.DurationVar(&maxBackoff, "max-backoff", 30*time.Minute, "maximum delay between checks after errors")
+	flag.BoolVar(&once, "once", false, "check once and exit (for cron)")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [options]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	email, key := os.Getenv("LUADNS_API_USERNAME"), os.Getenv("LUADNS_API_TOKEN")
+	if email == "" || key == "" {
+		log.Fatalln("LUADNS_API_USERNAME and LUADNS_API_TOKEN are required")
+	}
+	if len(hosts) == 0 {
+		log.Fatalln("at least one -host is required")
+	}
+
+	state, err := LoadState(stateFile)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	c := api.NewClient(email, key, api.SetBaseURL(url))
+	c.UserAgent("luadns-ddns")
+	u := &Updater{Client: c, Hosts: hosts, TTL: uint32(ttl), State: state, MaxAge: maxAge}
+
+	switch source {
+	case "http":
+		if ipv4 {
+			u.IPv4 = &HTTPSource{URL: ipv4URL, Network: "tcp4"}
+		}
+		if ipv6 {
+			u.IPv6 = &HTTPSource{URL: ipv6URL, Network: "tcp6"}
+		}
+	case "interface":
+		if ipv4 {
+			u.IPv4 = &InterfaceSource{Interface: iface}
+		}
+		if ipv6 {
+			u.IPv6 = &InterfaceSource{Interface: iface, IPv6: true}
+		}
+	default:
+		log.Fatalf("unknown address source %q\n", source)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if once {
+		if err := update(ctx, u); err != nil {
+			log.Fatalln(err)
+		}
+		return
+	}
+
+	var backoff time.Duration
+	for {
+		wait := interval
+		if err := update(ctx, u); err != nil {
+			log.Println(err)
+			backoff = nextBackoff(backoff, maxBackoff)
+			wait = backoff
+		} else {
+			backoff = 0
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// update runs a check and logs updated records.
+func update(ctx context.Context, u *Updater) error {
+	records, err := u.Update(ctx)
+	for _, r := range records {
+		log.Println("Updated", r.Name, r.Type, r.Content)
+	}
+	return err
+}
+
+// nextBackoff doubles the delay after an error, from minBackoff up to `max`.
+func nextBackoff(d, max time.Duration) time.Duration {
+	if d < minBackoff {
+		d = minBackoff
+	} else {
+		d *= 2
+	}
+	if max > 0 && d > max {
+		d = max
+	}
+	return d
+}
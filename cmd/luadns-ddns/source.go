@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// Source finds the current public address of an address family.
+type Source interface {
+	Addr(ctx context.Context) (netip.Addr, error)
+}
+
+// HTTPSource reads the public address from an HTTP echo endpoint answering
+// with the client address as plain text, such as https://api.ipify.org.
+type HTTPSource struct {
+	URL     string
+	Network string // "tcp4" or "tcp6" to force the address family, any when empty
+	Client  *http.Client
+}
+
+// Addr fetches the echo endpoint and parses its response.
+func (s *HTTPSource) Addr(ctx context.Context) (netip.Addr, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+
+	resp, err := s.client().Do(req)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return netip.Addr{}, fmt.Errorf("%s: unexpected status %s", s.URL, resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 256))
+	if err != nil {
+		return netip.Addr{}, err
+	}
+
+	addr, err := netip.ParseAddr(strings.TrimSpace(string(body)))
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("%s: %w", s.URL, err)
+	}
+	return addr.Unmap(), nil
+}
+
+func (s *HTTPSource) client() *http.Client {
+	if s.Client != nil {
+		return s.Client
+	}
+
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if s.Network != "" {
+			network = s.Network
+		}
+		return dialer.DialContext(ctx, network, addr)
+	}
+	s.Client = &http.Client{Transport: transport, Timeout: 30 * time.Second}
+	return s.Client
+}
+
+// InterfaceSource reads the public address from local network interfaces,
+// it's useful when the host is directly connected (no NAT).
+type InterfaceSource struct {
+	Interface string // interface name, all interfaces when empty
+	IPv6      bool   // find an IPv6 address instead of an IPv4 address
+}
+
+// Addr returns the first public address of the interfaces.
+func (s *InterfaceSource) Addr(ctx context.Context) (netip.Addr, error) {
+	var ifaces []net.Interface
+	if s.Interface != "" {
+		iface, err := net.InterfaceByName(s.Interface)
+		if err != nil {
+			return netip.Addr{}, err
+		}
+		ifaces = []net.Interface{*iface}
+	} else {
+		var err error
+		if ifaces, err = net.Interfaces(); err != nil {
+			return netip.Addr{}, err
+		}
+	}
+
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			return netip.Addr{}, err
+		}
+		if addr, ok := publicAddr(addrs, s.IPv6); ok {
+			return addr, nil
+		}
+	}
+
+	family := "IPv4"
+	if s.IPv6 {
+		family = "IPv6"
+	}
+	return netip.Addr{}, fmt.Errorf("no public %s address found on interfaces", family)
+}
+
+// publicAddr returns the first public address of the family, loopback,
+// link-local and private (RFC 1918, RFC 4193) addresses are skipped.
+func publicAddr(addrs []net.Addr, ipv6 bool) (netip.Addr, bool) {
+	for _, a := range addrs {
+		prefix, err := netip.ParsePrefix(a.String())
+		if err != nil {
+			continue
+		}
+
+		addr := prefix.Addr().Unmap()
+		if addr.Is6() != ipv6 || !addr.IsGlobalUnicast() || addr.IsPrivate() {
+			continue
+		}
+		return addr, true
+	}
+	return netip.Addr{}, false
+}
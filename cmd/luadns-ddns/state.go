@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// State caches the addresses published for every host, so the API isn't
+// called while the addresses don't change.
+type State struct {
+	Records   map[string]string `json:"records"` // address by "name type"
+	UpdatedAt time.Time         `json:"updated_at"`
+
+	filename string
+}
+
+// LoadState reads the state from a file, an empty state is returned when the
+// file doesn't exist. With an empty filename the state is kept in memory.
+func LoadState(filename string) (*State, error) {
+	s := &State{Records: map[string]string{}, filename: filename}
+	if filename == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	if s.Records == nil {
+		s.Records = map[string]string{}
+	}
+	return s, nil
+}
+
+// Save writes the state file atomically.
+func (s *State) Save() error {
+	if s.filename == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.filename), filepath.Base(s.filename)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.filename)
+}
+
+// Current reports whether `addrs` (address by record type) were published
+// for every host less than `maxAge` ago, no limit when zero.
+func (s *State) Current(hosts []string, addrs map[string]string, maxAge time.Duration) bool {
+	if s.UpdatedAt.IsZero() || (maxAge > 0 && time.Since(s.UpdatedAt) >= maxAge) {
+		return false
+	}
+
+	for _, host := range hosts {
+		for typ, addr := range addrs {
+			if s.Records[stateKey(host, typ)] != addr {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Set records the addresses published for the hosts.
+func (s *State) Set(hosts []string, addrs map[string]string) {
+	for _, host := range hosts {
+		for typ, addr := range addrs {
+			s.Records[stateKey(host, typ)] = addr
+		}
+	}
+	s.UpdatedAt = time.Now().UTC()
+}
+
+func stateKey(host, typ string) string {
+	return strings.ToLower(host) + " " + typ
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"strings"
+	"time"
+
+	api "github.com/luadns/luadns-go"
+)
+
+// Updater keeps the A/AAAA records of hosts pointing to the current public
+// addresses.
+type Updater struct {
+	Client *api.Client
+	Hosts  []string
+	TTL    uint32
+	IPv4   Source        // IPv4 address source, A records are left unchanged when nil
+	IPv6   Source        // IPv6 address source, AAAA records are left unchanged when nil
+	State  *State        // published addresses
+	MaxAge time.Duration // live records are checked again after MaxAge, even when unchanged
+}
+
+// Update detects the current addresses and updates hosts having different
+// A/AAAA records, one UpdateManyRecords call per zone. The API is only
+// called when the addresses differ from the state.
+//
+// The updated records are returned.
+func (u *Updater) Update(ctx context.Context) ([]*api.Record, error) {
+	addrs, err := u.detect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.State == nil {
+		u.State = &State{Records: map[string]string{}}
+	}
+	if u.State.Current(u.Hosts, addrs, u.MaxAge) {
+		return nil, nil
+	}
+
+	zones, err := u.Client.ListAllZones(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var order []*api.Zone
+	hosts := map[*api.Zone][]string{}
+	for _, host := range u.Hosts {
+		z := api.FindZone(zones, host)
+		if z == nil {
+			return nil, fmt.Errorf("no zone found for %s", host)
+		}
+		if _, ok := hosts[z]; !ok {
+			order = append(order, z)
+		}
+		hosts[z] = append(hosts[z], host)
+	}
+
+	var updated []*api.Record
+	for _, z := range order {
+		records, err := u.Client.ListAllRecords(ctx, z)
+		if err != nil {
+			return updated, err
+		}
+
+		rrs := u.changes(hosts[z], records, addrs)
+		if len(rrs) == 0 {
+			continue
+		}
+
+		result, err := u.Client.UpdateManyRecords(ctx, z, rrs)
+		if err != nil {
+			return updated, err
+		}
+		updated = append(updated, result...)
+	}
+
+	u.State.Set(u.Hosts, addrs)
+	return updated, u.State.Save()
+}
+
+// detect returns the current address by record type.
+func (u *Updater) detect(ctx context.Context) (map[string]string, error) {
+	if u.IPv4 == nil && u.IPv6 == nil {
+		return nil, fmt.Errorf("no address source configured")
+	}
+
+	addrs := map[string]string{}
+	if u.IPv4 != nil {
+		addr, err := u.IPv4.Addr(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if !addr.Is4() {
+			return nil, fmt.Errorf("%s is not an IPv4 address", addr)
+		}
+		addrs[api.TypeA] = addr.String()
+	}
+	if u.IPv6 != nil {
+		addr, err := u.IPv6.Addr(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if !addr.Is6() {
+			return nil, fmt.Errorf("%s is not an IPv6 address", addr)
+		}
+		addrs[api.TypeAAAA] = addr.String()
+	}
+	return addrs, nil
+}
+
+// changes returns the record sets of `hosts` to replace, a set is kept when
+// it holds a single record with the current address and TTL.
+func (u *Updater) changes(hosts []string, records []*api.Record, addrs map[string]string) []*api.RR {
+	var rrs []*api.RR
+	for _, host := range hosts {
+		name := strings.ToLower(api.Fqdn(host))
+		for _, typ := range []string{api.TypeA, api.TypeAAAA} {
+			addr, ok := addrs[typ]
+			if !ok {
+				continue
+			}
+
+			var set []*api.Record
+			for _, r := range records {
+				if r.Type == typ && strings.EqualFold(api.Fqdn(r.Name), name) {
+					set = append(set, r)
+				}
+			}
+			if len(set) == 1 && sameAddr(set[0].Content, addr) && set[0].TTL == u.TTL {
+				continue
+			}
+			rrs = append(rrs, &api.RR{Name: name, Type: typ, Content: addr, TTL: u.TTL})
+		}
+	}
+	return rrs
+}
+
+// sameAddr compares addresses ignoring their textual form.
+func sameAddr(a, b string) bool {
+	x, err := netip.ParseAddr(a)
+	if err != nil {
+		return false
+	}
+	y, err := netip.ParseAddr(b)
+	if err != nil {
+		return false
+	}
+	return x == y
+}
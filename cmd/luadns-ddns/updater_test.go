@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"path/filepath"
+	"testing"
+	"time"
+
+	api "github.com/luadns/luadns-go"
+	"github.com/luadns/luadns-go/internal/fakeapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func echoServer(addr *string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(*addr + "\n"))
+	}))
+}
+
+func TestUpdater(t *testing.T) {
+	server := fakeapi.New()
+	defer server.Close()
+
+	org := server.AddZone("example.org",
+		&api.Record{Name: "home.example.org.", Type: "A", Content: "1.1.1.1", TTL: 300},
+		&api.Record{Name: "home.example.org.", Type: "TXT", Content: "keep", TTL: 300},
+		&api.Record{Name: "nas.example.org.", Type: "A", Content: "2.2.2.2", TTL: 300},
+		&api.Record{Name: "nas.example.org.", Type: "AAAA", Content: "2001:db8:0:0::1", TTL: 300},
+	)
+
+	ip4, ip6 := "2.2.2.2", "2001:db8::1"
+	echo4, echo6 := echoServer(&ip4), echoServer(&ip6)
+	defer echo4.Close()
+	defer echo6.Close()
+
+	state, err := LoadState(filepath.Join(t.TempDir(), "state.json"))
+	assert.NoError(t, err)
+
+	u := &Updater{
+		Client: server.Client(),
+		Hosts:  []string{"home.example.org", "nas.example.org"},
+		TTL:    300,
+		IPv4:   &HTTPSource{URL: echo4.URL},
+		IPv6:   &HTTPSource{URL: echo6.URL},
+		State:  state,
+	}
+	ctx := context.Background()
+
+	// Only home.example.org differs.
+	updated, err := u.Update(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, updated, 2) {
+		assert.Equal(t, "home.example.org.", updated[0].Name)
+		assert.Equal(t, "2.2.2.2", updated[0].Content)
+		assert.Equal(t, "AAAA", updated[1].Type)
+	}
+
+	var contents []string
+	for _, r := range server.Records(org.ID)[3:] {
+		contents = append(contents, r.Name+" "+r.Type+" "+r.Content)
+	}
+	assert.ElementsMatch(t, []string{
+		"home.example.org. TXT keep",
+		"nas.example.org. A 2.2.2.2",
+		"nas.example.org. AAAA 2001:db8:0:0::1",
+		"home.example.org. A 2.2.2.2",
+		"home.example.org. AAAA 2001:db8::1",
+	}, contents)
+
+	// Unchanged addresses don't call the API.
+	server.ResetRequests()
+	updated, err = u.Update(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, updated)
+	assert.Empty(t, server.Requests())
+
+	// The state is reloaded from the file.
+	state, err = LoadState(state.filename)
+	assert.NoError(t, err)
+	assert.True(t, state.Current(u.Hosts, map[string]string{"A": "2.2.2.2", "AAAA": "2001:db8::1"}, 0))
+
+	// Changed addresses are updated with a single bulk call.
+	ip4 = "3.3.3.3"
+	updated, err = u.Update(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, updated, 2)
+	assert.Equal(t, []string{"GET /zones", "GET /zones/101/records", "PATCH /zones/101/records"}, server.Requests())
+
+	// Expired state checks live records again.
+	u.MaxAge = time.Nanosecond
+	server.ResetRequests()
+	updated, err = u.Update(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, updated)
+	assert.Equal(t, []string{"GET /zones", "GET /zones/101/records"}, server.Requests())
+}
+
+func TestUpdaterErrors(t *testing.T) {
+	server := fakeapi.New()
+	defer server.Close()
+	server.AddZone("example.org")
+
+	ip := "2001:db8::1"
+	echo := echoServer(&ip)
+	defer echo.Close()
+
+	u := &Updater{Client: server.Client(), Hosts: []string{"home.example.org"}, TTL: 300}
+	_, err := u.Update(context.Background())
+	assert.EqualError(t, err, "no address source configured")
+
+	u.IPv4 = &HTTPSource{URL: echo.URL}
+	_, err = u.Update(context.Background())
+	assert.EqualError(t, err, "2001:db8::1 is not an IPv4 address")
+
+	ip = "1.1.1.1"
+	u.Hosts = []string{"home.example.net"}
+	_, err = u.Update(context.Background())
+	assert.EqualError(t, err, "no zone found for home.example.net")
+
+	ip = "invalid"
+	_, err = u.Update(context.Background())
+	assert.ErrorContains(t, err, `ParseAddr("invalid")`)
+}
+
+func TestPublicAddr(t *testing.T) {
+	addrs := []net.Addr{
+		&net.IPNet{IP: net.ParseIP("127.0.0.1"), Mask: net.CIDRMask(8, 32)},
+		&net.IPNet{IP: net.ParseIP("192.168.1.10"), Mask: net.CIDRMask(24, 32)},
+		&net.IPNet{IP: net.ParseIP("fe80::1"), Mask: net.CIDRMask(64, 128)},
+		&net.IPNet{IP: net.ParseIP("fd00::1"), Mask: net.CIDRMask(64, 128)},
+		&net.IPNet{IP: net.ParseIP("2001:db8::1"), Mask: net.CIDRMask(64, 128)},
+		&net.IPNet{IP: net.ParseIP("203.0.113.5"), Mask: net.CIDRMask(24, 32)},
+	}
+
+	addr, ok := publicAddr(addrs, false)
+	assert.True(t, ok)
+	assert.Equal(t, netip.MustParseAddr("203.0.113.5"), addr)
+
+	addr, ok = publicAddr(addrs, true)
+	assert.True(t, ok)
+	assert.Equal(t, netip.MustParseAddr("2001:db8::1"), addr)
+
+	_, ok = publicAddr(addrs[:3], false)
+	assert.False(t, ok)
+}
+
+func TestNextBackoff(t *testing.T) {
+	assert.Equal(t, minBackoff, nextBackoff(0, time.Hour))
+	assert.Equal(t, 2*minBackoff, nextBackoff(minBackoff, time.Hour))
+	assert.Equal(t, time.Hour, nextBackoff(time.Hour, time.Hour))
+}
@@ -0,0 +1,124 @@
+// Command luadns-drift compares a desired state (zone spec, zone file or
+// snapshot) with the live LuaDNS account and reports differences.
+//
+// Exit codes: 0 no drift, 1 error, 2 drift detected.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	api "github.com/luadns/luadns-go"
+	"github.com/luadns/luadns-go/zonespec"
+)
+
+const (
+	baseURL = "https://api.luadns.com/v1"
+)
+
+// options represents the command line options.
+type options struct {
+	email      string
+	key        string
+	url        string
+	specFile   string
+	zoneFile   string
+	zoneName   string
+	snapFile   string
+	jsonOutput bool
+	ignoreTTL  bool
+	ignoreXtra bool
+}
+
+func main() {
+	os.Exit(run(context.Background(), os.Args, os.Stdout, os.Stderr))
+}
+
+// run runs the command with `args` (program name included) and returns the
+// exit code.
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	var o options
+	fs := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&o.email, "email", os.Getenv("LUADNS_EMAIL"), "your email address (LUADNS_EMAIL)")
+	fs.StringVar(&o.key, "key", os.Getenv("LUADNS_API_KEY"), "your API key (LUADNS_API_KEY)")
+	fs.StringVar(&o.url, "url", baseURL, "base URL")
+	fs.StringVar(&o.specFile, "spec", "", "desired state from a YAML/JSON zone spec")
+	fs.StringVar(&o.zoneFile, "zonefile", "", "desired state from a zone file (requires -zone)")
+	fs.StringVar(&o.zoneName, "zone", "", "zone name of the zone file")
+	fs.StringVar(&o.snapFile, "snapshot", "", "desired state from a snapshot")
+	fs.BoolVar(&o.jsonOutput, "json", false, "write the report as JSON")
+	fs.BoolVar(&o.ignoreTTL, "ignore-ttl", false, "don't compare record TTLs")
+	fs.BoolVar(&o.ignoreXtra, "ignore-extra-zones", false, "don't report zones missing from the desired state")
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: %s [options]\n", args[0])
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return api.DriftExitError
+	}
+
+	desired, err := load(&o)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return api.DriftExitError
+	}
+
+	opts := &api.DriftOptions{IgnoreTTL: o.ignoreTTL, IgnoreExtraZones: o.ignoreXtra || o.zoneFile != ""}
+
+	c := api.NewClient(o.email, o.key, api.SetBaseURL(o.url))
+	report, err := c.DetectDrift(ctx, desired, opts)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return api.DriftExitError
+	}
+
+	if o.jsonOutput {
+		err = report.WriteJSON(stdout)
+	} else {
+		err = report.WriteText(stdout)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return api.DriftExitError
+	}
+
+	return report.ExitCode()
+}
+
+// load reads the desired state from the selected source.
+func load(o *options) ([]*api.Zone, error) {
+	switch {
+	case o.specFile != "":
+		spec, err := zonespec.LoadFile(o.specFile)
+		if err != nil {
+			return nil, err
+		}
+		return spec.Build()
+	case o.zoneFile != "":
+		if o.zoneName == "" {
+			return nil, fmt.Errorf("-zonefile requires -zone")
+		}
+		f, err := os.Open(o.zoneFile)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		zone, err := api.ZoneFromZoneFile(f, o.zoneName, &api.ParseZoneFileOptions{Filename: o.zoneFile, IncludeAllowed: true})
+		if err != nil {
+			return nil, err
+		}
+		return []*api.Zone{zone}, nil
+	case o.snapFile != "":
+		s, err := api.ReadSnapshotFile(o.snapFile)
+		if err != nil {
+			return nil, err
+		}
+		return s.Zones, nil
+	}
+	return nil, fmt.Errorf("one of -spec, -zonefile or -snapshot is required")
+}
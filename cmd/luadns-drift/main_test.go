@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	api "github.com/luadns/luadns-go"
+	"github.com/luadns/luadns-go/internal/fakeapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func runDrift(t *testing.T, server *fakeapi.Server, content string, extra ...string) (int, string, string) {
+	filename := filepath.Join(t.TempDir(), "example.org.zone")
+	assert.NoError(t, os.WriteFile(filename, []byte(content), 0o644))
+
+	args := append([]string{"luadns-drift",
+		"-email", fakeapi.Email, "-key", fakeapi.APIKey, "-url", server.URL,
+		"-zonefile", filename, "-zone", "example.org",
+	}, extra...)
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRun(t *testing.T) {
+	server := fakeapi.New()
+	defer server.Close()
+
+	server.AddZone("example.org", &api.Record{Name: "www.example.org.", Type: "A", Content: "1.1.1.1", TTL: 300})
+
+	code, stdout, stderr := runDrift(t, server, "$ORIGIN example.org.\nwww 300 IN A 1.1.1.1\n")
+	assert.Equal(t, api.DriftExitOK, code)
+	assert.Equal(t, "No drift detected.\n", stdout)
+	assert.Empty(t, stderr)
+
+	code, stdout, stderr = runDrift(t, server, "$ORIGIN example.org.\nwww 300 IN A 2.2.2.2\n")
+	assert.Equal(t, api.DriftExitDrift, code)
+	assert.Equal(t, "Zone example.org:\n  changed  www.example.org. 300 IN A 1.1.1.1 -> www.example.org. 300 IN A 2.2.2.2\n", stdout)
+	assert.Empty(t, stderr)
+
+	code, stdout, _ = runDrift(t, server, "$ORIGIN example.org.\nwww 300 IN A 2.2.2.2\n", "-json")
+	assert.Equal(t, api.DriftExitDrift, code)
+	assert.Contains(t, stdout, `"zone": "example.org"`)
+}
+
+func TestRunError(t *testing.T) {
+	server := fakeapi.New()
+	defer server.Close()
+
+	server.Fail = func(r *http.Request) int { return http.StatusInternalServerError }
+	code, stdout, stderr := runDrift(t, server, "$ORIGIN example.org.\nwww 300 IN A 1.1.1.1\n")
+	assert.Equal(t, api.DriftExitError, code)
+	assert.Empty(t, stdout)
+	assert.NotEmpty(t, stderr)
+
+	// The desired state is required.
+	var out, errOut bytes.Buffer
+	code = run(context.Background(), []string{"luadns-drift", "-url", server.URL}, &out, &errOut)
+	assert.Equal(t, api.DriftExitError, code)
+	assert.Equal(t, "one of -spec, -zonefile or -snapshot is required\n", errOut.String())
+}
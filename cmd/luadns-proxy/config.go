@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	api "github.com/luadns/luadns-go"
+	"gopkg.in/yaml.v3"
+)
+
+// Config represents the proxy configuration file (YAML or JSON).
+//
+//	tokens:
+//	  - name: team-a
+//	    token: 9d1f3c...
+//	    zones: [example.org]
+//	    names: ["*.team-a.example.org"]
+//	    types: [A, AAAA, CNAME, TXT]
+//	    max_ttl: 3600
+//	    allow_delete: true
+type Config struct {
+	Tokens []*Token `yaml:"tokens" json:"tokens"`
+}
+
+// Token represents a proxy token and the policy enforced for its calls.
+type Token struct {
+	Name        string   `yaml:"name" json:"name"`
+	Token       string   `yaml:"token" json:"token"`
+	Zones       []string `yaml:"zones,omitempty" json:"zones,omitempty"`
+	Names       []string `yaml:"names,omitempty" json:"names,omitempty"`
+	Types       []string `yaml:"types,omitempty" json:"types,omitempty"`
+	MaxTTL      uint32   `yaml:"max_ttl,omitempty" json:"max_ttl,omitempty"`
+	AllowDelete bool     `yaml:"allow_delete,omitempty" json:"allow_delete,omitempty"`
+}
+
+// Policy returns the ScopedClient policy of the token.
+func (t *Token) Policy() api.Policy {
+	return api.Policy{Zones: t.Zones, Names: t.Names, Types: t.Types, MaxTTL: t.MaxTTL, AllowDelete: t.AllowDelete}
+}
+
+// LoadConfigFile loads and validates a configuration file.
+func LoadConfigFile(filename string) (*Config, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return LoadConfig(f)
+}
+
+// LoadConfig loads and validates a configuration.
+func LoadConfig(r io.Reader) (*Config, error) {
+	var config Config
+	if err := yaml.NewDecoder(r).Decode(&config); err != nil {
+		return nil, err
+	}
+
+	if len(config.Tokens) == 0 {
+		return nil, fmt.Errorf("no tokens configured")
+	}
+	names := map[string]bool{}
+	for i, t := range config.Tokens {
+		if t.Name == "" || t.Token == "" {
+			return nil, fmt.Errorf("token %d: name and token are required", i+1)
+		}
+		if names[t.Name] {
+			return nil, fmt.Errorf("token %s: duplicate name", t.Name)
+		}
+		names[t.Name] = true
+	}
+	return &config, nil
+}
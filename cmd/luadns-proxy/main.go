@@ -0,0 +1,64 @@
+// Command luadns-proxy is a multi-tenant proxy for the LuaDNS REST API.
+//
+// It exposes the API paths (/zones, /zones/{id}/records, ...) to callers
+// authenticated with proxy tokens, enforces per-token zone and record
+// policies and forwards allowed calls with the real credentials, read from
+// LUADNS_API_USERNAME and LUADNS_API_TOKEN. All tokens share one upstream
+// requests budget.
+//
+//	luadns-proxy -config tokens.yaml -listen :8080 -rate-limit 1200 -rate-window 5m
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	api "github.com/luadns/luadns-go"
+)
+
+const (
+	baseURL = "https://api.luadns.com/v1"
+)
+
+var (
+	url        string
+	listen     string
+	configFile string
+	rateLimit  int
+	rateWindow time.Duration
+)
+
+func main() {
+	flag.StringVar(&url, "url", baseURL, "base URL")
+	flag.StringVar(&listen, "listen", ":8080", "HTTP listen address")
+	flag.StringVar(&configFile, "config", "luadns-proxy.yaml", "tokens configuration file (YAML or JSON)")
+	flag.IntVar(&rateLimit, "rate-limit", 0, "upstream requests per window shared by all tokens, unlimited when zero")
+	flag.DurationVar(&rateWindow, "rate-window", 5*time.Minute, "upstream requests window")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [options]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	email, key := os.Getenv("LUADNS_API_USERNAME"), os.Getenv("LUADNS_API_TOKEN")
+	if email == "" || key == "" {
+		log.Fatalln("LUADNS_API_USERNAME and LUADNS_API_TOKEN are required")
+	}
+
+	config, err := LoadConfigFile(configFile)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	budget := &Budget{Limit: rateLimit, Window: rateWindow}
+	c := api.NewClient(email, key, api.SetBaseURL(url), api.WrapTransport(budget.Transport))
+	c.UserAgent("luadns-proxy")
+	p := &Proxy{Client: c, Tokens: config.Tokens}
+
+	log.Println("Listening on", listen)
+	log.Fatalln(http.ListenAndServe(listen, p))
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	api "github.com/luadns/luadns-go"
+)
+
+// maxBodySize is the maximum size of request bodies.
+const maxBodySize = 1 << 20
+
+// Proxy serves the LuaDNS REST API paths, calls are authenticated with proxy
+// tokens, checked against the token policy and forwarded with the real
+// credentials of Client. Callers use Basic authentication with the token name
+// as email and the token as API key:
+//
+//	c := luadns.NewClient("team-a", token, luadns.SetBaseURL("http://proxy:8080"))
+//
+// Zone changes and user endpoints aren't available through the proxy.
+type Proxy struct {
+	Client *api.Client // upstream client, its requests are limited by a Budget transport
+	Tokens []*Token
+
+	once    sync.Once
+	clients map[string]*api.ScopedClient // by token name
+}
+
+// Budget represents the upstream requests budget, a token bucket holding
+// Limit requests and refilled over Window. Once the upstream API reports the
+// quota was exceeded, calls are rejected until the reset time.
+//
+// The budget is charged for every upstream request, proxied calls checking
+// zones or records make several of them. It's installed on the upstream
+// client with:
+//
+//	api.NewClient(email, key, api.WrapTransport(budget.Transport))
+type Budget struct {
+	Limit  int           // requests per window, unlimited when zero
+	Window time.Duration // refill period
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+	until  time.Time // upstream quota reset
+}
+
+// take reserves a request, the reset time is returned when the budget is exhausted.
+func (b *Budget) take(now time.Time) (time.Time, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if now.Before(b.until) {
+		return b.until, false
+	}
+	if b.Limit <= 0 || b.Window <= 0 {
+		return time.Time{}, true
+	}
+
+	rate := float64(b.Limit) / b.Window.Seconds()
+	if b.last.IsZero() {
+		b.tokens = float64(b.Limit)
+	} else {
+		b.tokens += now.Sub(b.last).Seconds() * rate
+		if b.tokens > float64(b.Limit) {
+			b.tokens = float64(b.Limit)
+		}
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / rate * float64(time.Second))
+		return now.Add(wait), false
+	}
+	b.tokens--
+	return time.Time{}, true
+}
+
+// Transport returns a http.RoundTripper charging `next` requests to the budget.
+func (b *Budget) Transport(next http.RoundTripper) http.RoundTripper {
+	return &budgetTransport{budget: b, next: next}
+}
+
+// errBudgetExceeded is returned by upstream requests once the budget is exhausted.
+type errBudgetExceeded struct {
+	limit int
+	reset time.Time
+}
+
+func (e *errBudgetExceeded) Error() string {
+	return "upstream requests budget exceeded, retry at " + e.reset.Format(time.RFC3339)
+}
+
+type budgetTransport struct {
+	budget *Budget
+	next   http.RoundTripper
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *budgetTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if reset, ok := t.budget.take(time.Now()); !ok {
+		return nil, &errBudgetExceeded{limit: t.budget.Limit, reset: reset}
+	}
+
+	resp, err := t.next.RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		if reset, err := strconv.ParseInt(resp.Header.Get("X-Ratelimit-Reset"), 10, 64); err == nil {
+			t.budget.exceeded(time.Unix(reset, 0))
+		}
+	}
+	return resp, nil
+}
+
+// exceeded records the upstream quota reset time.
+func (b *Budget) exceeded(reset time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if reset.After(b.until) {
+		b.until = reset
+	}
+}
+
+// ServeHTTP implements http.Handler.
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.once.Do(func() {
+		p.clients = map[string]*api.ScopedClient{}
+		for _, t := range p.Tokens {
+			p.clients[t.Name] = api.NewScopedClient(p.Client, t.Policy())
+		}
+	})
+
+	name, c := p.authenticate(r)
+	if c == nil {
+		writeError(w, &api.ForbiddenRequestError{Status: "Forbidden", Message: "invalid credentials"})
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+	v, err := p.route(r, c)
+	if err != nil {
+		var violation *api.ErrPolicyViolation
+		if errors.As(err, &violation) {
+			log.Println(name+":", err)
+		}
+		writeError(w, err)
+		return
+	}
+
+	if list, ok := v.(listResponse); ok {
+		writeList(w, r, list)
+		return
+	}
+	writeJSON(w, http.StatusOK, v)
+}
+
+// authenticate returns the client of the token matching the credentials.
+func (p *Proxy) authenticate(r *http.Request) (string, *api.ScopedClient) {
+	name, token, ok := r.BasicAuth()
+	if !ok {
+		return "", nil
+	}
+
+	for _, t := range p.Tokens {
+		if t.Name == name && subtle.ConstantTimeCompare([]byte(t.Token), []byte(token)) == 1 {
+			return name, p.clients[name]
+		}
+	}
+	return "", nil
+}
+
+// listResponse represents a list endpoint response, it's paginated.
+type listResponse struct {
+	n     int
+	slice func(i, j int) any
+}
+
+// errNotFound is returned for unknown paths.
+var errNotFound = &api.ErrBadStatusCode{StatusCode: http.StatusNotFound}
+
+// route calls the ScopedClient method matching the request.
+func (p *Proxy) route(r *http.Request, c *api.ScopedClient) (any, error) {
+	ctx := r.Context()
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if parts[0] != "zones" {
+		return nil, errNotFound
+	}
+
+	if len(parts) == 1 {
+		if r.Method != http.MethodGet {
+			return nil, &api.ForbiddenRequestError{Status: "Forbidden", Message: "zone changes are not allowed"}
+		}
+		zones, err := c.ListAllZones(ctx)
+		return listResponse{len(zones), func(i, j int) any { return zones[i:j] }}, err
+	}
+
+	zoneID, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, errNotFound
+	}
+	zone := &api.Zone{ID: zoneID}
+
+	switch {
+	case len(parts) == 2:
+		if r.Method != http.MethodGet {
+			return nil, &api.ForbiddenRequestError{Status: "Forbidden", Message: "zone changes are not allowed"}
+		}
+		return c.GetZone(ctx, zoneID)
+
+	case len(parts) == 3 && parts[2] == "records":
+		switch r.Method {
+		case http.MethodGet:
+			records, err := c.ListAllRecords(ctx, zone)
+			return listResponse{len(records), func(i, j int) any { return records[i:j] }}, err
+		case http.MethodPost:
+			var attrs api.Record
+			if err := readJSON(r, &attrs); err != nil {
+				return nil, err
+			}
+			return c.CreateRecord(ctx, zone, &attrs)
+		case http.MethodPatch:
+			var rrs []*api.RR
+			if err := readJSON(r, &rrs); err != nil {
+				return nil, err
+			}
+			return c.UpdateManyRecords(ctx, zone, rrs)
+		}
+
+	case len(parts) == 4 && parts[2] == "records" && (parts[3] == "create_many" || parts[3] == "delete_many"):
+		if r.Method != http.MethodPost {
+			break
+		}
+		var rrs []*api.RR
+		if err := readJSON(r, &rrs); err != nil {
+			return nil, err
+		}
+		if parts[3] == "create_many" {
+			return c.CreateManyRecords(ctx, zone, rrs)
+		}
+		return c.DeleteManyRecords(ctx, zone, rrs)
+
+	case len(parts) == 4 && parts[2] == "records":
+		recordID, err := strconv.ParseInt(parts[3], 10, 64)
+		if err != nil {
+			return nil, errNotFound
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			return c.GetRecord(ctx, zone, recordID)
+		case http.MethodPut:
+			var attrs api.Record
+			if err := readJSON(r, &attrs); err != nil {
+				return nil, err
+			}
+			return c.UpdateRecord(ctx, zone, recordID, &attrs)
+		case http.MethodDelete:
+			return c.DeleteRecord(ctx, zone, recordID)
+		}
+	}
+
+	return nil, errNotFound
+}
+
+func readJSON(r *http.Request, dest any) error {
+	if err := json.NewDecoder(r.Body).Decode(dest); err != nil {
+		return &api.BadRequestError{{Classification: "DeserializationError", Message: err.Error()}}
+	}
+	return nil
+}
+
+// writeList writes a list following the API pagination (page and limit
+// parameters, X-* headers).
+func writeList(w http.ResponseWriter, r *http.Request, list listResponse) {
+	n := list.n
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 {
+		limit = n
+	}
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+
+	pages := 1
+	if limit > 0 {
+		pages = (n + limit - 1) / limit
+	}
+	i, j := (page-1)*limit, page*limit
+	if i > n {
+		i = n
+	}
+	if j > n {
+		j = n
+	}
+
+	w.Header().Set("X-Page", strconv.Itoa(page))
+	w.Header().Set("X-Limit", strconv.Itoa(limit))
+	w.Header().Set("X-Total-Count", strconv.Itoa(n))
+	w.Header().Set("X-Pages-Count", strconv.Itoa(pages))
+	writeJSON(w, http.StatusOK, list.slice(i, j))
+}
+
+// writeError writes an error the way the API does, so callers get the same
+// error types.
+func writeError(w http.ResponseWriter, err error) {
+	var (
+		badRequest *api.BadRequestError
+		forbidden  *api.ForbiddenRequestError
+		violation  *api.ErrPolicyViolation
+		tooMany    *api.ErrTooManyRequests
+		budget     *errBudgetExceeded
+		badStatus  *api.ErrBadStatusCode
+	)
+
+	switch {
+	case errors.As(err, &badRequest):
+		writeJSON(w, http.StatusBadRequest, badRequest)
+	case errors.As(err, &forbidden):
+		writeJSON(w, http.StatusForbidden, forbidden)
+	case errors.As(err, &violation):
+		writeJSON(w, http.StatusForbidden, &api.ForbiddenRequestError{Status: "Forbidden", Message: violation.Error()})
+	case errors.As(err, &tooMany):
+		writeTooManyRequests(w, int(tooMany.Limit), time.Unix(tooMany.Reset, 0))
+	case errors.As(err, &budget):
+		writeTooManyRequests(w, budget.limit, budget.reset)
+	case errors.As(err, &badStatus):
+		writeJSON(w, badStatus.StatusCode, map[string]string{"status": http.StatusText(badStatus.StatusCode), "message": err.Error()})
+	default:
+		log.Println(err)
+		writeJSON(w, http.StatusBadGateway, map[string]string{"status": "Bad Gateway", "message": "upstream request failed"})
+	}
+}
+
+func writeTooManyRequests(w http.ResponseWriter, limit int, reset time.Time) {
+	w.Header().Set("X-Ratelimit-Limit", strconv.Itoa(limit))
+	// Round up, callers wait until the reset second.
+	w.Header().Set("X-Ratelimit-Reset", strconv.FormatInt(reset.Add(time.Second-1).Unix(), 10))
+	writeJSON(w, http.StatusTooManyRequests, map[string]string{})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
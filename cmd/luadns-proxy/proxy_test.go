@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	api "github.com/luadns/luadns-go"
+	"github.com/luadns/luadns-go/internal/fakeapi"
+	"github.com/stretchr/testify/assert"
+)
+
+const config = `
+tokens:
+  - name: team-a
+    token: token-a
+    zones: [example.org]
+    names: ["*.team-a.example.org"]
+    types: [A, TXT]
+    max_ttl: 3600
+    allow_delete: true
+  - name: team-b
+    token: token-b
+    zones: [example.net]
+`
+
+func startProxy(t *testing.T, upstream *fakeapi.Server, budget *Budget) *httptest.Server {
+	c, err := LoadConfig(strings.NewReader(config))
+	assert.NoError(t, err)
+
+	client := upstream.Client()
+	if budget != nil {
+		client = upstream.Client(api.WrapTransport(budget.Transport))
+	}
+	return httptest.NewServer(&Proxy{Client: client, Tokens: c.Tokens})
+}
+
+func TestProxy(t *testing.T) {
+	upstream := fakeapi.New()
+	defer upstream.Close()
+
+	org := upstream.AddZone("example.org",
+		&api.Record{Name: "www.example.org.", Type: "A", Content: "1.1.1.1", TTL: 300},
+		&api.Record{Name: "app.team-a.example.org.", Type: "A", Content: "2.2.2.2", TTL: 300},
+	)
+	upstream.AddZone("example.net")
+
+	proxy := startProxy(t, upstream, nil)
+	defer proxy.Close()
+
+	c := api.NewClient("team-a", "token-a", api.SetBaseURL(proxy.URL))
+	ctx := context.Background()
+
+	zones, err := c.ListAllZones(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, zones, 1) {
+		assert.Equal(t, "example.org", zones[0].Name)
+	}
+
+	records, err := c.ListAllRecords(ctx, org)
+	assert.NoError(t, err)
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, "app.team-a.example.org.", records[0].Name)
+	}
+
+	zone, err := c.GetZone(ctx, org.ID)
+	assert.NoError(t, err)
+	assert.Len(t, zone.Records, 1)
+
+	r, err := c.CreateRecord(ctx, org, &api.Record{Name: "api.team-a.example.org.", Type: "A", Content: "3.3.3.3", TTL: 300})
+	assert.NoError(t, err)
+
+	r, err = c.UpdateRecord(ctx, org, r.ID, &api.Record{Name: r.Name, Type: "A", Content: "4.4.4.4", TTL: 300})
+	assert.NoError(t, err)
+	assert.Equal(t, "4.4.4.4", r.Content)
+
+	_, err = c.UpdateManyRecords(ctx, org, []*api.RR{{Name: "api.team-a.example.org.", Type: "TXT", Content: "hello", TTL: 300}})
+	assert.NoError(t, err)
+
+	deleted, err := c.DeleteManyRecords(ctx, org, []*api.RR{{Name: "api.team-a.example.org.", Type: "TXT"}})
+	assert.NoError(t, err)
+	assert.Len(t, deleted, 1)
+
+	_, err = c.DeleteRecord(ctx, org, r.ID)
+	assert.NoError(t, err)
+
+	// Calls are forwarded with the real credentials.
+	assert.Contains(t, upstream.Requests(), "DELETE /zones/101/records/"+strconv.FormatInt(r.ID, 10))
+}
+
+func TestProxyErrors(t *testing.T) {
+	upstream := fakeapi.New()
+	defer upstream.Close()
+
+	org := upstream.AddZone("example.org",
+		&api.Record{Name: "www.example.org.", Type: "A", Content: "1.1.1.1", TTL: 300},
+	)
+	www := upstream.Records(org.ID)[3]
+
+	proxy := startProxy(t, upstream, nil)
+	defer proxy.Close()
+
+	ctx := context.Background()
+	c := api.NewClient("team-a", "token-a", api.SetBaseURL(proxy.URL))
+
+	// Invalid credentials.
+	_, err := api.NewClient("team-a", "token-b", api.SetBaseURL(proxy.URL)).ListAllZones(ctx)
+	assert.EqualError(t, err, "Forbidden: invalid credentials")
+
+	// Policy violations are reported as forbidden requests.
+	_, err = c.CreateRecord(ctx, org, &api.Record{Name: "www.example.org.", Type: "A", Content: "3.3.3.3", TTL: 300})
+	assert.IsType(t, &api.ForbiddenRequestError{}, err)
+	assert.EqualError(t, err, "Forbidden: Policy denies create record www.example.org. A in zone example.org: name not allowed")
+
+	_, err = c.DeleteRecord(ctx, org, www.ID)
+	assert.EqualError(t, err, "Forbidden: Policy denies delete record www.example.org. A in zone example.org: name not allowed")
+
+	_, err = api.NewClient("team-b", "token-b", api.SetBaseURL(proxy.URL)).ListAllRecords(ctx, org)
+	assert.EqualError(t, err, "Forbidden: Policy denies list records in zone example.org: zone not allowed")
+
+	// Zone changes aren't available.
+	_, err = c.CreateZone(ctx, &api.Zone{Name: "example.com"})
+	assert.EqualError(t, err, "Forbidden: zone changes are not allowed")
+
+	// Bulk record paths are matched exactly.
+	req, err := http.NewRequest(http.MethodPost, proxy.URL+"/zones/101/other/create_many", strings.NewReader("[]"))
+	assert.NoError(t, err)
+	req.SetBasicAuth("team-a", "token-a")
+	resp, err := http.DefaultClient.Do(req)
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	}
+
+	// Request bodies are limited.
+	upstream.ResetRequests()
+	body := `[{"name":"` + strings.Repeat("a", maxBodySize) + `"}]`
+	req, err = http.NewRequest(http.MethodPost, proxy.URL+"/zones/101/records/create_many", strings.NewReader(body))
+	assert.NoError(t, err)
+	req.SetBasicAuth("team-a", "token-a")
+	resp, err = http.DefaultClient.Do(req)
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	}
+	assert.Empty(t, upstream.Requests())
+
+	// Upstream errors keep their type.
+	_, err = c.CreateRecord(ctx, org, &api.Record{Name: "app.team-a.example.org.", Type: "A", Content: "invalid", TTL: 300})
+	assert.IsType(t, &api.BadRequestError{}, err)
+
+	_, err = c.GetRecord(ctx, org, 999)
+	assert.Equal(t, &api.ErrBadStatusCode{StatusCode: http.StatusNotFound}, err)
+}
+
+func TestProxyBudget(t *testing.T) {
+	upstream := fakeapi.New()
+	defer upstream.Close()
+	org := upstream.AddZone("example.org")
+
+	budget := &Budget{Limit: 3, Window: time.Hour}
+	proxy := startProxy(t, upstream, budget)
+	defer proxy.Close()
+
+	ctx := context.Background()
+	a := api.NewClient("team-a", "token-a", api.SetBaseURL(proxy.URL))
+	b := api.NewClient("team-b", "token-b", api.SetBaseURL(proxy.URL))
+
+	// Every upstream request is charged, the zone is resolved first.
+	_, err := a.GetZone(ctx, org.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"GET /zones", "GET /zones/101"}, upstream.Requests())
+
+	_, err = b.ListAllZones(ctx)
+	assert.NoError(t, err)
+
+	// The budget is shared by all tokens.
+	_, err = a.ListAllZones(ctx)
+	if assert.IsType(t, &api.ErrTooManyRequests{}, err) {
+		assert.Equal(t, int64(3), err.(*api.ErrTooManyRequests).Limit)
+		assert.Greater(t, err.(*api.ErrTooManyRequests).Reset, time.Now().Unix())
+	}
+	assert.Len(t, upstream.Requests(), 3)
+
+	// Upstream quota errors block all tokens until the reset.
+	budget = &Budget{}
+	reset := time.Now().Add(time.Hour)
+	budget.exceeded(reset)
+	_, ok := budget.take(time.Now())
+	assert.False(t, ok)
+	_, ok = budget.take(reset)
+	assert.True(t, ok)
+}
@@ -0,0 +1,420 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	api "github.com/luadns/luadns-go"
+	"github.com/luadns/luadns-go/propagation"
+	"github.com/miekg/dns"
+)
+
+// Gateway translates RFC 2136 DNS UPDATE messages to API calls.
+type Gateway struct {
+	Client *api.Client
+	Zones  []string // zones accepting updates, all zones when empty
+
+	// KeyZones restricts TSIG keys (fully qualified names) to zones, keys
+	// not listed can update every zone accepting updates.
+	KeyZones map[string][]string
+
+	mu    sync.Mutex
+	cache []*api.Zone            // account zones, refreshed on misses
+	locks map[string]*sync.Mutex // serialize updates per zone
+}
+
+// entry represents a record of the zone state, `rr` is nil for records which
+// can't be represented in DNS (ALIAS, FORWARD, ...).
+type entry struct {
+	record *api.Record // live record, nil for added records
+	rr     dns.RR
+}
+
+// AcceptUpdates is a dns.MsgAcceptFunc accepting UPDATE requests, the
+// default accept function rejects them.
+func AcceptUpdates(dh dns.Header) dns.MsgAcceptAction {
+	const qr = 1 << 15
+	if dh.Bits&qr == 0 && int(dh.Bits>>11)&0xF == dns.OpcodeUpdate {
+		return dns.MsgAccept
+	}
+	return dns.DefaultMsgAcceptFunc(dh)
+}
+
+// ServeDNS implements the dns.Handler interface.
+func (g *Gateway) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Rcode = g.update(w, r)
+
+	if t := r.IsTsig(); t != nil && w.TsigStatus() == nil {
+		m.SetTsig(t.Hdr.Name, t.Algorithm, 300, time.Now().Unix())
+	}
+	w.WriteMsg(m)
+}
+
+// update processes an UPDATE message and returns the response RCODE.
+func (g *Gateway) update(w dns.ResponseWriter, r *dns.Msg) int {
+	if r.Opcode != dns.OpcodeUpdate {
+		return dns.RcodeNotImplemented
+	}
+	if r.IsTsig() == nil {
+		return dns.RcodeRefused
+	}
+	if w.TsigStatus() != nil {
+		return dns.RcodeNotAuth
+	}
+	if len(r.Question) != 1 || r.Question[0].Qtype != dns.TypeSOA || r.Question[0].Qclass != dns.ClassINET {
+		return dns.RcodeFormatError
+	}
+
+	ctx := context.Background()
+	origin := strings.ToLower(dns.Fqdn(r.Question[0].Name))
+	if !g.allowed(r.IsTsig().Hdr.Name, origin) {
+		return dns.RcodeNotAuth
+	}
+
+	zone, err := g.zone(ctx, origin)
+	if err != nil {
+		log.Println(err)
+		return dns.RcodeServerFailure
+	}
+	if zone == nil {
+		return dns.RcodeNotAuth
+	}
+
+	// Updates of a zone are applied one at a time, each update is checked
+	// against the zone state left by the previous one.
+	lock := g.lock(origin)
+	lock.Lock()
+	defer lock.Unlock()
+
+	records, err := g.Client.ListAllRecords(ctx, zone)
+	if err != nil {
+		log.Println(err)
+		return dns.RcodeServerFailure
+	}
+
+	state := []*entry{}
+	for _, rec := range records {
+		rr, _ := toRR(rec)
+		state = append(state, &entry{record: rec, rr: rr})
+	}
+
+	if rcode := checkPrerequisites(origin, r.Answer, state); rcode != dns.RcodeSuccess {
+		return rcode
+	}
+	if rcode := prescan(origin, r.Ns); rcode != dns.RcodeSuccess {
+		return rcode
+	}
+
+	for _, rr := range r.Ns {
+		state = applyUpdate(origin, rr, state)
+	}
+
+	desired := []*api.Record{}
+	for _, e := range state {
+		if e.record != nil {
+			desired = append(desired, e.record)
+			continue
+		}
+		h := e.rr.Header()
+		desired = append(desired, &api.Record{Name: h.Name, Type: dns.TypeToString[h.Rrtype], Content: propagation.RRContent(e.rr), TTL: h.Ttl})
+	}
+
+	changes := api.Diff(desired, records, &api.DiffOptions{Zone: zone.Name})
+	if changes.Empty() {
+		return dns.RcodeSuccess
+	}
+	if _, err := g.Client.ApplyChanges(ctx, zone, changes); err != nil {
+		log.Println(err)
+		return dns.RcodeServerFailure
+	}
+	return dns.RcodeSuccess
+}
+
+// allowed reports whether updates of `origin` signed with the TSIG key `key`
+// are accepted.
+func (g *Gateway) allowed(key, origin string) bool {
+	if zones, ok := g.KeyZones[strings.ToLower(dns.Fqdn(key))]; ok && !containsZone(zones, origin) {
+		return false
+	}
+	return len(g.Zones) == 0 || containsZone(g.Zones, origin)
+}
+
+// containsZone reports whether `origin` is listed in `zones`.
+func containsZone(zones []string, origin string) bool {
+	for _, z := range zones {
+		if strings.EqualFold(dns.Fqdn(z), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// zone returns the zone named `origin`, nil when not found. Zones are cached,
+// the cache is refreshed when `origin` isn't found.
+func (g *Gateway) zone(ctx context.Context, origin string) (*api.Zone, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	find := func() *api.Zone {
+		for _, z := range g.cache {
+			if strings.EqualFold(api.Fqdn(z.Name), origin) {
+				return z
+			}
+		}
+		return nil
+	}
+
+	if zone := find(); zone != nil {
+		return zone, nil
+	}
+	zones, err := g.Client.ListAllZones(ctx)
+	if err != nil {
+		return nil, err
+	}
+	g.cache = zones
+	return find(), nil
+}
+
+// lock returns the mutex serializing updates of `origin`.
+func (g *Gateway) lock(origin string) *sync.Mutex {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.locks == nil {
+		g.locks = map[string]*sync.Mutex{}
+	}
+	if _, ok := g.locks[origin]; !ok {
+		g.locks[origin] = &sync.Mutex{}
+	}
+	return g.locks[origin]
+}
+
+// checkPrerequisites checks the prerequisite section (RFC 2136 section 3.2).
+func checkPrerequisites(origin string, prereqs []dns.RR, state []*entry) int {
+	valueDependent := map[string][]dns.RR{}
+	keys := []string{}
+
+	for _, rr := range prereqs {
+		h := rr.Header()
+		if !dns.IsSubDomain(origin, h.Name) {
+			return dns.RcodeNotZone
+		}
+		if h.Ttl != 0 {
+			return dns.RcodeFormatError
+		}
+		if (h.Class == dns.ClassANY || h.Class == dns.ClassNONE) && h.Rdlength != 0 {
+			return dns.RcodeFormatError
+		}
+
+		switch h.Class {
+		case dns.ClassANY:
+			if h.Rrtype == dns.TypeANY {
+				if !nameInUse(h.Name, state) {
+					return dns.RcodeNameError
+				}
+			} else if len(rrset(h.Name, h.Rrtype, state)) == 0 {
+				return dns.RcodeNXRrset
+			}
+		case dns.ClassNONE:
+			if h.Rrtype == dns.TypeANY {
+				if nameInUse(h.Name, state) {
+					return dns.RcodeYXDomain
+				}
+			} else if len(rrset(h.Name, h.Rrtype, state)) > 0 {
+				return dns.RcodeYXRrset
+			}
+		case dns.ClassINET:
+			key := setKey(h.Name, h.Rrtype)
+			if _, ok := valueDependent[key]; !ok {
+				keys = append(keys, key)
+			}
+			valueDependent[key] = append(valueDependent[key], rr)
+		default:
+			return dns.RcodeFormatError
+		}
+	}
+
+	// Value dependent prerequisites compare whole RRsets.
+	for _, key := range keys {
+		expected := valueDependent[key]
+		h := expected[0].Header()
+		if !sameRRset(expected, rrset(h.Name, h.Rrtype, state)) {
+			return dns.RcodeNXRrset
+		}
+	}
+
+	return dns.RcodeSuccess
+}
+
+// prescan validates the update section (RFC 2136 section 3.4.1).
+func prescan(origin string, updates []dns.RR) int {
+	for _, rr := range updates {
+		h := rr.Header()
+		if !dns.IsSubDomain(origin, h.Name) {
+			return dns.RcodeNotZone
+		}
+
+		switch h.Class {
+		case dns.ClassINET:
+			if h.Rrtype == dns.TypeANY || isMeta(h.Rrtype) {
+				return dns.RcodeFormatError
+			}
+		case dns.ClassANY:
+			if h.Ttl != 0 || h.Rdlength != 0 || (isMeta(h.Rrtype) && h.Rrtype != dns.TypeANY) {
+				return dns.RcodeFormatError
+			}
+		case dns.ClassNONE:
+			if h.Ttl != 0 || h.Rrtype == dns.TypeANY || isMeta(h.Rrtype) {
+				return dns.RcodeFormatError
+			}
+		default:
+			return dns.RcodeFormatError
+		}
+	}
+	return dns.RcodeSuccess
+}
+
+// applyUpdate applies an update RR to the zone state (RFC 2136 section 3.4.2).
+// SOA records and apex NS records are never changed.
+func applyUpdate(origin string, rr dns.RR, state []*entry) []*entry {
+	h := rr.Header()
+	protected := func(e *entry) bool {
+		t := e.rr.Header().Rrtype
+		return t == dns.TypeSOA || (t == dns.TypeNS && strings.EqualFold(e.rr.Header().Name, origin))
+	}
+
+	switch h.Class {
+	case dns.ClassINET:
+		if h.Rrtype == dns.TypeSOA {
+			return state
+		}
+		for _, e := range state {
+			if e.rr != nil && sameRR(e.rr, rr) {
+				return state
+			}
+		}
+		return append(state, &entry{rr: rr})
+	case dns.ClassANY:
+		kept := []*entry{}
+		for _, e := range state {
+			if e.rr == nil || !strings.EqualFold(e.rr.Header().Name, h.Name) || protected(e) ||
+				(h.Rrtype != dns.TypeANY && e.rr.Header().Rrtype != h.Rrtype) {
+				kept = append(kept, e)
+			}
+		}
+		return kept
+	case dns.ClassNONE:
+		kept := []*entry{}
+		for _, e := range state {
+			deleted := e.rr != nil && !protected(e) && sameRR(e.rr, rr)
+			if !deleted {
+				kept = append(kept, e)
+			}
+		}
+		return kept
+	}
+	return state
+}
+
+// nameInUse reports whether records exist at `name`.
+func nameInUse(name string, state []*entry) bool {
+	for _, e := range state {
+		if e.rr != nil && strings.EqualFold(e.rr.Header().Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// rrset returns the records of a (name, type) set.
+func rrset(name string, rrtype uint16, state []*entry) []dns.RR {
+	rrs := []dns.RR{}
+	for _, e := range state {
+		if e.rr != nil && e.rr.Header().Rrtype == rrtype && strings.EqualFold(e.rr.Header().Name, name) {
+			rrs = append(rrs, e.rr)
+		}
+	}
+	return rrs
+}
+
+// sameRR reports whether both records hold the same data, records are
+// compared as API records: TXT strings are joined, TTLs and classes are
+// ignored.
+func sameRR(a, b dns.RR) bool {
+	ha, hb := a.Header(), b.Header()
+	return ha.Rrtype == hb.Rrtype && strings.EqualFold(ha.Name, hb.Name) && propagation.RRContent(a) == propagation.RRContent(b)
+}
+
+// sameRRset reports whether both RRsets hold the same data, TTLs are ignored.
+func sameRRset(a, b []dns.RR) bool {
+	contains := func(rrs []dns.RR, rr dns.RR) bool {
+		for _, x := range rrs {
+			if sameRR(x, rr) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, rr := range a {
+		if !contains(b, rr) {
+			return false
+		}
+	}
+	for _, rr := range b {
+		if !contains(a, rr) {
+			return false
+		}
+	}
+	return true
+}
+
+func isMeta(rrtype uint16) bool {
+	switch rrtype {
+	case dns.TypeANY, dns.TypeAXFR, dns.TypeIXFR, dns.TypeMAILA, dns.TypeMAILB, dns.TypeOPT, dns.TypeTSIG:
+		return true
+	}
+	return false
+}
+
+func setKey(name string, rrtype uint16) string {
+	return strings.ToLower(name) + " " + dns.TypeToString[rrtype]
+}
+
+// toRR converts an API record to a DNS RR.
+func toRR(r *api.Record) (dns.RR, bool) {
+	hdr := dns.RR_Header{Name: strings.ToLower(api.Fqdn(r.Name)), Class: dns.ClassINET, Ttl: r.TTL}
+
+	switch r.Type {
+	case api.TypeTXT, api.TypeSPF:
+		hdr.Rrtype = dns.StringToType[r.Type]
+		txt := []string{}
+		for s := r.Content; len(s) > 0 || len(txt) == 0; {
+			n := len(s)
+			if n > 255 {
+				n = 255
+			}
+			txt = append(txt, s[:n])
+			s = s[n:]
+		}
+		if r.Type == api.TypeSPF {
+			return &dns.SPF{Hdr: hdr, Txt: txt}, true
+		}
+		return &dns.TXT{Hdr: hdr, Txt: txt}, true
+	}
+
+	if _, ok := dns.StringToType[r.Type]; !ok {
+		return nil, false
+	}
+	rr, err := dns.NewRR(hdr.Name + " " + dns.Class(hdr.Class).String() + " " + r.Type + " " + r.Content)
+	if err != nil || rr == nil {
+		return nil, false
+	}
+	rr.Header().Ttl = r.TTL
+	return rr, true
+}
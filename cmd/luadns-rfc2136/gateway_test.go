@@ -0,0 +1,295 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	api "github.com/luadns/luadns-go"
+	"github.com/luadns/luadns-go/internal/fakeapi"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	keyName = "dhcp."
+	secret  = "c2VjcmV0c2VjcmV0c2VjcmV0"
+)
+
+func startGateway(t *testing.T, gw *Gateway) (udp, tcp string) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	secrets := map[string]string{keyName: secret}
+	servers := []*dns.Server{
+		{PacketConn: pc, Handler: gw, TsigSecret: secrets, MsgAcceptFunc: AcceptUpdates},
+		{Listener: l, Handler: gw, TsigSecret: secrets, MsgAcceptFunc: AcceptUpdates},
+	}
+	for _, srv := range servers {
+		srv := srv
+		started := make(chan struct{})
+		srv.NotifyStartedFunc = func() { close(started) }
+		go srv.ActivateAndServe()
+		<-started
+		t.Cleanup(func() { srv.Shutdown() })
+	}
+	return pc.LocalAddr().String(), l.Addr().String()
+}
+
+func exchange(t *testing.T, net, addr string, m *dns.Msg, sign bool) *dns.Msg {
+	c := &dns.Client{Net: net, TsigSecret: map[string]string{keyName: secret}}
+	if sign {
+		m.SetTsig(keyName, dns.HmacSHA256, 300, time.Now().Unix())
+	}
+	r, _, err := c.Exchange(m, addr)
+	if !assert.NoError(t, err) {
+		return &dns.Msg{}
+	}
+	return r
+}
+
+func rr(s string) dns.RR {
+	rr, err := dns.NewRR(s)
+	if err != nil {
+		panic(err)
+	}
+	return rr
+}
+
+// classRR returns a RR with its class replaced, keeping its rdata.
+func classRR(s string, class uint16) dns.RR {
+	r := rr(s)
+	r.Header().Class = class
+	return r
+}
+
+func TestGateway(t *testing.T) {
+	server := fakeapi.New()
+	defer server.Close()
+
+	zone := server.AddZone("example.org",
+		&api.Record{Name: "www.example.org.", Type: "A", Content: "1.1.1.1", TTL: 300},
+		&api.Record{Name: "www.example.org.", Type: "TXT", Content: "hello world", TTL: 300},
+		&api.Record{Name: "old.example.org.", Type: "A", Content: "2.2.2.2", TTL: 300},
+		&api.Record{Name: "old.example.org.", Type: "A", Content: "3.3.3.3", TTL: 300},
+	)
+	udp, tcp := startGateway(t, &Gateway{Client: server.Client()})
+
+	records := func() []string {
+		list := []string{}
+		for _, r := range server.Records(zone.ID)[3:] {
+			list = append(list, r.String())
+		}
+		sort.Strings(list)
+		return list
+	}
+
+	// Unsigned updates are refused.
+	m := new(dns.Msg)
+	m.SetUpdate("example.org.")
+	m.Insert([]dns.RR{rr("new.example.org. 300 IN A 4.4.4.4")})
+	assert.Equal(t, dns.RcodeRefused, exchange(t, "udp", udp, m, false).Rcode)
+
+	// Add records.
+	m = new(dns.Msg)
+	m.SetUpdate("example.org.")
+	m.Insert([]dns.RR{rr("new.example.org. 300 IN A 4.4.4.4"), rr(`new.example.org. 300 IN TXT "a b" "c"`)})
+	r := exchange(t, "udp", udp, m, true)
+	assert.Equal(t, dns.RcodeSuccess, r.Rcode)
+	assert.NotNil(t, r.IsTsig())
+
+	// Replace a RRset if it holds the expected value (over TCP).
+	server.ResetRequests()
+	m = new(dns.Msg)
+	m.SetUpdate("example.org.")
+	m.Used([]dns.RR{rr("www.example.org. 0 IN A 1.1.1.1")})
+	m.RemoveRRset([]dns.RR{rr("www.example.org. 0 IN A 0.0.0.0")})
+	m.Insert([]dns.RR{rr("www.example.org. 300 IN A 5.5.5.5")})
+	m.Remove([]dns.RR{rr("old.example.org. 0 IN A 2.2.2.2")})
+	assert.Equal(t, dns.RcodeSuccess, exchange(t, "tcp", tcp, m, true).Rcode)
+	assert.Contains(t, server.Requests(), "PATCH /zones/101/records")
+	assert.Contains(t, server.Requests(), "POST /zones/101/records/delete_many")
+
+	assert.Equal(t, []string{
+		"new.example.org. 300 IN A 4.4.4.4",
+		"new.example.org. 300 IN TXT a bc",
+		"old.example.org. 300 IN A 3.3.3.3",
+		"www.example.org. 300 IN A 5.5.5.5",
+		"www.example.org. 300 IN TXT hello world",
+	}, records())
+
+	// TXT records are compared by content, string boundaries are ignored.
+	server.ResetRequests()
+	m = new(dns.Msg)
+	m.SetUpdate("example.org.")
+	m.Used([]dns.RR{rr(`www.example.org. 0 IN TXT "hello " "world"`)})
+	m.Insert([]dns.RR{rr(`www.example.org. 300 IN TXT "hello" " world"`)})
+	assert.Equal(t, dns.RcodeSuccess, exchange(t, "udp", udp, m, true).Rcode)
+	assert.Equal(t, []string{"GET /zones/101/records"}, server.Requests())
+
+	// Delete all RRsets of a name, apex SOA and NS records are kept.
+	m = new(dns.Msg)
+	m.SetUpdate("example.org.")
+	m.RemoveName([]dns.RR{rr("www.example.org. 0 IN A 0.0.0.0"), rr("example.org. 0 IN A 0.0.0.0")})
+	assert.Equal(t, dns.RcodeSuccess, exchange(t, "udp", udp, m, true).Rcode)
+	assert.Len(t, server.Records(zone.ID), 3+3)
+}
+
+func TestGatewayConcurrentUpdates(t *testing.T) {
+	server := fakeapi.New()
+	defer server.Close()
+
+	zone := server.AddZone("example.org", &api.Record{Name: "www.example.org.", Type: "A", Content: "1.1.1.1", TTL: 300})
+	udp, _ := startGateway(t, &Gateway{Client: server.Client()})
+
+	// Updates replace the RRset when it holds the initial value, updates are
+	// applied one at a time so a single one succeeds.
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		succeeded int
+	)
+	for i := 2; i <= 10; i++ {
+		m := new(dns.Msg)
+		m.SetUpdate("example.org.")
+		m.Used([]dns.RR{rr("www.example.org. 0 IN A 1.1.1.1")})
+		m.RemoveRRset([]dns.RR{rr("www.example.org. 0 IN A 0.0.0.0")})
+		m.Insert([]dns.RR{rr(fmt.Sprintf("www.example.org. 300 IN A 1.1.1.%d", i))})
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if exchange(t, "udp", udp, m, true).Rcode == dns.RcodeSuccess {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, succeeded)
+	assert.Len(t, server.Records(zone.ID), 3+1)
+
+	// Zones are listed once.
+	n := 0
+	for _, r := range server.Requests() {
+		if r == "GET /zones" {
+			n++
+		}
+	}
+	assert.Equal(t, 1, n)
+}
+
+func TestGatewayErrors(t *testing.T) {
+	server := fakeapi.New()
+	defer server.Close()
+
+	server.AddZone("example.org", &api.Record{Name: "www.example.org.", Type: "A", Content: "1.1.1.1", TTL: 300})
+	server.AddZone("example.net")
+	udp, _ := startGateway(t, &Gateway{Client: server.Client(), Zones: []string{"example.org", "example.com"}})
+
+	tests := []struct {
+		name  string
+		zone  string
+		setup func(m *dns.Msg)
+		rcode int
+	}{
+		{"rrset exists", "example.org.", func(m *dns.Msg) { m.RRsetUsed([]dns.RR{rr("mail.example.org. 0 IN A 0.0.0.0")}) }, dns.RcodeNXRrset},
+		{"rrset value", "example.org.", func(m *dns.Msg) { m.Used([]dns.RR{rr("www.example.org. 0 IN A 2.2.2.2")}) }, dns.RcodeNXRrset},
+		{"rrset not exists", "example.org.", func(m *dns.Msg) { m.RRsetNotUsed([]dns.RR{rr("www.example.org. 0 IN A 0.0.0.0")}) }, dns.RcodeYXRrset},
+		{"name in use", "example.org.", func(m *dns.Msg) { m.NameUsed([]dns.RR{rr("mail.example.org. 0 IN A 0.0.0.0")}) }, dns.RcodeNameError},
+		{"name not in use", "example.org.", func(m *dns.Msg) { m.NameNotUsed([]dns.RR{rr("www.example.org. 0 IN A 0.0.0.0")}) }, dns.RcodeYXDomain},
+		{"rrset exists with rdata", "example.org.", func(m *dns.Msg) { m.Answer = []dns.RR{classRR("www.example.org. 0 IN A 1.1.1.1", dns.ClassANY)} }, dns.RcodeFormatError},
+		{"rrset not exists with rdata", "example.org.", func(m *dns.Msg) { m.Answer = []dns.RR{classRR("mail.example.org. 0 IN A 1.1.1.1", dns.ClassNONE)} }, dns.RcodeFormatError},
+		{"not zone", "example.org.", func(m *dns.Msg) { m.Insert([]dns.RR{rr("www.example.com. 300 IN A 1.1.1.1")}) }, dns.RcodeNotZone},
+		{"zone not allowed", "example.net.", func(m *dns.Msg) {}, dns.RcodeNotAuth},
+		{"zone not found", "example.com.", func(m *dns.Msg) {}, dns.RcodeNotAuth},
+		{"api error", "example.org.", func(m *dns.Msg) { m.Insert([]dns.RR{rr("www.example.org. 300 IN A 2.2.2.2")}) }, dns.RcodeServerFailure},
+	}
+
+	server.Fail = func(r *http.Request) int {
+		if r.Method == http.MethodPost {
+			return http.StatusBadRequest
+		}
+		return 0
+	}
+	c := &dns.Client{TsigSecret: map[string]string{keyName: secret}}
+	for _, tt := range tests {
+		m := new(dns.Msg)
+		m.SetUpdate(tt.zone)
+		tt.setup(m)
+		m.SetTsig(keyName, dns.HmacSHA256, 300, time.Now().Unix())
+
+		r, _, err := c.Exchange(m, udp)
+		if tt.rcode == dns.RcodeNotAuth {
+			// NOTAUTH responses are reported as authentication errors by the client.
+			assert.Equal(t, dns.ErrAuth, err, tt.name)
+			continue
+		}
+		if assert.NoError(t, err, tt.name) {
+			assert.Equal(t, dns.RcodeToString[tt.rcode], dns.RcodeToString[r.Rcode], tt.name)
+		}
+	}
+
+	// Bad signature.
+	m := new(dns.Msg)
+	m.SetUpdate("example.org.")
+	m.SetTsig(keyName, dns.HmacSHA256, 300, time.Now().Unix())
+	bad := &dns.Client{TsigSecret: map[string]string{keyName: "YmFkYmFkYmFk"}}
+	r, _, err := bad.Exchange(m, udp)
+	if assert.NoError(t, err) {
+		assert.Equal(t, dns.RcodeNotAuth, r.Rcode)
+	}
+
+	// Queries are not implemented.
+	m = new(dns.Msg)
+	m.SetQuestion("www.example.org.", dns.TypeA)
+	assert.Equal(t, dns.RcodeNotImplemented, exchange(t, "udp", udp, m, true).Rcode)
+}
+
+func TestGatewayKeyZones(t *testing.T) {
+	server := fakeapi.New()
+	defer server.Close()
+
+	zone := server.AddZone("example.org")
+	server.AddZone("example.net")
+	udp, _ := startGateway(t, &Gateway{Client: server.Client(), KeyZones: map[string][]string{keyName: {"example.org"}}})
+
+	c := &dns.Client{TsigSecret: map[string]string{keyName: secret}}
+	for _, origin := range []string{"example.org.", "example.net."} {
+		m := new(dns.Msg)
+		m.SetUpdate(origin)
+		m.Insert([]dns.RR{rr("www." + origin + " 300 IN A 1.1.1.1")})
+		m.SetTsig(keyName, dns.HmacSHA256, 300, time.Now().Unix())
+
+		r, _, err := c.Exchange(m, udp)
+		if origin == "example.net." {
+			// NOTAUTH responses are reported as authentication errors by the client.
+			assert.Equal(t, dns.ErrAuth, err)
+			continue
+		}
+		if assert.NoError(t, err) {
+			assert.Equal(t, dns.RcodeSuccess, r.Rcode)
+		}
+	}
+	assert.Len(t, server.Records(zone.ID), 4)
+}
+
+func TestParseKeys(t *testing.T) {
+	secrets, keyZones, err := parseKeys([]string{"dhcp:c2VjcmV0", "Acme.:c2VjcmV0:example.org,example.net"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"dhcp.": "c2VjcmV0", "Acme.": "c2VjcmV0"}, secrets)
+	assert.Equal(t, map[string][]string{"acme.": {"example.org", "example.net"}}, keyZones)
+
+	for _, k := range []string{"dhcp", "dhcp:", "dhcp:c2VjcmV0:"} {
+		_, _, err = parseKeys([]string{k})
+		assert.EqualError(t, err, fmt.Sprintf("invalid TSIG key %q", k))
+	}
+}
@@ -0,0 +1,103 @@
+// Command luadns-rfc2136 is a RFC 2136 dynamic update gateway for LuaDNS.
+//
+// It accepts TSIG signed DNS UPDATE messages over UDP and TCP, checks their
+// prerequisites against live records and applies updates with bulk API
+// calls. Credentials are read from LUADNS_API_USERNAME and LUADNS_API_TOKEN.
+// A TSIG key can be restricted to zones by listing them after its secret.
+//
+//	luadns-rfc2136 -listen :5353 -tsig dhcp.:c2VjcmV0:example.org,example.net
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	api "github.com/luadns/luadns-go"
+	"github.com/miekg/dns"
+)
+
+const (
+	baseURL = "https://api.luadns.com/v1"
+)
+
+// stringsFlag represents a repeatable flag.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(s string) error {
+	*f = append(*f, s)
+	return nil
+}
+
+var (
+	url    string
+	listen string
+	keys   stringsFlag
+	zones  stringsFlag
+)
+
+func main() {
+	flag.StringVar(&url, "url", baseURL, "base URL")
+	flag.StringVar(&listen, "listen", ":53", "UDP and TCP listen address")
+	flag.Var(&keys, "tsig", "TSIG key as name:base64-secret[:zone,...], any HMAC algorithm (repeatable)")
+	flag.Var(&zones, "zone", "zone accepting updates, all zones by default (repeatable)")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [options]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	email, key := os.Getenv("LUADNS_API_USERNAME"), os.Getenv("LUADNS_API_TOKEN")
+	if email == "" || key == "" {
+		log.Fatalln("LUADNS_API_USERNAME and LUADNS_API_TOKEN are required")
+	}
+
+	secrets, keyZones, err := parseKeys(keys)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	c := api.NewClient(email, key, api.SetBaseURL(url))
+	c.UserAgent("luadns-rfc2136")
+	gw := &Gateway{Client: c, Zones: zones, KeyZones: keyZones}
+
+	errs := make(chan error, 2)
+	for _, net := range []string{"udp", "tcp"} {
+		srv := &dns.Server{Addr: listen, Net: net, Handler: gw, TsigSecret: secrets, MsgAcceptFunc: AcceptUpdates}
+		go func() {
+			errs <- srv.ListenAndServe()
+		}()
+	}
+	log.Println("Listening on", listen)
+	log.Fatalln(<-errs)
+}
+
+// parseKeys parses TSIG keys (name:secret[:zone,...]) and returns their
+// secrets and the zones of restricted keys.
+func parseKeys(keys []string) (map[string]string, map[string][]string, error) {
+	if len(keys) == 0 {
+		return nil, nil, fmt.Errorf("at least one TSIG key is required")
+	}
+
+	secrets := map[string]string{}
+	keyZones := map[string][]string{}
+	for _, k := range keys {
+		name, rest, ok := strings.Cut(k, ":")
+		secret, list, restricted := strings.Cut(rest, ":")
+		if !ok || name == "" || secret == "" || (restricted && list == "") {
+			return nil, nil, fmt.Errorf("invalid TSIG key %q", k)
+		}
+		name = dns.Fqdn(name)
+		secrets[name] = secret
+		if restricted {
+			keyZones[strings.ToLower(name)] = strings.Split(list, ",")
+		}
+	}
+	return secrets, keyZones, nil
+}
@@ -0,0 +1,223 @@
+package luadns
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultMaxAttempts is the number of read-modify-write attempts made by
+	// ModifyRecord and ModifyZone.
+	DefaultMaxAttempts = 3
+
+	// DefaultMaxReadAge is the age of a read after which ModifyRecord and
+	// ModifyZone check the resource again before writing.
+	DefaultMaxReadAge = time.Second
+)
+
+// ErrConcurrentModification represents an error returned when a record or a
+// zone was changed by someone else since it was read.
+type ErrConcurrentModification struct {
+	Resource string // "record" or "zone"
+	ID       int64
+	Expected time.Time
+	Actual   time.Time
+}
+
+func (e *ErrConcurrentModification) Error() string {
+	msg := "Concurrent modification of " + e.Resource + " " + strconv.FormatInt(e.ID, 10)
+	if e.Actual.Equal(e.Expected) {
+		return msg + ": changed since read"
+	}
+	return msg + ": updated at " + e.Actual.UTC().Format(time.RFC3339Nano) +
+		", expected " + e.Expected.UTC().Format(time.RFC3339Nano)
+}
+
+// ModifyOptions represents options used by ModifyRecord and ModifyZone.
+type ModifyOptions struct {
+	MaxAttempts int           // defaults to DefaultMaxAttempts
+	Backoff     time.Duration // delay between attempts, doubled after each attempt
+	MaxReadAge  time.Duration // older reads are checked before writing, defaults to DefaultMaxReadAge
+}
+
+// UpdateRecordIfUnmodified updates a zone record like UpdateRecord, it fails
+// with ErrConcurrentModification when the record differs from `expected`, the
+// record as read before. UpdatedAt has a one second resolution, name, type,
+// content and TTL are compared too.
+//
+// The record is checked just before writing, a small window remains between
+// the check and the update since the API doesn't support conditional writes.
+func (c *Client) UpdateRecordIfUnmodified(ctx context.Context, zone *Zone, recordID int64, expected *Record, attrs *Record) (*Record, error) {
+	current, err := c.GetRecord(ctx, zone, recordID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkRecordUnmodified(expected, current); err != nil {
+		return nil, err
+	}
+
+	return c.UpdateRecord(ctx, zone, recordID, attrs)
+}
+
+// UpdateZoneIfUnmodified updates a zone like UpdateZone, it fails with
+// ErrConcurrentModification when the zone UpdatedAt, tags or template differ
+// from `expected`, the zone as read before.
+// Note the zone UpdatedAt changes along with any of its records.
+func (c *Client) UpdateZoneIfUnmodified(ctx context.Context, zoneID int64, expected *Zone, attrs *Zone) (*Zone, error) {
+	current, err := c.GetZone(ctx, zoneID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkZoneUnmodified(expected, current); err != nil {
+		return nil, err
+	}
+
+	return c.UpdateZone(ctx, zoneID, attrs)
+}
+
+// ModifyRecord reads a record, applies `fn` to a copy and writes it. When the
+// read is older than MaxReadAge once `fn` returns (slow `fn`, retries after a
+// backoff), the record is read again and checked unmodified like
+// UpdateRecordIfUnmodified. On concurrent modification `fn` is called again
+// with the record read by the check, so changes are merged instead of
+// overwritten.
+func (c *Client) ModifyRecord(ctx context.Context, zone *Zone, recordID int64, fn func(r *Record) error, opts *ModifyOptions) (*Record, error) {
+	current, err := c.GetRecord(ctx, zone, recordID)
+	if err != nil {
+		return nil, err
+	}
+	readAt := time.Now()
+
+	var record *Record
+	err = retryModify(ctx, opts, func() error {
+		attrs := *current
+		if err := fn(&attrs); err != nil {
+			return err
+		}
+
+		if time.Since(readAt) >= maxReadAge(opts) {
+			latest, err := c.GetRecord(ctx, zone, recordID)
+			if err != nil {
+				return err
+			}
+			readAt = time.Now()
+			if err := checkRecordUnmodified(current, latest); err != nil {
+				current = latest
+				return err
+			}
+		}
+
+		record, err = c.UpdateRecord(ctx, zone, recordID, &attrs)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+// ModifyZone reads a zone, applies `fn` to a copy and writes it, retrying like
+// ModifyRecord.
+func (c *Client) ModifyZone(ctx context.Context, zoneID int64, fn func(z *Zone) error, opts *ModifyOptions) (*Zone, error) {
+	current, err := c.GetZone(ctx, zoneID)
+	if err != nil {
+		return nil, err
+	}
+	readAt := time.Now()
+
+	var zone *Zone
+	err = retryModify(ctx, opts, func() error {
+		attrs := *current
+		attrs.Tags = append([]string(nil), current.Tags...)
+		attrs.Records = nil
+		if err := fn(&attrs); err != nil {
+			return err
+		}
+
+		if time.Since(readAt) >= maxReadAge(opts) {
+			latest, err := c.GetZone(ctx, zoneID)
+			if err != nil {
+				return err
+			}
+			readAt = time.Now()
+			if err := checkZoneUnmodified(current, latest); err != nil {
+				current = latest
+				return err
+			}
+		}
+
+		zone, err = c.UpdateZone(ctx, zoneID, &attrs)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return zone, nil
+}
+
+// checkRecordUnmodified compares a record read before with its current state.
+func checkRecordUnmodified(expected, current *Record) error {
+	if current.UpdatedAt.Equal(expected.UpdatedAt) &&
+		strings.EqualFold(Fqdn(current.Name), Fqdn(expected.Name)) &&
+		current.Type == expected.Type &&
+		current.Content == expected.Content &&
+		current.TTL == expected.TTL {
+		return nil
+	}
+	return &ErrConcurrentModification{Resource: "record", ID: current.ID, Expected: expected.UpdatedAt, Actual: current.UpdatedAt}
+}
+
+// checkZoneUnmodified compares a zone read before with its current state.
+func checkZoneUnmodified(expected, current *Zone) error {
+	if current.UpdatedAt.Equal(expected.UpdatedAt) &&
+		sameTags(current.Tags, expected.Tags) &&
+		current.TemplateID == expected.TemplateID {
+		return nil
+	}
+	return &ErrConcurrentModification{Resource: "zone", ID: current.ID, Expected: expected.UpdatedAt, Actual: current.UpdatedAt}
+}
+
+func maxReadAge(opts *ModifyOptions) time.Duration {
+	if opts == nil || opts.MaxReadAge <= 0 {
+		return DefaultMaxReadAge
+	}
+	return opts.MaxReadAge
+}
+
+// retryModify calls `attempt` until it doesn't fail with ErrConcurrentModification.
+func retryModify(ctx context.Context, opts *ModifyOptions, attempt func() error) error {
+	if opts == nil {
+		opts = &ModifyOptions{}
+	}
+	maxAttempts := opts.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultMaxAttempts
+	}
+	backoff := opts.Backoff
+
+	var err error
+	for i := 0; i < maxAttempts; i++ {
+		if i > 0 && backoff > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+
+		err = attempt()
+		var conflict *ErrConcurrentModification
+		if !errors.As(err, &conflict) {
+			return err
+		}
+	}
+	return err
+}
@@ -0,0 +1,135 @@
+package luadns_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/luadns/luadns-go"
+	"github.com/luadns/luadns-go/internal/fakeapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateRecordIfUnmodified(t *testing.T) {
+	server := fakeapi.New()
+	defer server.Close()
+
+	zone := server.AddZone("example.org", &luadns.Record{Name: "www.example.org.", Type: "A", Content: "1.1.1.1", TTL: 300})
+	record := server.Records(zone.ID)[3]
+	c := server.Client()
+	ctx := context.Background()
+
+	server.Touch(zone.ID, record.ID, "2.2.2.2")
+
+	_, err := c.UpdateRecordIfUnmodified(ctx, zone, record.ID, record, &luadns.Record{Name: "www.example.org.", Type: "A", Content: "3.3.3.3", TTL: 300})
+	var conflict *luadns.ErrConcurrentModification
+	assert.True(t, errors.As(err, &conflict))
+	assert.Equal(t, "record", conflict.Resource)
+	assert.Equal(t, record.ID, conflict.ID)
+	assert.Equal(t, "2.2.2.2", server.Records(zone.ID)[3].Content)
+
+	// Changes made within the same second are detected by content.
+	fresh := server.Records(zone.ID)[3]
+	stale := *fresh
+	stale.Content = "1.1.1.1"
+	_, err = c.UpdateRecordIfUnmodified(ctx, zone, record.ID, &stale, &luadns.Record{Name: "www.example.org.", Type: "A", Content: "3.3.3.3", TTL: 300})
+	assert.EqualError(t, err, "Concurrent modification of record 105: changed since read")
+
+	updated, err := c.UpdateRecordIfUnmodified(ctx, zone, record.ID, fresh, &luadns.Record{Name: "www.example.org.", Type: "A", Content: "3.3.3.3", TTL: 300})
+	assert.NoError(t, err)
+	assert.Equal(t, "3.3.3.3", updated.Content)
+}
+
+func TestUpdateZoneIfUnmodified(t *testing.T) {
+	server := fakeapi.New()
+	defer server.Close()
+
+	zone := server.AddZone("example.org", &luadns.Record{Name: "www.example.org.", Type: "A", Content: "1.1.1.1", TTL: 300})
+	c := server.Client()
+	ctx := context.Background()
+
+	_, err := c.UpdateZone(ctx, zone.ID, &luadns.Zone{Name: zone.Name, Tags: []string{"a"}})
+	assert.NoError(t, err)
+
+	_, err = c.UpdateZoneIfUnmodified(ctx, zone.ID, zone, &luadns.Zone{Name: zone.Name, Tags: []string{"b"}})
+	assert.IsType(t, &luadns.ErrConcurrentModification{}, err)
+
+	// Tags are compared along with UpdatedAt.
+	fresh, err := c.GetZone(ctx, zone.ID)
+	assert.NoError(t, err)
+	fresh.Tags = nil
+	_, err = c.UpdateZoneIfUnmodified(ctx, zone.ID, fresh, &luadns.Zone{Name: zone.Name, Tags: []string{"b"}})
+	assert.IsType(t, &luadns.ErrConcurrentModification{}, err)
+}
+
+func TestModifyRecord(t *testing.T) {
+	server := fakeapi.New()
+	defer server.Close()
+
+	zone := server.AddZone("example.org", &luadns.Record{Name: "www.example.org.", Type: "TXT", Content: "a", TTL: 300})
+	record := server.Records(zone.ID)[3]
+	c := server.Client()
+	ctx := context.Background()
+
+	// Fresh reads are written without check.
+	server.ResetRequests()
+	updated, err := c.ModifyRecord(ctx, zone, record.ID, func(r *luadns.Record) error {
+		r.Content += "b"
+		return nil
+	}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "ab", updated.Content)
+	assert.Equal(t, []string{"GET /zones/101/records/105", "PUT /zones/101/records/105"}, server.Requests())
+
+	// Old reads are checked, the record read by a failed check is used by the
+	// next attempt.
+	server.ResetRequests()
+	calls := 0
+	updated, err = c.ModifyRecord(ctx, zone, record.ID, func(r *luadns.Record) error {
+		calls++
+		if calls == 1 {
+			server.Touch(zone.ID, record.ID, "b") // concurrent writer
+		}
+		r.Content += "c"
+		return nil
+	}, &luadns.ModifyOptions{MaxReadAge: time.Nanosecond})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, "bc", updated.Content)
+	assert.Equal(t, []string{
+		"GET /zones/101/records/105",
+		"GET /zones/101/records/105",
+		"GET /zones/101/records/105",
+		"PUT /zones/101/records/105",
+	}, server.Requests())
+
+	// Attempts are bounded.
+	calls = 0
+	_, err = c.ModifyRecord(ctx, zone, record.ID, func(r *luadns.Record) error {
+		calls++
+		server.Touch(zone.ID, record.ID, "x")
+		return nil
+	}, &luadns.ModifyOptions{MaxAttempts: 2, MaxReadAge: time.Nanosecond})
+	assert.IsType(t, &luadns.ErrConcurrentModification{}, err)
+	assert.Equal(t, 2, calls)
+}
+
+func TestModifyZone(t *testing.T) {
+	server := fakeapi.New()
+	defer server.Close()
+
+	zone := server.AddZone("example.org")
+	c := server.Client()
+	ctx := context.Background()
+
+	_, err := c.UpdateZone(ctx, zone.ID, &luadns.Zone{Name: zone.Name, Tags: []string{"dns", "web"}})
+	assert.NoError(t, err)
+
+	updated, err := c.ModifyZone(ctx, zone.ID, func(z *luadns.Zone) error {
+		z.Tags[0] = "prod"
+		return nil
+	}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"prod", "web"}, updated.Tags)
+}
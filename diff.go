@@ -0,0 +1,231 @@
+package luadns
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"sort"
+	"strings"
+)
+
+// ChangeAction represents the kind of change applied to a record.
+type ChangeAction string
+
+const (
+	ChangeCreate ChangeAction = "create"
+	ChangeUpdate ChangeAction = "update"
+	ChangeDelete ChangeAction = "delete"
+)
+
+// Change represents a single record change. Creates have only `Desired`,
+// deletes have only `Current`, updates have both.
+type Change struct {
+	Action  ChangeAction `json:"action"`
+	Desired *Record      `json:"desired,omitempty"`
+	Current *Record      `json:"current,omitempty"`
+}
+
+// ChangeSet represents the changes needed to turn current records into desired records.
+type ChangeSet struct {
+	Changes []*Change `json:"changes"`
+
+	// Unchanged lists current records kept in (name, type) sets having
+	// changes, ApplyChanges reads the kept records from the zone instead.
+	Unchanged []*Record `json:"unchanged,omitempty"`
+}
+
+// DiffOptions represents options used when computing a ChangeSet.
+type DiffOptions struct {
+	Zone        string   // zone name, records generated by LuaDNS in this zone are protected (defaults to the SOA owner)
+	IgnoreTTL   bool     // don't compare record TTLs
+	IgnoreTypes []string // record types left untouched
+}
+
+// Diff computes creates, updates and deletes needed to turn `current` records
+// into `desired` records.
+//
+// Records are identified by name, type and canonical content. Within a
+// (name, type) set unmatched records are paired as updates, the remaining
+// records are created or deleted. Desired records without TTL match any TTL.
+// Records generated by LuaDNS (SOA, apex NS) are never changed, the apex is
+// taken from the current SOA record when DiffOptions.Zone is empty.
+func Diff(desired []*Record, current []*Record, opts *DiffOptions) ChangeSet {
+	if opts == nil {
+		opts = &DiffOptions{}
+	}
+
+	origin := opts.Zone
+	for _, r := range current {
+		if origin == "" && r.Type == TypeSOA {
+			origin = r.Name
+		}
+	}
+	skip := func(r *Record) bool {
+		return r.Type == TypeSOA || (origin != "" && r.IsGenerated(origin)) || r.Generated || hasType(opts.IgnoreTypes, r.Type)
+	}
+
+	// Group records by (name, type).
+	type rrset struct {
+		desired []*Record
+		current []*Record
+	}
+	sets := map[string]*rrset{}
+	keys := []string{}
+	set := func(r *Record) *rrset {
+		key := setKey(r)
+		s, ok := sets[key]
+		if !ok {
+			s = &rrset{}
+			sets[key] = s
+			keys = append(keys, key)
+		}
+		return s
+	}
+
+	for _, r := range current {
+		if !skip(r) {
+			set(r).current = append(set(r).current, r)
+		}
+	}
+	for _, r := range desired {
+		if !skip(r) {
+			set(r).desired = append(set(r).desired, r)
+		}
+	}
+	sort.Strings(keys)
+
+	cs := ChangeSet{Changes: []*Change{}}
+	for _, key := range keys {
+		s := sets[key]
+		n := len(cs.Changes)
+
+		// Match records with the same canonical content.
+		unmatched := []*Record{}
+		kept := []*Record{}
+		used := make([]bool, len(s.current))
+		for _, d := range s.desired {
+			found := false
+			for i, c := range s.current {
+				if used[i] || canonicalContent(d.Type, d.Content) != canonicalContent(c.Type, c.Content) {
+					continue
+				}
+				used[i], found = true, true
+				if !opts.IgnoreTTL && d.TTL != 0 && d.TTL != c.TTL {
+					cs.Changes = append(cs.Changes, &Change{Action: ChangeUpdate, Desired: d, Current: c})
+				} else {
+					kept = append(kept, c)
+				}
+				break
+			}
+			if !found {
+				unmatched = append(unmatched, d)
+			}
+		}
+
+		stale := []*Record{}
+		for i, c := range s.current {
+			if !used[i] {
+				stale = append(stale, c)
+			}
+		}
+
+		// Pair remaining records as updates, then create or delete the rest.
+		for len(unmatched) > 0 && len(stale) > 0 {
+			cs.Changes = append(cs.Changes, &Change{Action: ChangeUpdate, Desired: unmatched[0], Current: stale[0]})
+			unmatched, stale = unmatched[1:], stale[1:]
+		}
+		for _, c := range stale {
+			cs.Changes = append(cs.Changes, &Change{Action: ChangeDelete, Current: c})
+		}
+		for _, d := range unmatched {
+			cs.Changes = append(cs.Changes, &Change{Action: ChangeCreate, Desired: d})
+		}
+
+		if len(cs.Changes) > n {
+			cs.Unchanged = append(cs.Unchanged, kept...)
+		}
+	}
+
+	return cs
+}
+
+// Empty reports whether the change set has no changes.
+func (cs ChangeSet) Empty() bool {
+	return len(cs.Changes) == 0
+}
+
+// Filter returns the changes matching `action`.
+func (cs ChangeSet) Filter(action ChangeAction) []*Change {
+	changes := []*Change{}
+	for _, c := range cs.Changes {
+		if c.Action == action {
+			changes = append(changes, c)
+		}
+	}
+	return changes
+}
+
+// WriteDiff writes the change set as a human readable unified diff.
+func (cs ChangeSet) WriteDiff(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("--- current\n+++ desired\n")
+	for _, c := range cs.Changes {
+		if c.Current != nil {
+			bw.WriteString("-" + c.Current.String() + "\n")
+		}
+		if c.Desired != nil {
+			bw.WriteString("+" + c.Desired.String() + "\n")
+		}
+	}
+	return bw.Flush()
+}
+
+// String returns the change set as a unified diff.
+func (cs ChangeSet) String() string {
+	var b strings.Builder
+	cs.WriteDiff(&b)
+	return b.String()
+}
+
+// WriteJSON writes the change set as indented JSON.
+func (cs ChangeSet) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(cs)
+}
+
+// setKey returns the (name, type) identity of a record.
+func setKey(r *Record) string {
+	return strings.ToLower(Fqdn(r.Name)) + " " + strings.ToUpper(r.Type)
+}
+
+// canonicalContent normalizes record content so equivalent contents compare
+// equal (whitespace, IP address notation, domain names case and trailing dot).
+func canonicalContent(typ, content string) string {
+	typ = strings.ToUpper(typ)
+	switch typ {
+	case TypeTXT, TypeSPF:
+		return content
+	case TypeA, TypeAAAA:
+		if ip := net.ParseIP(strings.TrimSpace(content)); ip != nil {
+			return ip.String()
+		}
+	}
+
+	fields := strings.Fields(content)
+	idx := -1
+	switch typ {
+	case TypeCNAME, TypeNS, TypePTR, TypeALIAS:
+		idx = 0
+	case TypeMX:
+		idx = 1
+	case TypeSRV:
+		idx = 3
+	}
+	if idx >= 0 && idx < len(fields) {
+		fields[idx] = strings.ToLower(Fqdn(fields[idx]))
+	}
+
+	return strings.Join(fields, " ")
+}
@@ -0,0 +1,82 @@
+package luadns_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/luadns/luadns-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	current := []*luadns.Record{
+		{ID: 1, Name: "example.org.", Type: "SOA", Content: "ns1.luadns.net. hostmaster.luadns.net. 1 1200 120 604800 3600", TTL: 3600},
+		{ID: 2, Name: "example.org.", Type: "NS", Content: "ns1.luadns.net.", TTL: 86400},
+		{ID: 3, Name: "example.org.", Type: "A", Content: "1.1.1.1", TTL: 3600},
+		{ID: 4, Name: "example.org.", Type: "A", Content: "2.2.2.2", TTL: 3600},
+		{ID: 5, Name: "www.example.org.", Type: "CNAME", Content: "Example.org.", TTL: 3600},
+		{ID: 6, Name: "mail.example.org.", Type: "CNAME", Content: "ghs.google.com.", TTL: 3600},
+		{ID: 7, Name: "old.example.org.", Type: "A", Content: "3.3.3.3", TTL: 3600},
+		{ID: 8, Name: "example.org.", Type: "TXT", Content: "v=spf1 -all", TTL: 3600},
+	}
+	desired := []*luadns.Record{
+		{Name: "example.org.", Type: "A", Content: "1.1.1.1", TTL: 300},
+		{Name: "example.org.", Type: "A", Content: "4.4.4.4"},
+		{Name: "www.example.org.", Type: "CNAME", Content: "example.org", TTL: 3600},
+		{Name: "mail.example.org.", Type: "CNAME", Content: "mail.example.net.", TTL: 3600},
+		{Name: "new.example.org.", Type: "AAAA", Content: "2001:db8::1", TTL: 3600},
+	}
+
+	cs := luadns.Diff(desired, current, &luadns.DiffOptions{Zone: "example.org", IgnoreTypes: []string{"TXT"}})
+	assert.Equal(t, []*luadns.Change{
+		{Action: luadns.ChangeUpdate, Desired: desired[0], Current: current[2]},
+		{Action: luadns.ChangeUpdate, Desired: desired[1], Current: current[3]},
+		{Action: luadns.ChangeUpdate, Desired: desired[3], Current: current[5]},
+		{Action: luadns.ChangeCreate, Desired: desired[4]},
+		{Action: luadns.ChangeDelete, Current: current[6]},
+	}, cs.Changes)
+	assert.Len(t, cs.Filter(luadns.ChangeUpdate), 3)
+
+	cs = luadns.Diff(desired[:1], current[2:3], &luadns.DiffOptions{IgnoreTTL: true})
+	assert.True(t, cs.Empty())
+}
+
+func TestDiffProtectsGeneratedRecords(t *testing.T) {
+	current := []*luadns.Record{
+		{ID: 1, Name: "example.org.", Type: "NS", Content: "ns1.luadns.net.", TTL: 86400},
+		{ID: 2, Name: "sub.example.org.", Type: "NS", Content: "ns1.example.net.", TTL: 86400},
+		{ID: 3, Name: "x.example.org.", Type: "A", Content: "1.1.1.1", TTL: 300, Generated: true},
+	}
+
+	cs := luadns.Diff(nil, current, &luadns.DiffOptions{Zone: "example.org"})
+	assert.Equal(t, []*luadns.Change{{Action: luadns.ChangeDelete, Current: current[1]}}, cs.Changes)
+
+	// Without zone the apex is found from the SOA record, SOA records are always kept.
+	current = append(current, &luadns.Record{ID: 4, Name: "example.org.", Type: "SOA", Content: "ns1.luadns.net. hostmaster.luadns.net. 1 1200 120 604800 3600", TTL: 3600})
+	desired := []*luadns.Record{{Name: "example.org.", Type: "SOA", Content: "ns1.example.net. hostmaster.example.net. 2 1200 120 604800 3600", TTL: 3600}}
+	cs = luadns.Diff(desired, current, nil)
+	assert.Equal(t, []*luadns.Change{{Action: luadns.ChangeDelete, Current: current[1]}}, cs.Changes)
+}
+
+func TestChangeSetOutput(t *testing.T) {
+	cs := luadns.Diff(
+		[]*luadns.Record{{Name: "www.example.org.", Type: "A", Content: "2.2.2.2", TTL: 300}},
+		[]*luadns.Record{{ID: 1, Name: "www.example.org.", Type: "A", Content: "1.1.1.1", TTL: 300}},
+		nil,
+	)
+
+	assert.Equal(t, "--- current\n+++ desired\n"+
+		"-www.example.org. 300 IN A 1.1.1.1\n"+
+		"+www.example.org. 300 IN A 2.2.2.2\n", cs.String())
+
+	var buf bytes.Buffer
+	assert.NoError(t, cs.WriteJSON(&buf))
+
+	var decoded luadns.ChangeSet
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Len(t, decoded.Changes, 1)
+	assert.Equal(t, luadns.ChangeUpdate, decoded.Changes[0].Action)
+	assert.Equal(t, int64(1), decoded.Changes[0].Current.ID)
+	assert.Equal(t, "2.2.2.2", decoded.Changes[0].Desired.Content)
+}
@@ -0,0 +1,197 @@
+package luadns
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Exit codes returned by DriftReport.ExitCode, suitable for cron and CI jobs.
+const (
+	DriftExitOK    = 0 // live state matches the desired state
+	DriftExitError = 1 // drift detection failed
+	DriftExitDrift = 2 // drift detected
+)
+
+// DriftOptions represents options used by DetectDrift.
+type DriftOptions struct {
+	IgnoreTTL        bool     // don't compare record TTLs
+	IgnoreTypes      []string // record types not compared
+	IgnoreExtraZones bool     // don't report live zones missing from the desired state
+}
+
+// ZoneDrift represents the record and metadata differences of a zone.
+type ZoneDrift struct {
+	Zone     string           `json:"zone"`
+	Missing  []*Record        `json:"missing,omitempty"`  // desired records not found in the live zone
+	Extra    []*Record        `json:"extra,omitempty"`    // live records not found in the desired state
+	Changed  []*Change        `json:"changed,omitempty"`  // records having a different content or TTL
+	Metadata []*MetadataDrift `json:"metadata,omitempty"` // zone attributes having a different value
+}
+
+// MetadataDrift represents a zone attribute differing from the desired state.
+type MetadataDrift struct {
+	Field   string `json:"field"` // "tags" or "template_id"
+	Current string `json:"current"`
+	Desired string `json:"desired"`
+}
+
+// DriftReport represents the differences between a desired state and the live account.
+type DriftReport struct {
+	MissingZones []string     `json:"missing_zones,omitempty"` // desired zones not found in the account
+	ExtraZones   []string     `json:"extra_zones,omitempty"`   // account zones not found in the desired state
+	Zones        []*ZoneDrift `json:"zones,omitempty"`         // zones having record differences
+}
+
+// DetectDrift compares desired zones (built from a zone spec, a zone file or
+// a snapshot) with the live account. Zone tags and template are compared
+// when set in the desired zone, zone files don't carry them.
+func (c *Client) DetectDrift(ctx context.Context, desired []*Zone, opts *DriftOptions) (*DriftReport, error) {
+	if opts == nil {
+		opts = &DriftOptions{}
+	}
+
+	zones, err := c.ListAllZones(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	live := map[string]*Zone{}
+	for _, z := range zones {
+		live[strings.ToLower(Fqdn(z.Name))] = z
+	}
+
+	report := &DriftReport{}
+	seen := map[string]bool{}
+	for _, d := range desired {
+		key := strings.ToLower(Fqdn(d.Name))
+		seen[key] = true
+
+		z, ok := live[key]
+		if !ok {
+			report.MissingZones = append(report.MissingZones, d.Name)
+			continue
+		}
+
+		records, err := c.ListAllRecords(ctx, z)
+		if err != nil {
+			return nil, err
+		}
+
+		cs := Diff(d.Records, records, &DiffOptions{Zone: z.Name, IgnoreTTL: opts.IgnoreTTL, IgnoreTypes: opts.IgnoreTypes})
+		metadata := metadataDrift(d, z)
+		if cs.Empty() && len(metadata) == 0 {
+			continue
+		}
+
+		zd := &ZoneDrift{Zone: z.Name, Metadata: metadata}
+		for _, ch := range cs.Changes {
+			switch ch.Action {
+			case ChangeCreate:
+				zd.Missing = append(zd.Missing, ch.Desired)
+			case ChangeDelete:
+				zd.Extra = append(zd.Extra, ch.Current)
+			default:
+				zd.Changed = append(zd.Changed, ch)
+			}
+		}
+		report.Zones = append(report.Zones, zd)
+	}
+
+	if !opts.IgnoreExtraZones {
+		for _, z := range zones {
+			if !seen[strings.ToLower(Fqdn(z.Name))] {
+				report.ExtraZones = append(report.ExtraZones, z.Name)
+			}
+		}
+		sort.Strings(report.ExtraZones)
+	}
+
+	return report, nil
+}
+
+// metadataDrift compares the tags and template of a desired zone, when set,
+// with the live zone.
+func metadataDrift(desired, live *Zone) []*MetadataDrift {
+	drifts := []*MetadataDrift{}
+	if desired.Tags != nil && !sameTags(desired.Tags, live.Tags) {
+		drifts = append(drifts, &MetadataDrift{Field: "tags", Current: strings.Join(live.Tags, ","), Desired: strings.Join(desired.Tags, ",")})
+	}
+	if desired.TemplateID != 0 && desired.TemplateID != live.TemplateID {
+		drifts = append(drifts, &MetadataDrift{Field: "template_id", Current: strconv.FormatInt(live.TemplateID, 10), Desired: strconv.FormatInt(desired.TemplateID, 10)})
+	}
+	if len(drifts) == 0 {
+		return nil
+	}
+	return drifts
+}
+
+// ZoneFromZoneFile parses a zone file into a zone usable as desired state.
+func ZoneFromZoneFile(r io.Reader, name string, opts *ParseZoneFileOptions) (*Zone, error) {
+	rrs, err := ParseZoneFile(r, name, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	zone := &Zone{Name: strings.TrimSuffix(name, "."), Records: []*Record{}}
+	for _, rr := range rrs {
+		zone.Records = append(zone.Records, &Record{Name: rr.Name, Type: rr.Type, Content: rr.Content, TTL: rr.TTL})
+	}
+	return zone, nil
+}
+
+// HasDrift reports whether the live account differs from the desired state.
+func (r *DriftReport) HasDrift() bool {
+	return len(r.MissingZones) > 0 || len(r.ExtraZones) > 0 || len(r.Zones) > 0
+}
+
+// ExitCode returns DriftExitDrift when drift was detected, DriftExitOK otherwise.
+func (r *DriftReport) ExitCode() int {
+	if r.HasDrift() {
+		return DriftExitDrift
+	}
+	return DriftExitOK
+}
+
+// WriteText writes the report in a human readable format.
+func (r *DriftReport) WriteText(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	if !r.HasDrift() {
+		bw.WriteString("No drift detected.\n")
+		return bw.Flush()
+	}
+
+	for _, name := range r.MissingZones {
+		bw.WriteString("Zone " + name + " is missing\n")
+	}
+	for _, name := range r.ExtraZones {
+		bw.WriteString("Zone " + name + " is not managed\n")
+	}
+	for _, zd := range r.Zones {
+		bw.WriteString("Zone " + zd.Zone + ":\n")
+		for _, rec := range zd.Missing {
+			bw.WriteString("  missing  " + rec.String() + "\n")
+		}
+		for _, rec := range zd.Extra {
+			bw.WriteString("  extra    " + rec.String() + "\n")
+		}
+		for _, ch := range zd.Changed {
+			bw.WriteString("  changed  " + ch.Current.String() + " -> " + ch.Desired.String() + "\n")
+		}
+		for _, m := range zd.Metadata {
+			bw.WriteString("  changed  " + m.Field + ": " + m.Current + " -> " + m.Desired + "\n")
+		}
+	}
+	return bw.Flush()
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r *DriftReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
@@ -0,0 +1,65 @@
+package luadns_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/luadns/luadns-go"
+	"github.com/luadns/luadns-go/internal/fakeapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectDrift(t *testing.T) {
+	server := fakeapi.New()
+	defer server.Close()
+
+	server.AddZone("example.org",
+		&luadns.Record{Name: "www.example.org.", Type: "A", Content: "2.2.2.2", TTL: 300},
+		&luadns.Record{Name: "manual.example.org.", Type: "TXT", Content: "hand made", TTL: 300},
+	)
+	server.AddZone("example.com")
+	net := server.AddZone("example.net")
+	c := server.Client()
+	ctx := context.Background()
+
+	zonefile := `$TTL 300
+@    IN A 1.1.1.1
+www  IN A 1.1.1.1
+`
+	org, err := luadns.ZoneFromZoneFile(strings.NewReader(zonefile), "example.org", nil)
+	assert.NoError(t, err)
+	desired := []*luadns.Zone{org, {Name: "example.net"}, {Name: "example.io"}}
+
+	report, err := c.DetectDrift(ctx, desired, nil)
+	assert.NoError(t, err)
+	assert.True(t, report.HasDrift())
+	assert.Equal(t, luadns.DriftExitDrift, report.ExitCode())
+	assert.Equal(t, []string{"example.io"}, report.MissingZones)
+	assert.Equal(t, []string{"example.com"}, report.ExtraZones)
+
+	var buf bytes.Buffer
+	assert.NoError(t, report.WriteText(&buf))
+	assert.Equal(t, `Zone example.io is missing
+Zone example.com is not managed
+Zone example.org:
+  missing  example.org. 300 IN A 1.1.1.1
+  extra    manual.example.org. 300 IN TXT hand made
+  changed  www.example.org. 300 IN A 2.2.2.2 -> www.example.org. 300 IN A 1.1.1.1
+`, buf.String())
+
+	report, err = c.DetectDrift(ctx, []*luadns.Zone{{Name: "example.net"}}, &luadns.DriftOptions{IgnoreExtraZones: true})
+	assert.NoError(t, err)
+	assert.False(t, report.HasDrift())
+	assert.Equal(t, luadns.DriftExitOK, report.ExitCode())
+
+	// Zone tags are compared when desired.
+	_, err = c.UpdateZone(ctx, net.ID, &luadns.Zone{Name: "example.net", Tags: []string{"web"}})
+	assert.NoError(t, err)
+	report, err = c.DetectDrift(ctx, []*luadns.Zone{{Name: "example.net", Tags: []string{"prod"}}}, &luadns.DriftOptions{IgnoreExtraZones: true})
+	assert.NoError(t, err)
+	buf.Reset()
+	assert.NoError(t, report.WriteText(&buf))
+	assert.Equal(t, "Zone example.net:\n  changed  tags: web -> prod\n", buf.String())
+}
@@ -0,0 +1,491 @@
+// Package fakeapi implements an in-memory fake of the LuaDNS REST API used in tests.
+package fakeapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	api "github.com/luadns/luadns-go"
+)
+
+const (
+	Email  = "joe@example.com"
+	APIKey = "password"
+)
+
+// FailFunc returns a non zero status code to make a request fail.
+type FailFunc func(r *http.Request) int
+
+// Server represents a fake API server.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	zones    []*api.Zone
+	records  map[int64][]*api.Record
+	nextID   int64
+	now      time.Time
+	requests []string
+
+	// Fail is called before each request, a non zero status code aborts the
+	// request with that status.
+	Fail FailFunc
+}
+
+// New starts a fake API server.
+func New() *Server {
+	s := &Server{
+		records: map[int64][]*api.Record{},
+		nextID:  100,
+		now:     time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Client returns an API client configured to use the fake server, `opts`
+// are applied after the base URL.
+func (s *Server) Client(opts ...api.OptFunc) *api.Client {
+	return api.NewClient(Email, APIKey, append([]api.OptFunc{api.SetBaseURL(s.URL)}, opts...)...)
+}
+
+// AddZone adds a zone with generated SOA and NS records and supplied records.
+func (s *Server) AddZone(name string, records ...*api.Record) *api.Zone {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	zone := s.addZone(&api.Zone{Name: name})
+	for _, r := range records {
+		s.addRecord(zone, &api.Record{Name: r.Name, Type: r.Type, Content: r.Content, TTL: r.TTL})
+	}
+	return copyZone(zone)
+}
+
+// Records returns a copy of zone records.
+func (s *Server) Records(zoneID int64) []*api.Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return copyRecords(s.records[zoneID])
+}
+
+// Zones returns a copy of the zones.
+func (s *Server) Zones() []*api.Zone {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	zones := []*api.Zone{}
+	for _, z := range s.zones {
+		zones = append(zones, copyZone(z))
+	}
+	return zones
+}
+
+// Requests returns handled requests as "METHOD /path" strings.
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string{}, s.requests...)
+}
+
+// ResetRequests clears the request log.
+func (s *Server) ResetRequests() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = nil
+}
+
+// Touch changes a record content behind the client back, as another API user would do.
+func (s *Server) Touch(zoneID, recordID int64, content string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, r := range s.records[zoneID] {
+		if r.ID == recordID {
+			r.Content = content
+			r.UpdatedAt = s.tick()
+		}
+	}
+}
+
+func (s *Server) tick() time.Time {
+	s.now = s.now.Add(time.Second)
+	return s.now
+}
+
+func (s *Server) addZone(attrs *api.Zone) *api.Zone {
+	s.nextID++
+	zone := &api.Zone{
+		ID:         s.nextID,
+		Name:       strings.TrimSuffix(attrs.Name, "."),
+		Tags:       attrs.Tags,
+		TemplateID: attrs.TemplateID,
+		CreatedAt:  s.tick(),
+	}
+	zone.UpdatedAt = zone.CreatedAt
+	s.zones = append(s.zones, zone)
+
+	origin := api.Fqdn(zone.Name)
+	s.addRecord(zone, &api.Record{Name: origin, Type: api.TypeSOA, Content: "ns1.luadns.net. hostmaster.luadns.net. 1 1200 120 604800 3600", TTL: 3600})
+	for i := 1; i <= 2; i++ {
+		s.addRecord(zone, &api.Record{Name: origin, Type: api.TypeNS, Content: fmt.Sprintf("ns%d.luadns.net.", i), TTL: 86400})
+	}
+	return zone
+}
+
+func (s *Server) addRecord(zone *api.Zone, attrs *api.Record) *api.Record {
+	s.nextID++
+	r := &api.Record{
+		ID:        s.nextID,
+		Name:      api.Fqdn(attrs.Name),
+		Type:      attrs.Type,
+		Content:   attrs.Content,
+		TTL:       attrs.TTL,
+		ZoneID:    zone.ID,
+		CreatedAt: s.tick(),
+	}
+	if r.TTL == 0 {
+		r.TTL = 3600
+	}
+	r.UpdatedAt = r.CreatedAt
+	s.records[zone.ID] = append(s.records[zone.ID], r)
+	zone.UpdatedAt = r.UpdatedAt
+	return r
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+
+	if user, pass, ok := r.BasicAuth(); !ok || user != Email || pass != APIKey {
+		writeJSON(w, http.StatusForbidden, map[string]string{"status": "Forbidden", "message": "invalid credentials"})
+		return
+	}
+	if s.Fail != nil {
+		if status := s.Fail(r); status != 0 {
+			s.fail(w, status)
+			return
+		}
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if parts[0] != "zones" {
+		writeJSON(w, http.StatusNotFound, map[string]string{"status": "Not Found", "message": "not found"})
+		return
+	}
+
+	if len(parts) == 1 {
+		switch r.Method {
+		case http.MethodGet:
+			zones := []*api.Zone{}
+			for _, z := range s.zones {
+				zones = append(zones, copyZone(z))
+			}
+			writeJSON(w, http.StatusOK, zones)
+		case http.MethodPost:
+			var attrs api.Zone
+			if !readJSON(w, r, &attrs) {
+				return
+			}
+			if attrs.Name == "" || s.zoneByName(attrs.Name) != nil {
+				writeInputError(w, "name", "invalid name")
+				return
+			}
+			writeJSON(w, http.StatusOK, copyZone(s.addZone(&attrs)))
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	zoneID, _ := strconv.ParseInt(parts[1], 10, 64)
+	zone := s.zoneByID(zoneID)
+	if zone == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"status": "Not Found", "message": "zone not found"})
+		return
+	}
+
+	switch {
+	case len(parts) == 2:
+		s.serveZone(w, r, zone)
+	case len(parts) == 3 && parts[2] == "records":
+		s.serveRecords(w, r, zone)
+	case len(parts) == 4 && parts[3] == "create_many" && r.Method == http.MethodPost:
+		s.createMany(w, r, zone)
+	case len(parts) == 4 && parts[3] == "delete_many" && r.Method == http.MethodPost:
+		s.deleteMany(w, r, zone)
+	case len(parts) == 4:
+		id, _ := strconv.ParseInt(parts[3], 10, 64)
+		s.serveRecord(w, r, zone, id)
+	default:
+		writeJSON(w, http.StatusNotFound, map[string]string{"status": "Not Found", "message": "not found"})
+	}
+}
+
+func (s *Server) serveZone(w http.ResponseWriter, r *http.Request, zone *api.Zone) {
+	switch r.Method {
+	case http.MethodGet:
+		z := copyZone(zone)
+		z.Records = copyRecords(s.records[zone.ID])
+		writeJSON(w, http.StatusOK, z)
+	case http.MethodPut:
+		var attrs api.Zone
+		if !readJSON(w, r, &attrs) {
+			return
+		}
+		zone.Tags, zone.TemplateID, zone.UpdatedAt = attrs.Tags, attrs.TemplateID, s.tick()
+		writeJSON(w, http.StatusOK, copyZone(zone))
+	case http.MethodDelete:
+		for i, z := range s.zones {
+			if z.ID == zone.ID {
+				s.zones = append(s.zones[:i], s.zones[i+1:]...)
+				break
+			}
+		}
+		delete(s.records, zone.ID)
+		writeJSON(w, http.StatusOK, copyZone(zone))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) serveRecords(w http.ResponseWriter, r *http.Request, zone *api.Zone) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, copyRecords(s.records[zone.ID]))
+	case http.MethodPost:
+		var attrs api.Record
+		if !readJSON(w, r, &attrs) || !s.validate(w, zone, attrs.Name, attrs.Type, attrs.Content) {
+			return
+		}
+		writeJSON(w, http.StatusOK, copyRecord(s.addRecord(zone, &attrs)))
+	case http.MethodPatch:
+		var rrs []*api.RR
+		if !readJSON(w, r, &rrs) {
+			return
+		}
+		for _, rr := range rrs {
+			if !s.validate(w, zone, rr.Name, rr.Type, rr.Content) {
+				return
+			}
+		}
+
+		// Replace (name, type) sets present in the input.
+		sets := map[string]bool{}
+		for _, rr := range rrs {
+			sets[setKey(rr.Name, rr.Type)] = true
+		}
+		kept := []*api.Record{}
+		for _, rec := range s.records[zone.ID] {
+			if !sets[setKey(rec.Name, rec.Type)] {
+				kept = append(kept, rec)
+			}
+		}
+		s.records[zone.ID] = kept
+
+		updated := []*api.Record{}
+		for _, rr := range rrs {
+			rec := s.addRecord(zone, &api.Record{Name: rr.Name, Type: rr.Type, Content: rr.Content, TTL: rr.TTL})
+			updated = append(updated, copyRecord(rec))
+		}
+		writeJSON(w, http.StatusOK, updated)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) serveRecord(w http.ResponseWriter, r *http.Request, zone *api.Zone, id int64) {
+	idx := -1
+	for i, rec := range s.records[zone.ID] {
+		if rec.ID == id {
+			idx = i
+		}
+	}
+	if idx < 0 {
+		writeJSON(w, http.StatusNotFound, map[string]string{"status": "Not Found", "message": "record not found"})
+		return
+	}
+	rec := s.records[zone.ID][idx]
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, copyRecord(rec))
+	case http.MethodPut:
+		var attrs api.Record
+		if !readJSON(w, r, &attrs) || !s.validate(w, zone, attrs.Name, attrs.Type, attrs.Content) {
+			return
+		}
+		rec.Name, rec.Type, rec.Content, rec.TTL = api.Fqdn(attrs.Name), attrs.Type, attrs.Content, attrs.TTL
+		rec.UpdatedAt = s.tick()
+		zone.UpdatedAt = rec.UpdatedAt
+		writeJSON(w, http.StatusOK, copyRecord(rec))
+	case http.MethodDelete:
+		s.records[zone.ID] = append(s.records[zone.ID][:idx], s.records[zone.ID][idx+1:]...)
+		zone.UpdatedAt = s.tick()
+		writeJSON(w, http.StatusOK, copyRecord(rec))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) createMany(w http.ResponseWriter, r *http.Request, zone *api.Zone) {
+	var rrs []*api.RR
+	if !readJSON(w, r, &rrs) {
+		return
+	}
+	for _, rr := range rrs {
+		if !s.validate(w, zone, rr.Name, rr.Type, rr.Content) {
+			return
+		}
+	}
+
+	created := []*api.Record{}
+	for _, rr := range rrs {
+		rec := s.addRecord(zone, &api.Record{Name: rr.Name, Type: rr.Type, Content: rr.Content, TTL: rr.TTL})
+		created = append(created, copyRecord(rec))
+	}
+	writeJSON(w, http.StatusOK, created)
+}
+
+func (s *Server) deleteMany(w http.ResponseWriter, r *http.Request, zone *api.Zone) {
+	var rrs []*api.RR
+	if !readJSON(w, r, &rrs) {
+		return
+	}
+
+	match := func(rec *api.Record, rr *api.RR) bool {
+		return strings.EqualFold(rec.Name, api.Fqdn(rr.Name)) &&
+			(rr.Type == "" || rr.Type == rec.Type) &&
+			(rr.Content == "" || rr.Content == rec.Content) &&
+			(rr.TTL == 0 || rr.TTL == rec.TTL)
+	}
+
+	kept, deleted := []*api.Record{}, []*api.Record{}
+	for _, rec := range s.records[zone.ID] {
+		found := false
+		for _, rr := range rrs {
+			if match(rec, rr) {
+				found = true
+				break
+			}
+		}
+		if found {
+			deleted = append(deleted, copyRecord(rec))
+		} else {
+			kept = append(kept, rec)
+		}
+	}
+	s.records[zone.ID] = kept
+	if len(deleted) > 0 {
+		zone.UpdatedAt = s.tick()
+	}
+	writeJSON(w, http.StatusOK, deleted)
+}
+
+// validate checks record attributes the same way the API does for common mistakes.
+func (s *Server) validate(w http.ResponseWriter, zone *api.Zone, name, typ, content string) bool {
+	origin := api.Fqdn(zone.Name)
+	name = api.Fqdn(strings.ToLower(name))
+	switch {
+	case name != origin && !strings.HasSuffix(name, "."+origin):
+		writeInputError(w, "name", "name outside of zone")
+		return false
+	case typ == "":
+		writeInputError(w, "type", "type is required")
+		return false
+	case typ == api.TypeA && (net.ParseIP(content) == nil || net.ParseIP(content).To4() == nil):
+		writeInputError(w, "content", "invalid IPv4 address")
+		return false
+	case typ == api.TypeAAAA && (net.ParseIP(content) == nil || net.ParseIP(content).To4() != nil):
+		writeInputError(w, "content", "invalid IPv6 address")
+		return false
+	}
+	return true
+}
+
+func (s *Server) fail(w http.ResponseWriter, status int) {
+	switch status {
+	case http.StatusBadRequest:
+		writeInputError(w, "content", "rejected")
+	case http.StatusForbidden:
+		writeJSON(w, status, map[string]string{"status": "Forbidden", "message": "rejected"})
+	case http.StatusTooManyRequests:
+		w.Header().Set("X-Ratelimit-Limit", "1200")
+		w.Header().Set("X-Ratelimit-Reset", strconv.FormatInt(time.Now().Unix(), 10))
+		writeJSON(w, status, map[string]string{})
+	default:
+		writeJSON(w, status, map[string]string{})
+	}
+}
+
+func (s *Server) zoneByID(id int64) *api.Zone {
+	for _, z := range s.zones {
+		if z.ID == id {
+			return z
+		}
+	}
+	return nil
+}
+
+func (s *Server) zoneByName(name string) *api.Zone {
+	for _, z := range s.zones {
+		if strings.EqualFold(z.Name, strings.TrimSuffix(name, ".")) {
+			return z
+		}
+	}
+	return nil
+}
+
+func setKey(name, typ string) string {
+	return strings.ToLower(api.Fqdn(name)) + " " + typ
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, dest any) bool {
+	if err := json.NewDecoder(r.Body).Decode(dest); err != nil {
+		writeJSON(w, http.StatusBadRequest, []api.InputError{{Classification: "DeserializationError", Message: err.Error()}})
+		return false
+	}
+	return true
+}
+
+func writeInputError(w http.ResponseWriter, field, message string) {
+	writeJSON(w, http.StatusBadRequest, []api.InputError{{Classification: "ValidationError", FieldNames: []string{field}, Message: message}})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func copyZone(z *api.Zone) *api.Zone {
+	c := *z
+	c.Tags = append([]string(nil), z.Tags...)
+	return &c
+}
+
+func copyRecord(r *api.Record) *api.Record {
+	c := *r
+	return &c
+}
+
+func copyRecords(records []*api.Record) []*api.Record {
+	out := []*api.Record{}
+	for _, r := range records {
+		out = append(out, copyRecord(r))
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
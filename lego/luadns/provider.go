@@ -0,0 +1,189 @@
+// Package luadns implements a DNS-01 challenge provider for the lego ACME
+// client. DNSProvider satisfies the lego challenge.Provider and
+// challenge.ProviderTimeout interfaces:
+//
+//	provider, err := luadns.NewDNSProvider()
+//	if err != nil {
+//		return err
+//	}
+//	err = client.Challenge.SetDNS01Provider(provider)
+package luadns
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	api "github.com/luadns/luadns-go"
+)
+
+// Environment variables, compatible with the lego built-in provider.
+const (
+	EnvAPIUsername        = "LUADNS_API_USERNAME"
+	EnvAPIToken           = "LUADNS_API_TOKEN"
+	EnvTTL                = "LUADNS_TTL"
+	EnvPropagationTimeout = "LUADNS_PROPAGATION_TIMEOUT"
+	EnvPollingInterval    = "LUADNS_POLLING_INTERVAL"
+)
+
+// Default settings.
+const (
+	DefaultTTL                = 300
+	DefaultPropagationTimeout = 120 * time.Second
+	DefaultPollingInterval    = 2 * time.Second
+)
+
+// Config is used to configure the creation of the DNSProvider.
+type Config struct {
+	Email              string
+	APIKey             string
+	TTL                uint32
+	PropagationTimeout time.Duration
+	PollingInterval    time.Duration
+
+	// Client overrides the API client built from Email and APIKey.
+	Client *api.Client
+}
+
+// NewDefaultConfig returns a default configuration, settings are read from
+// the environment.
+func NewDefaultConfig() *Config {
+	return &Config{
+		Email:              os.Getenv(EnvAPIUsername),
+		APIKey:             os.Getenv(EnvAPIToken),
+		TTL:                uint32(envInt(EnvTTL, DefaultTTL)),
+		PropagationTimeout: envSeconds(EnvPropagationTimeout, DefaultPropagationTimeout),
+		PollingInterval:    envSeconds(EnvPollingInterval, DefaultPollingInterval),
+	}
+}
+
+// DNSProvider implements the lego challenge.Provider interface.
+type DNSProvider struct {
+	config *Config
+	client *api.Client
+
+	mu      sync.Mutex
+	records map[string]*challengeRecord // token -> created record
+}
+
+// challengeRecord represents a TXT record created for a challenge.
+type challengeRecord struct {
+	zone   *api.Zone
+	record *api.Record
+}
+
+// NewDNSProvider returns a DNSProvider configured from the environment
+// (LUADNS_API_USERNAME, LUADNS_API_TOKEN).
+func NewDNSProvider() (*DNSProvider, error) {
+	return NewDNSProviderConfig(NewDefaultConfig())
+}
+
+// NewDNSProviderConfig returns a DNSProvider using the supplied configuration.
+func NewDNSProviderConfig(config *Config) (*DNSProvider, error) {
+	if config == nil {
+		return nil, errors.New("luadns: the configuration of the DNS provider is nil")
+	}
+
+	client := config.Client
+	if client == nil {
+		if config.Email == "" || config.APIKey == "" {
+			return nil, fmt.Errorf("luadns: some credentials information are missing: %s,%s", EnvAPIUsername, EnvAPIToken)
+		}
+		client = api.NewClient(config.Email, config.APIKey)
+		client.UserAgent("lego")
+	}
+
+	return &DNSProvider{config: config, client: client, records: map[string]*challengeRecord{}}, nil
+}
+
+// Timeout returns the timeout and interval used when checking propagation.
+func (d *DNSProvider) Timeout() (timeout, interval time.Duration) {
+	return d.config.PropagationTimeout, d.config.PollingInterval
+}
+
+// Present creates the TXT record fulfilling the DNS-01 challenge, the record
+// ID is remembered to be deleted by CleanUp.
+func (d *DNSProvider) Present(domain, token, keyAuth string) error {
+	ctx := context.Background()
+	fqdn, value := ChallengeRecord(domain, keyAuth)
+
+	zone, err := d.findZone(ctx, fqdn)
+	if err != nil {
+		return fmt.Errorf("luadns: %w", err)
+	}
+
+	record, err := d.client.CreateRecord(ctx, zone, &api.Record{Name: fqdn, Type: api.TypeTXT, Content: value, TTL: d.config.TTL})
+	if err != nil {
+		return fmt.Errorf("luadns: failed to create record: %w", err)
+	}
+
+	d.mu.Lock()
+	d.records[token] = &challengeRecord{zone: zone, record: record}
+	d.mu.Unlock()
+
+	return nil
+}
+
+// CleanUp deletes the TXT record created by Present for `token`, records of
+// other challenges on the same name are kept.
+func (d *DNSProvider) CleanUp(domain, token, keyAuth string) error {
+	d.mu.Lock()
+	rec, ok := d.records[token]
+	delete(d.records, token)
+	d.mu.Unlock()
+
+	if !ok {
+		fqdn, _ := ChallengeRecord(domain, keyAuth)
+		return fmt.Errorf("luadns: unknown record ID for '%s'", fqdn)
+	}
+
+	if _, err := d.client.DeleteRecord(context.Background(), rec.zone, rec.record.ID); err != nil {
+		return fmt.Errorf("luadns: failed to delete record: %w", err)
+	}
+	return nil
+}
+
+// findZone returns the account zone holding `fqdn` (longest match).
+func (d *DNSProvider) findZone(ctx context.Context, fqdn string) (*api.Zone, error) {
+	zones, err := d.client.ListAllZones(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	found := api.FindZone(zones, fqdn)
+	if found == nil {
+		return nil, fmt.Errorf("no zone found for %s", fqdn)
+	}
+	return found, nil
+}
+
+// ChallengeRecord returns the name and value of the DNS-01 challenge TXT
+// record (RFC 8555 section 8.4).
+func ChallengeRecord(domain, keyAuth string) (fqdn, value string) {
+	sum := sha256.Sum256([]byte(keyAuth))
+	domain = strings.TrimPrefix(domain, "*.")
+	return "_acme-challenge." + api.Fqdn(domain), base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// envInt returns the integer value of an environment variable.
+func envInt(key string, def int) int {
+	if n, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return n
+	}
+	return def
+}
+
+// envSeconds returns the duration in seconds of an environment variable.
+func envSeconds(key string, def time.Duration) time.Duration {
+	if n, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return time.Duration(n) * time.Second
+	}
+	return def
+}
@@ -0,0 +1,82 @@
+package luadns_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	api "github.com/luadns/luadns-go"
+	"github.com/luadns/luadns-go/internal/fakeapi"
+	"github.com/luadns/luadns-go/lego/luadns"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewDNSProvider(t *testing.T) {
+	t.Setenv(luadns.EnvAPIUsername, "")
+	t.Setenv(luadns.EnvAPIToken, "")
+	_, err := luadns.NewDNSProvider()
+	assert.EqualError(t, err, "luadns: some credentials information are missing: LUADNS_API_USERNAME,LUADNS_API_TOKEN")
+
+	t.Setenv(luadns.EnvAPIUsername, "joe@example.com")
+	t.Setenv(luadns.EnvAPIToken, "secret")
+	t.Setenv(luadns.EnvTTL, "60")
+	t.Setenv(luadns.EnvPropagationTimeout, "30")
+	p, err := luadns.NewDNSProvider()
+	assert.NoError(t, err)
+
+	timeout, interval := p.Timeout()
+	assert.Equal(t, 30*time.Second, timeout)
+	assert.Equal(t, luadns.DefaultPollingInterval, interval)
+}
+
+func TestDNSProviderPresentCleanUp(t *testing.T) {
+	server := fakeapi.New()
+	defer server.Close()
+
+	server.AddZone("example.org")
+	zone := server.AddZone("sub.example.org")
+	p, err := luadns.NewDNSProviderConfig(&luadns.Config{TTL: 60, Client: server.Client()})
+	assert.NoError(t, err)
+
+	// Wildcard and apex certificates share the challenge name.
+	var wg sync.WaitGroup
+	for _, domain := range []string{"www.sub.example.org", "*.www.sub.example.org"} {
+		wg.Add(1)
+		go func(domain string) {
+			defer wg.Done()
+			assert.NoError(t, p.Present(domain, domain, "auth-"+domain))
+		}(domain)
+	}
+	wg.Wait()
+
+	challenges := func() []*api.Record {
+		list := []*api.Record{}
+		for _, r := range server.Records(zone.ID) {
+			if r.Type == api.TypeTXT {
+				list = append(list, r)
+			}
+		}
+		return list
+	}
+	assert.Len(t, challenges(), 2)
+	assert.Equal(t, "_acme-challenge.www.sub.example.org.", challenges()[0].Name)
+	assert.Equal(t, uint32(60), challenges()[0].TTL)
+
+	assert.NoError(t, p.CleanUp("www.sub.example.org", "www.sub.example.org", "auth-www.sub.example.org"))
+	_, value := luadns.ChallengeRecord("*.www.sub.example.org", "auth-*.www.sub.example.org")
+	if assert.Len(t, challenges(), 1) {
+		assert.Equal(t, value, challenges()[0].Content)
+	}
+
+	err = p.CleanUp("www.sub.example.org", "www.sub.example.org", "auth-www.sub.example.org")
+	assert.EqualError(t, err, "luadns: unknown record ID for '_acme-challenge.www.sub.example.org.'")
+
+	err = p.Present("example.com", "token", "auth")
+	assert.EqualError(t, err, "luadns: no zone found for _acme-challenge.example.com.")
+}
+
+func TestChallengeRecord(t *testing.T) {
+	fqdn, value := luadns.ChallengeRecord("*.example.org", "token.thumbprint")
+	assert.Equal(t, "_acme-challenge.example.org.", fqdn)
+	assert.Equal(t, "61rBZ_4knHblO0MNoxFsXZ_eTFUHum0B6IVRbhvUn5I", value)
+}
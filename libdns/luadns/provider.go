@@ -0,0 +1,191 @@
+// Package luadns implements a libdns provider for LuaDNS.
+//
+// The provider implements the libdns RecordGetter, RecordAppender,
+// RecordSetter, RecordDeleter and ZoneLister interfaces. SetRecords replaces
+// whole (name, type) record sets in a single UpdateManyRecords call,
+// DeleteRecords uses a single DeleteManyRecords call.
+package luadns
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/libdns/libdns"
+	api "github.com/luadns/luadns-go"
+)
+
+// Provider facilitates DNS record manipulation with LuaDNS.
+type Provider struct {
+	Email  string `json:"email,omitempty"`
+	APIKey string `json:"api_key,omitempty"`
+
+	// Client overrides the API client built from Email and APIKey.
+	Client *api.Client `json:"-"`
+
+	mu    sync.Mutex
+	zones map[string]*api.Zone
+}
+
+// GetRecords lists all the records in the zone.
+func (p *Provider) GetRecords(ctx context.Context, zone string) ([]libdns.Record, error) {
+	z, err := p.zone(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := p.client().ListAllRecords(ctx, z)
+	if err != nil {
+		return nil, err
+	}
+
+	return toLibdns(zone, records), nil
+}
+
+// AppendRecords adds records to the zone, it returns the records that were added.
+func (p *Provider) AppendRecords(ctx context.Context, zone string, recs []libdns.Record) ([]libdns.Record, error) {
+	z, err := p.zone(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := p.client().CreateManyRecords(ctx, z, toRRs(zone, recs))
+	if err != nil {
+		return nil, err
+	}
+
+	return toLibdns(zone, records), nil
+}
+
+// SetRecords replaces the (name, type) record sets of the input records, in
+// a single atomic API call. It returns the records that were set.
+func (p *Provider) SetRecords(ctx context.Context, zone string, recs []libdns.Record) ([]libdns.Record, error) {
+	z, err := p.zone(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := p.client().UpdateManyRecords(ctx, z, toRRs(zone, recs))
+	if err != nil {
+		return nil, libdns.AtomicErr(err)
+	}
+
+	return toLibdns(zone, records), nil
+}
+
+// DeleteRecords deletes the records from the zone, empty type, TTL or value
+// match any record. It returns the records that were deleted.
+func (p *Provider) DeleteRecords(ctx context.Context, zone string, recs []libdns.Record) ([]libdns.Record, error) {
+	z, err := p.zone(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := p.client().DeleteManyRecords(ctx, z, toRRs(zone, recs))
+	if err != nil {
+		return nil, err
+	}
+
+	return toLibdns(zone, records), nil
+}
+
+// ListZones lists the zones of the account.
+func (p *Provider) ListZones(ctx context.Context) ([]libdns.Zone, error) {
+	zones, err := p.client().ListAllZones(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	list := []libdns.Zone{}
+	for _, z := range zones {
+		list = append(list, libdns.Zone{Name: api.Fqdn(z.Name)})
+	}
+	return list, nil
+}
+
+// client returns the API client.
+func (p *Provider) client() *api.Client {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.Client == nil {
+		p.Client = api.NewClient(p.Email, p.APIKey)
+	}
+	return p.Client
+}
+
+// zone returns the API zone named `name`, zones are cached.
+func (p *Provider) zone(ctx context.Context, name string) (*api.Zone, error) {
+	key := strings.ToLower(api.Fqdn(name))
+
+	p.mu.Lock()
+	z, ok := p.zones[key]
+	p.mu.Unlock()
+	if ok {
+		return z, nil
+	}
+
+	zones, err := p.client().ListAllZones(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.zones = map[string]*api.Zone{}
+	for _, z := range zones {
+		p.zones[strings.ToLower(api.Fqdn(z.Name))] = z
+	}
+
+	if z, ok := p.zones[key]; ok {
+		return z, nil
+	}
+	return nil, fmt.Errorf("zone %s not found", name)
+}
+
+// toRRs converts libdns records to API RRs using fully qualified names.
+func toRRs(zone string, recs []libdns.Record) []*api.RR {
+	rrs := []*api.RR{}
+	for _, rec := range recs {
+		rr := rec.RR()
+		rrs = append(rrs, &api.RR{
+			Name:    libdns.AbsoluteName(rr.Name, api.Fqdn(zone)),
+			Type:    rr.Type,
+			Content: rr.Data,
+			TTL:     uint32(rr.TTL / time.Second),
+		})
+	}
+	return rrs
+}
+
+// toLibdns converts API records to libdns records using relative names.
+func toLibdns(zone string, records []*api.Record) []libdns.Record {
+	recs := []libdns.Record{}
+	for _, r := range records {
+		rr := libdns.RR{
+			Name: libdns.RelativeName(api.Fqdn(r.Name), api.Fqdn(zone)),
+			Type: r.Type,
+			Data: r.Content,
+			TTL:  time.Duration(r.TTL) * time.Second,
+		}
+
+		rec, err := rr.Parse()
+		if err != nil {
+			recs = append(recs, rr)
+			continue
+		}
+		recs = append(recs, rec)
+	}
+	return recs
+}
+
+// Interface guards
+var (
+	_ libdns.RecordGetter   = (*Provider)(nil)
+	_ libdns.RecordAppender = (*Provider)(nil)
+	_ libdns.RecordSetter   = (*Provider)(nil)
+	_ libdns.RecordDeleter  = (*Provider)(nil)
+	_ libdns.ZoneLister     = (*Provider)(nil)
+)
@@ -0,0 +1,86 @@
+package luadns_test
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/libdns/libdns"
+	api "github.com/luadns/luadns-go"
+	"github.com/luadns/luadns-go/internal/fakeapi"
+	"github.com/luadns/luadns-go/libdns/luadns"
+	"github.com/stretchr/testify/assert"
+)
+
+func records(server *fakeapi.Server, zoneID int64) []string {
+	list := []string{}
+	for _, r := range server.Records(zoneID)[3:] {
+		list = append(list, r.String())
+	}
+	sort.Strings(list)
+	return list
+}
+
+func TestProvider(t *testing.T) {
+	server := fakeapi.New()
+	defer server.Close()
+
+	zone := server.AddZone("example.org",
+		&api.Record{Name: "example.org.", Type: "A", Content: "1.1.1.1", TTL: 300},
+		&api.Record{Name: "example.org.", Type: "A", Content: "2.2.2.2", TTL: 300},
+		&api.Record{Name: "example.org.", Type: "MX", Content: "10 mail.example.org.", TTL: 300},
+	)
+	p := &luadns.Provider{Client: server.Client()}
+	ctx := context.Background()
+
+	recs, err := p.GetRecords(ctx, "example.org.")
+	assert.NoError(t, err)
+	assert.Len(t, recs, 6)
+	assert.Contains(t, recs, libdns.MX{Name: "@", TTL: 300 * time.Second, Preference: 10, Target: "mail.example.org."})
+
+	appended, err := p.AppendRecords(ctx, "example.org.", []libdns.Record{
+		libdns.TXT{Name: "_acme-challenge", TTL: 60 * time.Second, Text: "token"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []libdns.Record{libdns.TXT{Name: "_acme-challenge", TTL: 60 * time.Second, Text: "token"}}, appended)
+
+	_, err = p.SetRecords(ctx, "example.org.", []libdns.Record{
+		libdns.RR{Name: "@", Type: "A", Data: "3.3.3.3", TTL: 300 * time.Second},
+	})
+	assert.NoError(t, err)
+
+	deleted, err := p.DeleteRecords(ctx, "example.org", []libdns.Record{libdns.RR{Name: "_acme-challenge", Type: "TXT"}})
+	assert.NoError(t, err)
+	assert.Len(t, deleted, 1)
+
+	assert.Equal(t, []string{
+		"example.org. 300 IN A 3.3.3.3",
+		"example.org. 300 IN MX 10 mail.example.org.",
+	}, records(server, zone.ID))
+
+	zones, err := p.ListZones(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []libdns.Zone{{Name: "example.org."}}, zones)
+
+	_, err = p.GetRecords(ctx, "example.net.")
+	assert.EqualError(t, err, "zone example.net. not found")
+}
+
+func TestProviderSetRecordsAtomic(t *testing.T) {
+	server := fakeapi.New()
+	defer server.Close()
+
+	server.AddZone("example.org")
+	server.Fail = func(r *http.Request) int {
+		if r.Method == http.MethodPatch {
+			return http.StatusBadRequest
+		}
+		return 0
+	}
+	p := &luadns.Provider{Client: server.Client()}
+
+	_, err := p.SetRecords(context.Background(), "example.org.", []libdns.Record{libdns.RR{Name: "www", Type: "A", Data: "1.1.1.1"}})
+	assert.EqualError(t, err, "Invalid data for content: rejected")
+}
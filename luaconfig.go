@@ -0,0 +1,214 @@
+package luadns
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// LuaOptions represents options used when exporting Lua zone configuration files.
+type LuaOptions struct {
+	TTL uint32 // default TTL, records using it are written without TTL argument
+}
+
+// luaFunc describes how record content maps to Lua helper function arguments.
+type luaFunc struct {
+	name    string
+	fields  int   // number of content fields (0 = whole content as a string)
+	order   []int // content fields order in function arguments
+	numbers []int // content fields written as numbers
+	target  int   // content field holding a domain name (-1 = none)
+}
+
+var luaFuncs = map[string]luaFunc{
+	TypeA:     {name: "a", target: -1},
+	TypeAAAA:  {name: "aaaa", target: -1},
+	TypeALIAS: {name: "alias", target: 0},
+	TypeCAA:   {name: "caa", fields: 3, order: []int{0, 1, 2}, numbers: []int{0}, target: -1},
+	TypeCNAME: {name: "cname", target: 0},
+	TypeDS:    {name: "ds", fields: 4, order: []int{0, 1, 2, 3}, numbers: []int{0, 1, 2}, target: -1},
+	TypeMX:    {name: "mx", fields: 2, order: []int{1, 0}, numbers: []int{0}, target: 1},
+	TypeNS:    {name: "ns", target: 0},
+	TypePTR:   {name: "ptr", target: 0},
+	TypeSPF:   {name: "spf", target: -1},
+	TypeSRV:   {name: "srv", fields: 4, order: []int{3, 2, 0, 1}, numbers: []int{0, 1, 2}, target: 3},
+	TypeSSHFP: {name: "sshfp", fields: 3, order: []int{0, 1, 2}, numbers: []int{0, 1}, target: -1},
+	TypeTLSA:  {name: "tlsa", fields: 4, order: []int{0, 1, 2, 3}, numbers: []int{0, 1, 2}, target: -1},
+	TypeTXT:   {name: "txt", target: -1},
+
+	TypeFORWARD:  {name: "forward", target: -1},
+	TypeREDIRECT: {name: "redirect", target: -1},
+	TypeSLAVE:    {name: "slave", target: -1},
+}
+
+// ExportLuaZones writes every zone as a Lua configuration file into `dir`
+// using the layout expected by LuaDNS git repositories (`<zone>.lua`).
+func ExportLuaZones(ctx context.Context, c *Client, dir string, opts *LuaOptions) error {
+	zones, err := c.ListAllZones(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, zone := range zones {
+		records, err := c.ListAllRecords(ctx, zone)
+		if err != nil {
+			return err
+		}
+
+		f, err := os.Create(filepath.Join(dir, LuaZoneFilename(zone)))
+		if err != nil {
+			return err
+		}
+
+		err = WriteLuaZone(f, zone, records, opts)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// LuaZoneFilename returns the zone file name used in LuaDNS git repositories.
+func LuaZoneFilename(zone *Zone) string {
+	return strings.TrimSuffix(zone.Name, ".") + ".lua"
+}
+
+// WriteLuaZone writes supplied records to `w` as a LuaDNS Lua zone file.
+// Records generated by LuaDNS (SOA, apex NS) are skipped.
+func WriteLuaZone(w io.Writer, zone *Zone, records []*Record, opts *LuaOptions) error {
+	if opts == nil {
+		opts = &LuaOptions{}
+	}
+	origin := Fqdn(zone.Name)
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "-- %s\n", strings.TrimSuffix(zone.Name, "."))
+	if len(zone.Tags) > 0 {
+		fmt.Fprintf(bw, "-- tags: %s\n", strings.Join(zone.Tags, ", "))
+	}
+	bw.WriteString("\n")
+
+	for _, r := range sortZoneRecords(origin, records) {
+		if r.IsGenerated(origin) {
+			continue
+		}
+		bw.WriteString(luaStatement(origin, r, opts.TTL))
+		bw.WriteString("\n")
+	}
+
+	return bw.Flush()
+}
+
+// luaStatement returns the Lua helper call creating record `r`.
+func luaStatement(origin string, r *Record, defaultTTL uint32) string {
+	fn, ok := luaFuncs[r.Type]
+	if !ok {
+		return fmt.Sprintf("-- unsupported record: %s %d %s %s", r.Name, r.TTL, r.Type, r.Content)
+	}
+
+	args := []string{luaName(origin, r.Name)}
+
+	fields := []string{r.Content}
+	if fn.fields > 0 {
+		fields = strings.SplitN(r.Content, " ", fn.fields)
+		if len(fields) != fn.fields {
+			return fmt.Sprintf("-- invalid record: %s %d %s %s", r.Name, r.TTL, r.Type, r.Content)
+		}
+	} else {
+		fn.order = []int{0}
+	}
+
+	for _, i := range fn.order {
+		v := fields[i]
+		switch {
+		case i == fn.target:
+			args = append(args, luaTarget(origin, v))
+		case containsInt(fn.numbers, i) && isNumber(v):
+			args = append(args, v)
+		case fn.fields > 0:
+			args = append(args, luaQuote(strings.Trim(v, `"`)))
+		default:
+			args = append(args, luaQuote(v))
+		}
+	}
+
+	if r.TTL != defaultTTL || defaultTTL == 0 {
+		args = append(args, strconv.FormatUint(uint64(r.TTL), 10))
+	}
+
+	return fn.name + "(" + strings.Join(args, ", ") + ")"
+}
+
+// luaName returns the owner name argument: `_a` for the zone apex, a relative
+// name for names inside the zone.
+func luaName(origin, name string) string {
+	rel := RelativeName(name, origin)
+	if rel == "@" {
+		return "_a"
+	}
+	return luaQuote(strings.TrimSuffix(rel, "."))
+}
+
+// luaTarget returns a domain name argument: `_a` for the zone apex, a relative
+// name for single labels inside the zone, a name without trailing dot otherwise.
+func luaTarget(origin, name string) string {
+	rel := RelativeName(name, origin)
+	switch {
+	case rel == "@":
+		return "_a"
+	case !strings.HasSuffix(rel, ".") && !strings.Contains(rel, "."):
+		return luaQuote(rel)
+	default:
+		return luaQuote(strings.TrimSuffix(name, "."))
+	}
+}
+
+// luaQuote returns `s` as a Lua string literal.
+func luaQuote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"', '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if c < 0x20 || c == 0x7f {
+				fmt.Fprintf(&b, `\%03d`, c)
+				continue
+			}
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+func isNumber(s string) bool {
+	_, err := strconv.ParseUint(s, 10, 32)
+	return err == nil
+}
+
+func containsInt(list []int, n int) bool {
+	for _, v := range list {
+		if v == n {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package luadns_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/luadns/luadns-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteLuaZone(t *testing.T) {
+	zone := &luadns.Zone{Name: "example.org", Tags: []string{"prod"}}
+	records := []*luadns.Record{
+		{Name: "example.org.", Type: "SOA", Content: "ns1.luadns.net. hostmaster.luadns.net. 1692975563 1200 120 604800 3600", TTL: 3600},
+		{Name: "example.org.", Type: "NS", Content: "ns1.luadns.net.", TTL: 86400},
+		{Name: "example.org.", Type: "A", Content: "1.1.1.1", TTL: 3600},
+		{Name: "example.org.", Type: "MX", Content: "10 mail.example.org.", TTL: 3600},
+		{Name: "example.org.", Type: "TXT", Content: `v=spf1 "a" mx ~all`, TTL: 300},
+		{Name: "_sip._udp.example.org.", Type: "SRV", Content: "0 5 5060 sip.example.com.", TTL: 3600},
+		{Name: "www.example.org.", Type: "CNAME", Content: "example.org.", TTL: 3600},
+		{Name: "mail.example.org.", Type: "CNAME", Content: "ghs.google.com.", TTL: 3600},
+		{Name: "example.org.", Type: "CAA", Content: `0 issue "letsencrypt.org"`, TTL: 3600},
+		{Name: "foo.example.org.", Type: "HINFO", Content: "x86 linux", TTL: 3600},
+	}
+
+	var buf bytes.Buffer
+	err := luadns.WriteLuaZone(&buf, zone, records, &luadns.LuaOptions{TTL: 3600})
+	assert.NoError(t, err)
+
+	expected := strings.Join([]string{
+		"-- example.org",
+		"-- tags: prod",
+		"",
+		"a(_a, \"1.1.1.1\")",
+		"caa(_a, 0, \"issue\", \"letsencrypt.org\")",
+		"mx(_a, \"mail\", 10)",
+		"txt(_a, \"v=spf1 \\\"a\\\" mx ~all\", 300)",
+		"srv(\"_sip._udp\", \"sip.example.com\", 5060, 0, 5)",
+		"-- unsupported record: foo.example.org. 3600 HINFO x86 linux",
+		"cname(\"mail\", \"ghs.google.com\")",
+		"cname(\"www\", _a)",
+		"",
+	}, "\n")
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestExportLuaZones(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/zones":
+			sendHTTPFixture(t, "/zones.index", w, r)
+		default:
+			sendHTTPFixture(t, "/zones/5/records.index", w, r)
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	c := luadns.NewClient("joe@example.com", "password", luadns.SetBaseURL(server.URL))
+	err := luadns.ExportLuaZones(context.Background(), c, dir, &luadns.LuaOptions{TTL: 86400})
+	assert.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(dir, "example.org.lua"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "mx(_a, \"aspmx.l.google.com\", 5)\n")
+	assert.Contains(t, string(data), "srv(\"_sip._udp\", \"sip.example.com\", 5060, 0, 0)\n")
+	assert.NotContains(t, string(data), "ns(")
+}
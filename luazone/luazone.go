@@ -0,0 +1,290 @@
+// Package luazone evaluates LuaDNS Lua zone configuration files locally.
+//
+// Zone scripts are run in a sandboxed Lua VM (only base, string, table and
+// math libraries are available) which implements the record helper functions
+// documented at https://www.luadns.com/help.html (`a`, `cname`, `mx`, `txt`,
+// `srv`, ...). Templates are Lua files evaluated before the zone script, they
+// usually define functions called from zone files.
+//
+// Example:
+//
+//	-- example.org.lua
+//	a(_a, "1.1.1.1")
+//	cname("www", _a)
+//	mx(_a, "mail", 10)
+package luazone
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	api "github.com/luadns/luadns-go"
+	lua "github.com/yuin/gopher-lua"
+)
+
+const (
+	DefaultTTL     = 3600             // TTL used by records without an explicit TTL
+	DefaultTimeout = 10 * time.Second // evaluation time limit
+)
+
+// Options represents evaluation options.
+type Options struct {
+	TTL       uint32        // default TTL, defaults to DefaultTTL
+	Templates string        // directory with templates (*.lua) evaluated before the zone script
+	Timeout   time.Duration // evaluation time limit (templates included), defaults to DefaultTimeout
+}
+
+// EvalFile evaluates a zone file, the zone name is derived from the file name
+// (`example.org.lua` configures `example.org`).
+func EvalFile(ctx context.Context, filename string, opts *Options) ([]*api.Record, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	zone := strings.TrimSuffix(filepath.Base(filename), ".lua")
+	return Eval(ctx, zone, filename, f, opts)
+}
+
+// Eval evaluates a zone script read from `r` and returns the configured records.
+// The `chunkname` is used in error messages. Scripts are stopped when `ctx` is
+// done or the evaluation takes longer than Options.Timeout.
+func Eval(ctx context.Context, zone, chunkname string, r io.Reader, opts *Options) ([]*api.Record, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	z := &zoneState{
+		name:   strings.TrimSuffix(strings.ToLower(zone), "."),
+		ttl:    opts.TTL,
+		origin: api.Fqdn(strings.ToLower(zone)),
+	}
+	if z.ttl == 0 {
+		z.ttl = DefaultTTL
+	}
+	timeout := opts.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+
+	L, err := z.newState()
+	if err != nil {
+		return nil, err
+	}
+	defer L.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	L.SetContext(ctx)
+
+	if opts.Templates != "" {
+		files, err := filepath.Glob(filepath.Join(opts.Templates, "*.lua"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(files)
+
+		for _, name := range files {
+			if err := L.DoFile(name); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	fn, err := L.Load(r, chunkname)
+	if err != nil {
+		return nil, err
+	}
+	L.Push(fn)
+	if err := L.PCall(0, lua.MultRet, nil); err != nil {
+		return nil, err
+	}
+
+	return z.records, nil
+}
+
+// zoneState holds the zone being evaluated.
+type zoneState struct {
+	name    string // zone name without trailing dot (value of `_a`)
+	origin  string // fully qualified zone name
+	ttl     uint32
+	records []*api.Record
+}
+
+func (z *zoneState) newState() (*lua.LState, error) {
+	L := lua.NewState(lua.Options{SkipOpenLibs: true})
+
+	libs := []struct {
+		name string
+		fn   lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+	}
+	for _, lib := range libs {
+		err := L.CallByParam(lua.P{Fn: L.NewFunction(lib.fn), NRet: 0, Protect: true}, lua.LString(lib.name))
+		if err != nil {
+			L.Close()
+			return nil, err
+		}
+	}
+
+	// Scripts can't read files outside of templates.
+	for _, name := range []string{"dofile", "loadfile", "load", "loadstring"} {
+		L.SetGlobal(name, lua.LNil)
+	}
+
+	L.SetGlobal("_a", lua.LString(z.name))
+	L.SetGlobal("concat", L.NewFunction(concat))
+	for name, spec := range helpers {
+		L.SetGlobal(name, L.NewFunction(z.helper(spec)))
+	}
+
+	return L, nil
+}
+
+// concat joins two names: concat("www", _a) == "www.example.org".
+func concat(L *lua.LState) int {
+	a := strings.TrimSuffix(L.CheckString(1), ".")
+	b := L.CheckString(2)
+	L.Push(lua.LString(a + "." + b))
+	return 1
+}
+
+// ownerName converts a name argument to a fully qualified name. Names are
+// relative to the zone unless they end with a dot or with the zone name.
+func (z *zoneState) ownerName(name string) string {
+	name = strings.ToLower(name)
+	switch {
+	case name == "" || name == "@" || name == z.name:
+		return z.origin
+	case strings.HasSuffix(name, "."):
+		return name
+	case strings.HasSuffix(name, "."+z.name):
+		return name + "."
+	default:
+		return name + "." + z.origin
+	}
+}
+
+// targetName converts a domain name argument (CNAME, MX, SRV targets) to a
+// fully qualified name. Single labels are relative to the zone, other names
+// are absolute.
+func (z *zoneState) targetName(name string) string {
+	if !strings.Contains(strings.TrimSuffix(name, "."), ".") && !strings.HasSuffix(name, ".") {
+		return z.ownerName(name)
+	}
+	return api.Fqdn(name)
+}
+
+// argKind represents the kind of a helper function argument.
+type argKind int
+
+const (
+	argString argKind = iota
+	argNumber
+	argTarget
+	argQuoted // string written quoted in record content (CAA value)
+)
+
+// argSpec describes a helper function argument.
+type argSpec struct {
+	kind     argKind
+	optional bool // optional numbers default to 0
+}
+
+// helperSpec describes a record helper function: `fn(name, args..., ttl)`.
+type helperSpec struct {
+	typ   string
+	args  []argSpec
+	order []int // arguments order in record content, defaults to arguments order
+}
+
+var helpers = map[string]helperSpec{
+	"a":        {typ: api.TypeA, args: []argSpec{{kind: argString}}},
+	"aaaa":     {typ: api.TypeAAAA, args: []argSpec{{kind: argString}}},
+	"alias":    {typ: api.TypeALIAS, args: []argSpec{{kind: argTarget}}},
+	"caa":      {typ: api.TypeCAA, args: []argSpec{{kind: argNumber}, {kind: argString}, {kind: argQuoted}}},
+	"cname":    {typ: api.TypeCNAME, args: []argSpec{{kind: argTarget}}},
+	"ds":       {typ: api.TypeDS, args: []argSpec{{kind: argNumber}, {kind: argNumber}, {kind: argNumber}, {kind: argString}}},
+	"forward":  {typ: api.TypeFORWARD, args: []argSpec{{kind: argString}}},
+	"mx":       {typ: api.TypeMX, args: []argSpec{{kind: argTarget}, {kind: argNumber, optional: true}}, order: []int{1, 0}},
+	"ns":       {typ: api.TypeNS, args: []argSpec{{kind: argTarget}}},
+	"ptr":      {typ: api.TypePTR, args: []argSpec{{kind: argTarget}}},
+	"redirect": {typ: api.TypeREDIRECT, args: []argSpec{{kind: argString}}},
+	"slave":    {typ: api.TypeSLAVE, args: []argSpec{{kind: argString}}},
+	"spf":      {typ: api.TypeSPF, args: []argSpec{{kind: argString}}},
+	"srv": {typ: api.TypeSRV, args: []argSpec{
+		{kind: argTarget}, {kind: argNumber}, {kind: argNumber, optional: true}, {kind: argNumber, optional: true},
+	}, order: []int{2, 3, 1, 0}},
+	"sshfp": {typ: api.TypeSSHFP, args: []argSpec{{kind: argNumber}, {kind: argNumber}, {kind: argString}}},
+	"tlsa":  {typ: api.TypeTLSA, args: []argSpec{{kind: argNumber}, {kind: argNumber}, {kind: argNumber}, {kind: argString}}},
+	"txt":   {typ: api.TypeTXT, args: []argSpec{{kind: argString}}},
+}
+
+// quoteEscaper escapes quoted strings of record content, like zone files.
+var quoteEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// helper returns the Lua function adding records described by `spec`.
+func (z *zoneState) helper(spec helperSpec) lua.LGFunction {
+	return func(L *lua.LState) int {
+		name := z.ownerName(L.CheckString(1))
+
+		values := make([]string, len(spec.args))
+		for i, arg := range spec.args {
+			n := i + 2
+			switch arg.kind {
+			case argNumber:
+				if arg.optional && L.Get(n) == lua.LNil {
+					values[i] = "0"
+					continue
+				}
+				values[i] = fmt.Sprintf("%d", L.CheckInt(n))
+			case argTarget:
+				values[i] = z.targetName(L.CheckString(n))
+			case argQuoted:
+				values[i] = `"` + quoteEscaper.Replace(L.CheckString(n)) + `"`
+			default:
+				values[i] = L.CheckString(n)
+			}
+		}
+
+		ttl := z.ttl
+		if v := L.Get(len(spec.args) + 2); v != lua.LNil {
+			n := L.CheckInt(len(spec.args) + 2)
+			if n < 0 {
+				L.ArgError(len(spec.args)+2, "invalid TTL")
+			}
+			ttl = uint32(n)
+		}
+
+		order := spec.order
+		if order == nil {
+			order = make([]int, len(values))
+			for i := range order {
+				order[i] = i
+			}
+		}
+		fields := make([]string, len(order))
+		for i, idx := range order {
+			fields[i] = values[idx]
+		}
+
+		z.records = append(z.records, &api.Record{
+			Name:    name,
+			Type:    spec.typ,
+			Content: strings.Join(fields, " "),
+			TTL:     ttl,
+		})
+		return 0
+	}
+}
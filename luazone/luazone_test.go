@@ -0,0 +1,86 @@
+package luazone_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	api "github.com/luadns/luadns-go"
+	"github.com/luadns/luadns-go/luazone"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvalFile(t *testing.T) {
+	records, err := luazone.EvalFile(context.Background(), "testdata/example.org.lua", &luazone.Options{Templates: "testdata/templates"})
+	assert.NoError(t, err)
+
+	expected := []*api.Record{
+		{Name: "example.org.", Type: "A", Content: "1.1.1.1", TTL: 3600},
+		{Name: "example.org.", Type: "AAAA", Content: "2001:db8::1", TTL: 300},
+		{Name: "www.example.org.", Type: "CNAME", Content: "example.org.", TTL: 3600},
+		{Name: "sub.example.org.", Type: "NS", Content: "ns1.example.net.", TTL: 3600},
+		{Name: "_sip._udp.example.org.", Type: "SRV", Content: "10 20 5060 sip.example.org.", TTL: 3600},
+		{Name: "example.org.", Type: "TXT", Content: "v=spf1 include:_spf.google.com ~all", TTL: 3600},
+		{Name: "example.org.", Type: "CAA", Content: `0 issue "letsencrypt.org"`, TTL: 3600},
+		{Name: "web1.example.org.", Type: "A", Content: "10.0.0.1", TTL: 3600},
+		{Name: "web2.example.org.", Type: "A", Content: "10.0.0.2", TTL: 3600},
+		{Name: "example.org.", Type: "MX", Content: "1 aspmx.l.google.com.", TTL: 3600},
+		{Name: "example.org.", Type: "MX", Content: "5 alt1.aspmx.l.google.com.", TTL: 3600},
+		{Name: "mail.example.org.", Type: "CNAME", Content: "ghs.google.com.", TTL: 3600},
+	}
+	assert.Equal(t, expected, records)
+}
+
+func TestEvalErrors(t *testing.T) {
+	tests := []struct {
+		script string
+		err    string
+	}{
+		{`a(_a)`, "example.org.lua:1: bad argument #2 to a (string expected, got nil)"},
+		{`mx(_a, "mail", "high")`, "bad argument #3 to mx (number expected, got string)"},
+		{`dofile("/etc/passwd")`, "attempt to call a non-function object"},
+		{`a(_a, "1.1.1.1"`, "example.org.lua at EOF:   syntax error"},
+	}
+
+	for _, test := range tests {
+		_, err := luazone.Eval(context.Background(), "example.org", "example.org.lua", strings.NewReader(test.script), nil)
+		if assert.Error(t, err, test.script) {
+			assert.Contains(t, err.Error(), test.err, test.script)
+		}
+	}
+}
+
+func TestEvalTimeout(t *testing.T) {
+	_, err := luazone.Eval(context.Background(), "example.org", "example.org.lua", strings.NewReader(`while true do end`), &luazone.Options{Timeout: 50 * time.Millisecond})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "context deadline exceeded")
+	}
+}
+
+func TestEvalQuoted(t *testing.T) {
+	records, err := luazone.Eval(context.Background(), "example.org", "example.org.lua", strings.NewReader(`caa(_a, 0, "iodef", 'mailto:"a"\\b')`), nil)
+	assert.NoError(t, err)
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, `0 iodef "mailto:\"a\"\\b"`, records[0].Content)
+	}
+}
+
+func TestEvalExportedZone(t *testing.T) {
+	records := []*api.Record{
+		{Name: "example.org.", Type: "A", Content: "1.1.1.1", TTL: 3600},
+		{Name: "example.org.", Type: "MX", Content: "10 mail.example.org.", TTL: 3600},
+		{Name: "example.org.", Type: "TXT", Content: `v=spf1 "a" ~all`, TTL: 300},
+		{Name: "_sip._udp.example.org.", Type: "SRV", Content: "0 5 5060 sip.example.com.", TTL: 3600},
+		{Name: "www.example.org.", Type: "CNAME", Content: "example.org.", TTL: 3600},
+	}
+
+	var buf bytes.Buffer
+	err := api.WriteLuaZone(&buf, &api.Zone{Name: "example.org"}, records, &api.LuaOptions{TTL: 3600})
+	assert.NoError(t, err)
+
+	evaluated, err := luazone.Eval(context.Background(), "example.org", "example.org.lua", &buf, nil)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, records, evaluated)
+}
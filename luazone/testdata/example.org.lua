@@ -0,0 +1,14 @@
+-- example.org
+a(_a, "1.1.1.1")
+aaaa(_a, "2001:db8::1", 300)
+cname("www", _a)
+ns("sub", "ns1.example.net")
+srv("_sip._udp", "sip", 5060, 10, 20)
+txt(_a, "v=spf1 include:_spf.google.com ~all")
+caa(_a, 0, "issue", "letsencrypt.org")
+
+for i = 1, 2 do
+  a(concat("web" .. i, _a), "10.0.0." .. i)
+end
+
+google_app(_a)
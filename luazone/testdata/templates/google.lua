@@ -0,0 +1,6 @@
+-- Google Workspace mail servers.
+function google_app(domain)
+  mx(domain, "aspmx.l.google.com", 1)
+  mx(domain, "alt1.aspmx.l.google.com", 5)
+  cname(concat("mail", domain), "ghs.google.com")
+end
@@ -0,0 +1,140 @@
+package luadns
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+)
+
+// PlanVersion is the plan file format version.
+const PlanVersion = 1
+
+// Plan represents changes computed against an observed zone state. A plan is
+// applied only if the zone state did not change since the plan was created.
+type Plan struct {
+	Version   int       `json:"version"`
+	ZoneID    int64     `json:"zone_id"`
+	Zone      string    `json:"zone"`
+	CreatedAt time.Time `json:"created_at"`
+	StateHash string    `json:"state_hash"` // hash of observed records and UpdatedAt values
+	Changes   ChangeSet `json:"changes"`
+}
+
+// ErrStalePlan represents an error returned when applying a plan to a zone
+// changed since the plan was created.
+type ErrStalePlan struct {
+	Zone     string
+	Expected string
+	Actual   string
+}
+
+func (e *ErrStalePlan) Error() string {
+	return "Zone " + e.Zone + " changed since the plan was created"
+}
+
+// CreatePlan computes the changes turning `zone` records into `desired` records.
+func (c *Client) CreatePlan(ctx context.Context, zone *Zone, desired []*Record, opts *DiffOptions) (*Plan, error) {
+	state, err := c.GetZone(ctx, zone.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts == nil {
+		opts = &DiffOptions{}
+	}
+	if opts.Zone == "" {
+		o := *opts
+		o.Zone = state.Name
+		opts = &o
+	}
+
+	return &Plan{
+		Version:   PlanVersion,
+		ZoneID:    state.ID,
+		Zone:      state.Name,
+		CreatedAt: time.Now().UTC(),
+		StateHash: StateHash(state),
+		Changes:   Diff(desired, state.Records, opts),
+	}, nil
+}
+
+// ApplyPlan applies the plan changes, it refuses to apply the plan with
+// ErrStalePlan when the zone changed since the plan was created.
+func (c *Client) ApplyPlan(ctx context.Context, plan *Plan) ([]*ChangeResult, error) {
+	if plan.Version != PlanVersion {
+		return nil, fmt.Errorf("unsupported plan version %d", plan.Version)
+	}
+
+	state, err := c.GetZone(ctx, plan.ZoneID)
+	if err != nil {
+		return nil, err
+	}
+
+	if hash := StateHash(state); hash != plan.StateHash {
+		return nil, &ErrStalePlan{Zone: state.Name, Expected: plan.StateHash, Actual: hash}
+	}
+
+	return c.ApplyChanges(ctx, state, plan.Changes)
+}
+
+// StateHash returns a hash of the zone state: zone ID, UpdatedAt and records.
+func StateHash(zone *Zone) string {
+	records := make([]*Record, len(zone.Records))
+	copy(records, zone.Records)
+	sort.Slice(records, func(i, j int) bool { return records[i].ID < records[j].ID })
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%d %s\n", zone.ID, zone.UpdatedAt.UTC().Format(time.RFC3339Nano))
+	for _, r := range records {
+		fmt.Fprintf(h, "%d %q %q %q %d %s\n", r.ID, r.Name, r.Type, r.Content, r.TTL, r.UpdatedAt.UTC().Format(time.RFC3339Nano))
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Write writes the plan as indented JSON.
+func (p *Plan) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(p)
+}
+
+// WriteFile writes the plan to a file.
+func (p *Plan) WriteFile(filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	err = p.Write(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// ReadPlan reads a plan written by Plan.Write.
+func ReadPlan(r io.Reader) (*Plan, error) {
+	var plan Plan
+	if err := json.NewDecoder(r).Decode(&plan); err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+// ReadPlanFile reads a plan file written by Plan.WriteFile.
+func ReadPlanFile(filename string) (*Plan, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadPlan(f)
+}
@@ -0,0 +1,65 @@
+package luadns_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/luadns/luadns-go"
+	"github.com/luadns/luadns-go/internal/fakeapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanApply(t *testing.T) {
+	server := fakeapi.New()
+	defer server.Close()
+
+	zone := server.AddZone("example.org", &luadns.Record{Name: "www.example.org.", Type: "A", Content: "1.1.1.1", TTL: 3600})
+	c := server.Client()
+	ctx := context.Background()
+
+	desired := []*luadns.Record{{Name: "www.example.org.", Type: "A", Content: "2.2.2.2", TTL: 3600}}
+	plan, err := c.CreatePlan(ctx, zone, desired, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "example.org", plan.Zone)
+	assert.Len(t, plan.Changes.Changes, 1)
+
+	filename := filepath.Join(t.TempDir(), "plan.json")
+	assert.NoError(t, plan.WriteFile(filename))
+
+	loaded, err := luadns.ReadPlanFile(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, plan.StateHash, loaded.StateHash)
+
+	results, err := c.ApplyPlan(ctx, loaded)
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+	assert.Equal(t, "2.2.2.2", server.Records(zone.ID)[3].Content)
+
+	// The zone changed, the plan is now stale.
+	_, err = c.ApplyPlan(ctx, loaded)
+	assert.IsType(t, &luadns.ErrStalePlan{}, err)
+	assert.EqualError(t, err, "Zone example.org changed since the plan was created")
+}
+
+func TestPlanApplyConcurrentChange(t *testing.T) {
+	server := fakeapi.New()
+	defer server.Close()
+
+	zone := server.AddZone("example.org", &luadns.Record{Name: "www.example.org.", Type: "A", Content: "1.1.1.1", TTL: 3600})
+	c := server.Client()
+	ctx := context.Background()
+
+	desired := []*luadns.Record{{Name: "www.example.org.", Type: "A", Content: "2.2.2.2", TTL: 3600}}
+	plan, err := c.CreatePlan(ctx, zone, desired, nil)
+	assert.NoError(t, err)
+
+	record := server.Records(zone.ID)[3]
+	server.Touch(zone.ID, record.ID, "3.3.3.3")
+
+	server.ResetRequests()
+	_, err = c.ApplyPlan(ctx, plan)
+	assert.IsType(t, &luadns.ErrStalePlan{}, err)
+	assert.Equal(t, []string{"GET /zones/101"}, server.Requests())
+	assert.Equal(t, "3.3.3.3", server.Records(zone.ID)[3].Content)
+}
@@ -0,0 +1,244 @@
+// Package propagation waits for LuaDNS changes to reach the name servers.
+//
+// It queries the name servers directly, keeping the DNS dependency out of
+// the API client:
+//
+//	err := propagation.Wait(ctx, c, record, &propagation.Options{Serial: serial})
+package propagation
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	api "github.com/luadns/luadns-go"
+	"github.com/miekg/dns"
+)
+
+const (
+	DefaultTimeout  = 2 * time.Minute // time waited by Wait
+	DefaultInterval = 2 * time.Second // delay between polls
+)
+
+// Options represents options used by Wait.
+type Options struct {
+	NameServers []string      // servers queried (host or host:port), defaults to the account name servers
+	Net         string        // "udp" (retried over TCP when truncated) or "tcp", defaults to "udp"
+	Serial      uint32        // minimum SOA serial, not checked when zero
+	Absent      bool          // wait until the record is removed instead
+	Timeout     time.Duration // defaults to DefaultTimeout
+	Interval    time.Duration // defaults to DefaultInterval
+}
+
+// NameServerStatus represents the answer of a name server.
+type NameServerStatus struct {
+	NameServer string
+	Found      bool   // record found with the expected content
+	Serial     uint32 // zone SOA serial
+	Err        error  // query error
+	Pending    string // reason the record isn't propagated yet, empty once propagated
+}
+
+// ErrTimeout represents an error returned when name servers didn't
+// agree before the timeout.
+type ErrTimeout struct {
+	Name     string
+	Type     string
+	Statuses []*NameServerStatus
+}
+
+func (e *ErrTimeout) Error() string {
+	pending := []string{}
+	for _, s := range e.Statuses {
+		if s.Pending != "" {
+			pending = append(pending, s.NameServer+": "+s.Pending)
+		}
+	}
+	return "Propagation of " + e.Name + " " + e.Type + " timed out (" + strings.Join(pending, ", ") + ")"
+}
+
+// Wait queries every name server directly (no recursion) until they all
+// answer `record` with its content (or don't answer it with opts.Absent) and
+// report the same SOA serial, at least opts.Serial.
+//
+// Name servers default to the account name servers (User.NameServers) read
+// with `c`. An *ErrTimeout is returned when the timeout expires.
+func Wait(ctx context.Context, c *api.Client, record *api.Record, opts *Options) error {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	qtype, ok := dns.StringToType[strings.ToUpper(record.Type)]
+	if !ok {
+		return errors.New("record type " + record.Type + " can't be queried")
+	}
+
+	servers := opts.NameServers
+	if len(servers) == 0 {
+		user, err := c.Me(ctx)
+		if err != nil {
+			return err
+		}
+		servers = user.NameServers
+	}
+	if len(servers) == 0 {
+		return errors.New("no name servers to query")
+	}
+
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+
+	pollCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var last []*NameServerStatus
+	for {
+		statuses := make([]*NameServerStatus, len(servers))
+		var wg sync.WaitGroup
+		for i, ns := range servers {
+			wg.Add(1)
+			go func(i int, ns string) {
+				defer wg.Done()
+				statuses[i] = queryNameServer(pollCtx, nameServerAddr(ns), opts.Net, record, qtype)
+			}(i, ns)
+		}
+		wg.Wait()
+
+		if propagated(statuses, opts) {
+			return nil
+		}
+		// Queries interrupted by the timeout don't replace the last answers.
+		if pollCtx.Err() == nil || last == nil {
+			last = statuses
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-pollCtx.Done():
+			return &ErrTimeout{Name: api.Fqdn(record.Name), Type: record.Type, Statuses: last}
+		case <-time.After(interval):
+		}
+	}
+}
+
+// propagated sets the pending reason of every status and reports whether all
+// name servers agree.
+func propagated(statuses []*NameServerStatus, opts *Options) bool {
+	serial := opts.Serial
+	for _, s := range statuses {
+		if s.Err == nil && s.Serial > serial {
+			serial = s.Serial
+		}
+	}
+
+	ok := true
+	for _, s := range statuses {
+		switch {
+		case s.Err != nil:
+			s.Pending = s.Err.Error()
+		case opts.Absent && s.Found:
+			s.Pending = "record still present"
+		case !opts.Absent && !s.Found:
+			s.Pending = "record not found"
+		case serial != 0 && s.Serial != serial:
+			s.Pending = "serial " + strconv.FormatUint(uint64(s.Serial), 10) + ", expected " + strconv.FormatUint(uint64(serial), 10)
+		default:
+			s.Pending = ""
+		}
+		ok = ok && s.Pending == ""
+	}
+	return ok
+}
+
+// queryNameServer queries a name server for the record and the zone SOA serial.
+func queryNameServer(ctx context.Context, addr, network string, record *api.Record, qtype uint16) *NameServerStatus {
+	status := &NameServerStatus{NameServer: addr}
+	name := dns.Fqdn(strings.ToLower(record.Name))
+
+	r, err := exchange(ctx, addr, network, name, qtype)
+	if err != nil {
+		status.Err = err
+		return status
+	}
+	for _, rr := range r.Answer {
+		if rr.Header().Rrtype == qtype && strings.EqualFold(rr.Header().Name, name) && sameContent(rr, record.Content) {
+			status.Found = true
+		}
+	}
+
+	// Authoritative servers return the zone SOA in the authority section
+	// for names below the apex.
+	r, err = exchange(ctx, addr, network, name, dns.TypeSOA)
+	if err != nil {
+		status.Err = err
+		return status
+	}
+	for _, rr := range append(r.Answer, r.Ns...) {
+		if soa, ok := rr.(*dns.SOA); ok {
+			status.Serial = soa.Serial
+			return status
+		}
+	}
+	status.Err = errors.New("no SOA record returned")
+	return status
+}
+
+// exchange sends a non recursive query, UDP queries are retried over TCP when
+// the answer is truncated.
+func exchange(ctx context.Context, addr, network, name string, qtype uint16) (*dns.Msg, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(name, qtype)
+	m.RecursionDesired = false
+
+	if network == "" {
+		network = "udp"
+	}
+	r, _, err := (&dns.Client{Net: network}).ExchangeContext(ctx, m, addr)
+	if err == nil && r.Truncated && network == "udp" {
+		r, _, err = (&dns.Client{Net: "tcp"}).ExchangeContext(ctx, m, addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if r.Rcode != dns.RcodeSuccess && r.Rcode != dns.RcodeNameError {
+		return nil, errors.New("query failed with " + dns.RcodeToString[r.Rcode])
+	}
+	return r, nil
+}
+
+// nameServerAddr appends the DNS port to a name server without port.
+func nameServerAddr(ns string) string {
+	if _, _, err := net.SplitHostPort(ns); err == nil {
+		return ns
+	}
+	return net.JoinHostPort(strings.TrimSuffix(ns, "."), "53")
+}
+
+// sameContent reports whether the data of `rr` matches the API record
+// content. TXT and SPF strings are compared joined, other contents are parsed
+// so names and addresses are compared in canonical form.
+func sameContent(rr dns.RR, content string) bool {
+	switch rr.(type) {
+	case *dns.TXT, *dns.SPF:
+		return RRContent(rr) == content
+	}
+
+	h := rr.Header()
+	want, err := dns.NewRR(h.Name + " IN " + dns.TypeToString[h.Rrtype] + " " + content)
+	if err != nil || want == nil {
+		return false
+	}
+	return dns.IsDuplicate(rr, want)
+}
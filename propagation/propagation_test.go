@@ -0,0 +1,136 @@
+package propagation_test
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/luadns/luadns-go"
+	"github.com/luadns/luadns-go/propagation"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+// nameServer represents a local authoritative server of example.org.
+type nameServer struct {
+	mu      sync.Mutex
+	serial  uint32
+	records []dns.RR
+}
+
+func (ns *nameServer) set(serial uint32, records ...string) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	ns.serial = serial
+	ns.records = nil
+	for _, s := range records {
+		rr, _ := dns.NewRR(s)
+		ns.records = append(ns.records, rr)
+	}
+}
+
+func (ns *nameServer) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Authoritative = true
+
+	q := r.Question[0]
+	soa := &dns.SOA{
+		Hdr:    dns.RR_Header{Name: "example.org.", Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: 300},
+		Ns:     "ns1.example.org.",
+		Mbox:   "hostmaster.example.org.",
+		Serial: ns.serial,
+	}
+	for _, rr := range ns.records {
+		if rr.Header().Name == q.Name && rr.Header().Rrtype == q.Qtype {
+			m.Answer = append(m.Answer, rr)
+		}
+	}
+	if q.Qtype == dns.TypeSOA && q.Name == "example.org." {
+		m.Answer = append(m.Answer, soa)
+	}
+	if len(m.Answer) == 0 {
+		m.Ns = append(m.Ns, soa)
+	}
+	w.WriteMsg(m)
+}
+
+func startNameServer(t *testing.T, ns *nameServer) string {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	l, err := net.Listen("tcp", pc.LocalAddr().String())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	for _, srv := range []*dns.Server{{PacketConn: pc, Handler: ns}, {Listener: l, Handler: ns}} {
+		started := make(chan struct{})
+		srv.NotifyStartedFunc = func() { close(started) }
+		go srv.ActivateAndServe()
+		<-started
+		t.Cleanup(func() { srv.Shutdown() })
+	}
+
+	return pc.LocalAddr().String()
+}
+
+func TestWait(t *testing.T) {
+	ns1, ns2 := &nameServer{}, &nameServer{}
+	ns1.set(2, "www.example.org. 300 IN A 2.2.2.2")
+	ns2.set(1, "www.example.org. 300 IN A 1.1.1.1")
+	servers := []string{startNameServer(t, ns1), startNameServer(t, ns2)}
+
+	c := luadns.NewClient("joe@example.com", "password")
+	ctx := context.Background()
+	record := &luadns.Record{Name: "www.example.org.", Type: "A", Content: "2.2.2.2", TTL: 300}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		ns2.set(2, "www.example.org. 300 IN A 2.2.2.2")
+	}()
+	err := propagation.Wait(ctx, c, record, &propagation.Options{NameServers: servers, Interval: 10 * time.Millisecond, Timeout: 5 * time.Second})
+	assert.NoError(t, err)
+
+	// TXT contents are compared joined.
+	ns1.set(3, `_acme-challenge.example.org. 60 IN TXT "to" "ken"`)
+	ns2.set(3, `_acme-challenge.example.org. 60 IN TXT "token"`)
+	txt := &luadns.Record{Name: "_acme-challenge.example.org.", Type: "TXT", Content: "token", TTL: 60}
+	err = propagation.Wait(ctx, c, txt, &propagation.Options{NameServers: servers, Net: "tcp", Serial: 3, Timeout: time.Second})
+	assert.NoError(t, err)
+
+	// Deletions.
+	ns1.set(4)
+	ns2.set(4)
+	err = propagation.Wait(ctx, c, txt, &propagation.Options{NameServers: servers, Absent: true, Timeout: time.Second})
+	assert.NoError(t, err)
+}
+
+func TestWaitTimeout(t *testing.T) {
+	ns1, ns2 := &nameServer{}, &nameServer{}
+	ns1.set(2, "www.example.org. 300 IN A 2.2.2.2")
+	ns2.set(1, "www.example.org. 300 IN A 2.2.2.2")
+	servers := []string{startNameServer(t, ns1), startNameServer(t, ns2)}
+
+	c := luadns.NewClient("joe@example.com", "password")
+	record := &luadns.Record{Name: "www.example.org.", Type: "A", Content: "2.2.2.2", TTL: 300}
+
+	err := propagation.Wait(context.Background(), c, record, &propagation.Options{NameServers: servers, Interval: 10 * time.Millisecond, Timeout: 100 * time.Millisecond})
+	if assert.IsType(t, &propagation.ErrTimeout{}, err) {
+		assert.EqualError(t, err, "Propagation of www.example.org. A timed out ("+servers[1]+": serial 1, expected 2)")
+	}
+
+	err = propagation.Wait(context.Background(), c, record, &propagation.Options{NameServers: servers, Absent: true, Timeout: 100 * time.Millisecond})
+	assert.ErrorContains(t, err, servers[0]+": record still present")
+
+	err = propagation.Wait(context.Background(), c, &luadns.Record{Name: "www.example.org.", Type: "REDIRECT"}, nil)
+	assert.EqualError(t, err, "record type REDIRECT can't be queried")
+}
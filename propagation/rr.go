@@ -0,0 +1,19 @@
+package propagation
+
+import (
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// RRContent returns the data of a DNS resource record in the API content
+// format, TXT and SPF strings are joined.
+func RRContent(rr dns.RR) string {
+	switch rr := rr.(type) {
+	case *dns.TXT:
+		return strings.Join(rr.Txt, "")
+	case *dns.SPF:
+		return strings.Join(rr.Txt, "")
+	}
+	return strings.TrimSpace(strings.TrimPrefix(rr.String(), rr.Header().String()))
+}
@@ -0,0 +1,28 @@
+package propagation_test
+
+import (
+	"testing"
+
+	"github.com/luadns/luadns-go/propagation"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRRContent(t *testing.T) {
+	tests := []struct {
+		rr      string
+		content string
+	}{
+		{"www.example.org. 300 IN A 1.1.1.1", "1.1.1.1"},
+		{"example.org. 300 IN MX 10 mail.example.org.", "10 mail.example.org."},
+		{`example.org. 300 IN TXT "hello " "world"`, "hello world"},
+		{`example.org. 300 IN SPF "v=spf1 -all"`, "v=spf1 -all"},
+		{`example.org. 300 IN CAA 0 issue "letsencrypt.org"`, `0 issue "letsencrypt.org"`},
+	}
+	for _, tt := range tests {
+		rr, err := dns.NewRR(tt.rr)
+		if assert.NoError(t, err) {
+			assert.Equal(t, tt.content, propagation.RRContent(rr), tt.rr)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package luadns
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// maxRateLimitRetries is the number of times a call is retried after the
+// API reported the requests quota was exceeded.
+const maxRateLimitRetries = 5
+
+// rateLimiter is shared by concurrent workers, once a call fails with
+// ErrTooManyRequests all workers pause until the quota is reset. Calls are
+// spaced by `interval` when set.
+type rateLimiter struct {
+	interval time.Duration
+
+	mu    sync.Mutex
+	until time.Time // quota reset time
+	next  time.Time // next call time when spacing calls
+}
+
+// wait blocks until the quota is reset and the next call slot is reached.
+func (l *rateLimiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	at := l.until
+	if l.interval > 0 {
+		if l.next.After(at) {
+			at = l.next
+		}
+		slot := at
+		if now := time.Now(); slot.Before(now) {
+			slot = now
+		}
+		l.next = slot.Add(l.interval)
+	}
+	d := time.Until(at)
+	l.mu.Unlock()
+
+	if d <= 0 {
+		return nil
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// do calls `fn`, the call is retried after the quota reset when it fails
+// with ErrTooManyRequests.
+func (l *rateLimiter) do(ctx context.Context, fn func() error) error {
+	for i := 0; ; i++ {
+		if err := l.wait(ctx); err != nil {
+			return err
+		}
+
+		err := fn()
+		var tooMany *ErrTooManyRequests
+		if !errors.As(err, &tooMany) || i >= maxRateLimitRetries {
+			return err
+		}
+
+		l.mu.Lock()
+		if reset := time.Unix(tooMany.Reset, 0); reset.After(l.until) {
+			l.until = reset
+		}
+		l.mu.Unlock()
+	}
+}
+
+// limitedClient calls the API through a rateLimiter, a call failing with
+// ErrTooManyRequests is retried on its own so completed calls aren't repeated.
+type limitedClient struct {
+	c *Client
+	l *rateLimiter
+}
+
+func (lc *limitedClient) CreateZone(ctx context.Context, attrs *Zone) (zone *Zone, err error) {
+	err = lc.l.do(ctx, func() error {
+		zone, err = lc.c.CreateZone(ctx, attrs)
+		return err
+	})
+	return zone, err
+}
+
+func (lc *limitedClient) UpdateZone(ctx context.Context, zoneID int64, attrs *Zone) (zone *Zone, err error) {
+	err = lc.l.do(ctx, func() error {
+		zone, err = lc.c.UpdateZone(ctx, zoneID, attrs)
+		return err
+	})
+	return zone, err
+}
+
+func (lc *limitedClient) ListAllRecords(ctx context.Context, zone *Zone) (records []*Record, err error) {
+	err = lc.l.do(ctx, func() error {
+		records, err = lc.c.ListAllRecords(ctx, zone)
+		return err
+	})
+	return records, err
+}
+
+func (lc *limitedClient) CreateManyRecords(ctx context.Context, zone *Zone, recs []*RR) (records []*Record, err error) {
+	err = lc.l.do(ctx, func() error {
+		records, err = lc.c.CreateManyRecords(ctx, zone, recs)
+		return err
+	})
+	return records, err
+}
+
+func (lc *limitedClient) UpdateManyRecords(ctx context.Context, zone *Zone, recs []*RR) (records []*Record, err error) {
+	err = lc.l.do(ctx, func() error {
+		records, err = lc.c.UpdateManyRecords(ctx, zone, recs)
+		return err
+	})
+	return records, err
+}
+
+func (lc *limitedClient) DeleteManyRecords(ctx context.Context, zone *Zone, recs []*RR) (records []*Record, err error) {
+	err = lc.l.do(ctx, func() error {
+		records, err = lc.c.DeleteManyRecords(ctx, zone, recs)
+		return err
+	})
+	return records, err
+}
+
+func (lc *limitedClient) UpdateRecord(ctx context.Context, zone *Zone, recordID int64, attrs *Record) (record *Record, err error) {
+	err = lc.l.do(ctx, func() error {
+		record, err = lc.c.UpdateRecord(ctx, zone, recordID, attrs)
+		return err
+	})
+	return record, err
+}
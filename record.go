@@ -1,6 +1,10 @@
 package luadns
 
-import "time"
+import (
+	"strconv"
+	"strings"
+	"time"
+)
 
 type RecordType string
 
@@ -8,7 +12,7 @@ const (
 	TypeA        = "A"
 	TypeAAAA     = "AAAA"
 	TypeALIAS    = "ALIAS"
-	TypeCAA      = "CAAA"
+	TypeCAA      = "CAA"
 	TypeCNAME    = "CNAME"
 	TypeDS       = "DS"
 	TypeFORWARD  = "FORWARD"
@@ -32,6 +36,82 @@ type Record struct {
 	Content   string    `json:"content"`
 	TTL       uint32    `json:"ttl"`
 	ZoneID    int64     `json:"zone_id"`
+	Generated bool      `json:"generated,omitempty"`
 	CreatedAt time.Time `json:"created_at,omitempty"`
 	UpdatedAt time.Time `json:"updated_at,omitempty"`
 }
+
+// String returns the record using zone file syntax.
+func (r *Record) String() string {
+	return Fqdn(r.Name) + " " + strconv.FormatUint(uint64(r.TTL), 10) + " IN " + r.Type + " " + r.Content
+}
+
+// IsGenerated reports whether the record is managed by LuaDNS itself (the
+// apex SOA and NS records or any record flagged as generated by the API).
+func (r *Record) IsGenerated(origin string) bool {
+	if r.Generated {
+		return true
+	}
+	if !strings.EqualFold(Fqdn(r.Name), Fqdn(origin)) {
+		return false
+	}
+	return r.Type == TypeSOA || r.Type == TypeNS
+}
+
+// IsExtendedType reports whether the record type is specific to LuaDNS and
+// can't be represented in a standard zone file (ALIAS, FORWARD, REDIRECT, SLAVE).
+func IsExtendedType(typ string) bool {
+	switch strings.ToUpper(typ) {
+	case TypeALIAS, TypeFORWARD, TypeREDIRECT, TypeSLAVE:
+		return true
+	}
+	return false
+}
+
+// Fqdn returns the fully qualified form of `name` (with trailing dot).
+func Fqdn(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
+
+// AbsoluteName converts a `name` relative to `origin` ("@" for the origin
+// itself) to a fully qualified name, fully qualified names are unchanged.
+func AbsoluteName(name, origin string) string {
+	switch {
+	case name == "@":
+		return Fqdn(origin)
+	case strings.HasSuffix(name, "."):
+		return name
+	default:
+		return name + "." + Fqdn(origin)
+	}
+}
+
+// RelativeName converts a fully qualified `name` to a name relative to
+// `origin` ("@" for the origin itself), names outside of `origin` are
+// returned fully qualified.
+func RelativeName(name, origin string) string {
+	name, origin = Fqdn(name), Fqdn(origin)
+	if strings.EqualFold(name, origin) {
+		return "@"
+	}
+	if suffix := "." + origin; len(name) > len(suffix) && strings.EqualFold(name[len(name)-len(suffix):], suffix) {
+		return name[:len(name)-len(suffix)]
+	}
+	return name
+}
+
+// DefaultTTL returns the most used TTL in records, the lowest one on ties.
+func DefaultTTL(records []*Record) uint32 {
+	counts := map[uint32]int{}
+	var ttl uint32
+	for _, r := range records {
+		counts[r.TTL]++
+		if counts[r.TTL] > counts[ttl] || (counts[r.TTL] == counts[ttl] && r.TTL < ttl) {
+			ttl = r.TTL
+		}
+	}
+	return ttl
+}
@@ -0,0 +1,322 @@
+package luadns
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+const (
+	// DefaultOwnerLabel is the label inserted in companion TXT record names.
+	DefaultOwnerLabel = "_owner"
+
+	// DefaultOwnerTTL is the TTL of companion TXT records.
+	DefaultOwnerTTL = 300
+
+	ownerHeritage = "heritage=luadns"
+	ownerPrefix   = "luadns/owner="
+)
+
+// ErrNotOwner represents an error returned when changing the ownership of a
+// (name, type) record set owned by someone else.
+type ErrNotOwner struct {
+	Name  string
+	Type  string
+	Owner string // current owner, empty when the set is not owned
+}
+
+func (e *ErrNotOwner) Error() string {
+	if e.Owner == "" {
+		return "Record set " + e.Name + " " + e.Type + " is not owned"
+	}
+	return "Record set " + e.Name + " " + e.Type + " is owned by " + e.Owner
+}
+
+// Registry tracks (name, type) record sets managed by an owner using companion
+// TXT records, similar to the external-dns TXT registry. By default the
+// ownership of `www.example.org. A` is stored in:
+//
+//	a._owner.www.example.org. 300 IN TXT "heritage=luadns,luadns/owner=<OwnerID>"
+//
+// The wildcard label of wildcard names is kept first, `*.example.org. A` is
+// owned by `*.a._owner.example.org.`.
+//
+// Diff and Sync never change record sets owned by someone else or not owned at all.
+type Registry struct {
+	OwnerID string
+	Label   string // label inserted in companion names, defaults to DefaultOwnerLabel
+	TTL     uint32 // companion TTL, defaults to DefaultOwnerTTL
+
+	// CompanionName overrides the companion TXT record naming scheme.
+	CompanionName func(name, typ string) string
+}
+
+// companionName returns the name of the companion TXT record of a (name, type) set.
+func (reg *Registry) companionName(name, typ string) string {
+	if reg.CompanionName != nil {
+		return Fqdn(reg.CompanionName(Fqdn(name), strings.ToUpper(typ)))
+	}
+
+	label := reg.Label
+	if label == "" {
+		label = DefaultOwnerLabel
+	}
+	name = Fqdn(name)
+	if strings.HasPrefix(name, "*.") {
+		return "*." + strings.ToLower(typ) + "." + label + "." + name[2:]
+	}
+	return strings.ToLower(typ) + "." + label + "." + name
+}
+
+// companion returns the companion TXT record of a (name, type) set.
+func (reg *Registry) companion(name, typ string) *Record {
+	ttl := reg.TTL
+	if ttl == 0 {
+		ttl = DefaultOwnerTTL
+	}
+	return &Record{
+		Name:    reg.companionName(name, typ),
+		Type:    TypeTXT,
+		Content: ownerHeritage + "," + ownerPrefix + reg.OwnerID,
+		TTL:     ttl,
+	}
+}
+
+// ownerOf returns the owner ID stored in a companion TXT record.
+func ownerOf(r *Record) (string, bool) {
+	if r.Type != TypeTXT {
+		return "", false
+	}
+
+	fields := strings.Split(strings.Trim(r.Content, `"`), ",")
+	if len(fields) < 2 || fields[0] != ownerHeritage {
+		return "", false
+	}
+	for _, f := range fields[1:] {
+		if strings.HasPrefix(f, ownerPrefix) {
+			return strings.TrimPrefix(f, ownerPrefix), true
+		}
+	}
+	return "", false
+}
+
+// ownership represents the ownership state of zone records.
+type ownership struct {
+	owners     map[string]string  // set key -> owner ID
+	companions map[string]*Record // set key -> companion record
+	records    []*Record          // records without companions
+}
+
+// ownership splits `records` into companions and regular records. Companions
+// are matched against the names of existing record sets.
+func (reg *Registry) ownership(records []*Record) *ownership {
+	o := &ownership{owners: map[string]string{}, companions: map[string]*Record{}}
+
+	byName := map[string]*Record{}
+	for _, r := range records {
+		if _, ok := ownerOf(r); ok {
+			byName[strings.ToLower(Fqdn(r.Name))] = r
+		}
+	}
+
+	isCompanion := map[*Record]bool{}
+	for _, r := range records {
+		c, ok := byName[strings.ToLower(reg.companionName(r.Name, r.Type))]
+		if !ok || c == r {
+			continue
+		}
+		owner, _ := ownerOf(c)
+		o.owners[setKey(r)] = owner
+		o.companions[setKey(r)] = c
+		isCompanion[c] = true
+	}
+
+	// Companions of deleted sets are recognized by their name only, the
+	// original (name, type) is recovered for the default naming scheme.
+	for name, c := range byName {
+		if isCompanion[c] {
+			continue
+		}
+		if key, ok := reg.setKeyOf(name); ok {
+			if _, exists := o.owners[key]; !exists {
+				owner, _ := ownerOf(c)
+				o.owners[key] = owner
+				o.companions[key] = c
+				isCompanion[c] = true
+			}
+		}
+	}
+
+	for _, r := range records {
+		if !isCompanion[r] {
+			o.records = append(o.records, r)
+		}
+	}
+	return o
+}
+
+// setKeyOf recovers the (name, type) set key from a companion name using the
+// default naming scheme.
+func (reg *Registry) setKeyOf(name string) (string, bool) {
+	if reg.CompanionName != nil {
+		return "", false
+	}
+
+	label := reg.Label
+	if label == "" {
+		label = DefaultOwnerLabel
+	}
+	wildcard := strings.HasPrefix(name, "*.")
+	if wildcard {
+		name = name[2:]
+	}
+	parts := strings.SplitN(name, ".", 3)
+	if len(parts) != 3 || parts[0] == "" || !strings.EqualFold(parts[1], label) {
+		return "", false
+	}
+	if wildcard {
+		parts[2] = "*." + parts[2]
+	}
+	return setKey(&Record{Name: parts[2], Type: parts[0]}), true
+}
+
+// Owners returns the owner ID of every owned (name, type) set in `records`,
+// keyed by "<fqdn> <TYPE>".
+func (reg *Registry) Owners(records []*Record) map[string]string {
+	return reg.ownership(records).owners
+}
+
+// IsCompanion reports whether `r` is a companion TXT record.
+func (reg *Registry) IsCompanion(r *Record) bool {
+	_, ok := ownerOf(r)
+	return ok
+}
+
+// Diff computes the changes turning owned `current` records into `desired`
+// records, like Diff but restricted to record sets owned by reg.OwnerID.
+//
+// Desired record sets owned by someone else, or existing record sets not
+// owned at all, are left untouched and returned as conflicts. New record sets
+// are created along with their companion, companions of deleted record sets
+// are deleted.
+func (reg *Registry) Diff(desired []*Record, current []*Record, opts *DiffOptions) (ChangeSet, []*Record) {
+	o := reg.ownership(current)
+
+	existing := map[string]bool{}
+	for _, r := range o.records {
+		existing[setKey(r)] = true
+	}
+
+	owned := func(key string) bool {
+		return o.owners[key] == reg.OwnerID
+	}
+
+	mine := []*Record{}
+	for _, r := range o.records {
+		if owned(setKey(r)) {
+			mine = append(mine, r)
+		}
+	}
+
+	wanted := []*Record{}
+	conflicts := []*Record{}
+	keep := map[string]bool{}
+	for _, r := range desired {
+		if reg.IsCompanion(r) || r.Generated || (opts != nil && opts.Zone != "" && r.IsGenerated(opts.Zone)) {
+			continue
+		}
+		key := setKey(r)
+		owner, ok := o.owners[key]
+		if (ok && owner != reg.OwnerID) || (!ok && existing[key]) {
+			conflicts = append(conflicts, r)
+			continue
+		}
+		wanted = append(wanted, r)
+		keep[key] = true
+	}
+
+	cs := Diff(wanted, mine, opts)
+
+	// Create companions of new sets, delete companions of removed sets.
+	created := map[string]bool{}
+	for _, r := range wanted {
+		key := setKey(r)
+		if _, ok := o.owners[key]; !ok && !created[key] {
+			created[key] = true
+			cs.Changes = append(cs.Changes, &Change{Action: ChangeCreate, Desired: reg.companion(r.Name, r.Type)})
+		}
+	}
+	keys := []string{}
+	for key := range o.companions {
+		if owned(key) && !keep[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		cs.Changes = append(cs.Changes, &Change{Action: ChangeDelete, Current: o.companions[key]})
+	}
+
+	return cs, conflicts
+}
+
+// Sync applies the changes computed by Registry.Diff to `zone` and returns
+// the change results along with desired records left untouched (conflicts).
+func (reg *Registry) Sync(ctx context.Context, c *Client, zone *Zone, desired []*Record, opts *DiffOptions) ([]*ChangeResult, []*Record, error) {
+	current, err := c.ListAllRecords(ctx, zone)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if opts == nil {
+		opts = &DiffOptions{}
+	}
+	if opts.Zone == "" {
+		o := *opts
+		o.Zone = zone.Name
+		opts = &o
+	}
+
+	cs, conflicts := reg.Diff(desired, current, opts)
+	results, err := c.ApplyChanges(ctx, zone, cs)
+	return results, conflicts, err
+}
+
+// Adopt takes the ownership of an existing (name, type) record set. It fails
+// with ErrNotOwner when the set is owned by someone else.
+func (reg *Registry) Adopt(ctx context.Context, c *Client, zone *Zone, name, typ string) error {
+	current, err := c.ListAllRecords(ctx, zone)
+	if err != nil {
+		return err
+	}
+
+	key := setKey(&Record{Name: name, Type: typ})
+	owner, ok := reg.Owners(current)[key]
+	if ok && owner == reg.OwnerID {
+		return nil
+	}
+	if ok {
+		return &ErrNotOwner{Name: Fqdn(name), Type: strings.ToUpper(typ), Owner: owner}
+	}
+
+	_, err = c.CreateManyRecords(ctx, zone, []*RR{exactRR(reg.companion(name, typ))})
+	return err
+}
+
+// Release gives up the ownership of a (name, type) record set, records are
+// kept. It fails with ErrNotOwner when the set isn't owned by reg.OwnerID.
+func (reg *Registry) Release(ctx context.Context, c *Client, zone *Zone, name, typ string) error {
+	current, err := c.ListAllRecords(ctx, zone)
+	if err != nil {
+		return err
+	}
+
+	o := reg.ownership(current)
+	key := setKey(&Record{Name: name, Type: typ})
+	if owner := o.owners[key]; owner != reg.OwnerID {
+		return &ErrNotOwner{Name: Fqdn(name), Type: strings.ToUpper(typ), Owner: owner}
+	}
+
+	_, err = c.DeleteManyRecords(ctx, zone, []*RR{exactRR(o.companions[key])})
+	return err
+}
@@ -0,0 +1,106 @@
+package luadns_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/luadns/luadns-go"
+	"github.com/luadns/luadns-go/internal/fakeapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistryDiff(t *testing.T) {
+	reg := &luadns.Registry{OwnerID: "ci"}
+	current := []*luadns.Record{
+		{Name: "www.example.org.", Type: "A", Content: "1.1.1.1", TTL: 300},
+		{Name: "a._owner.www.example.org.", Type: "TXT", Content: "heritage=luadns,luadns/owner=ci", TTL: 300},
+		{Name: "old.example.org.", Type: "A", Content: "1.1.1.1", TTL: 300},
+		{Name: "a._owner.old.example.org.", Type: "TXT", Content: "heritage=luadns,luadns/owner=ci", TTL: 300},
+		{Name: "api.example.org.", Type: "A", Content: "1.1.1.1", TTL: 300},
+		{Name: "a._owner.api.example.org.", Type: "TXT", Content: "heritage=luadns,luadns/owner=other", TTL: 300},
+		{Name: "manual.example.org.", Type: "A", Content: "1.1.1.1", TTL: 300},
+	}
+	desired := []*luadns.Record{
+		{Name: "www.example.org.", Type: "A", Content: "2.2.2.2", TTL: 300},
+		{Name: "api.example.org.", Type: "A", Content: "2.2.2.2", TTL: 300},
+		{Name: "manual.example.org.", Type: "A", Content: "2.2.2.2", TTL: 300},
+		{Name: "new.example.org.", Type: "A", Content: "2.2.2.2", TTL: 300},
+	}
+
+	assert.Equal(t, map[string]string{
+		"www.example.org. A": "ci",
+		"old.example.org. A": "ci",
+		"api.example.org. A": "other",
+	}, reg.Owners(current))
+
+	cs, conflicts := reg.Diff(desired, current, nil)
+	assert.Equal(t, []*luadns.Record{desired[1], desired[2]}, conflicts)
+	assert.Equal(t, `--- current
++++ desired
++new.example.org. 300 IN A 2.2.2.2
+-old.example.org. 300 IN A 1.1.1.1
+-www.example.org. 300 IN A 1.1.1.1
++www.example.org. 300 IN A 2.2.2.2
++a._owner.new.example.org. 300 IN TXT heritage=luadns,luadns/owner=ci
+-a._owner.old.example.org. 300 IN TXT heritage=luadns,luadns/owner=ci
+`, cs.String())
+}
+
+func TestRegistryWildcard(t *testing.T) {
+	reg := &luadns.Registry{OwnerID: "ci"}
+	current := []*luadns.Record{
+		{Name: "*.example.org.", Type: "A", Content: "1.1.1.1", TTL: 300},
+		{Name: "*.a._owner.example.org.", Type: "TXT", Content: "heritage=luadns,luadns/owner=ci", TTL: 300},
+		{Name: "*.cname._owner.old.example.org.", Type: "TXT", Content: "heritage=luadns,luadns/owner=ci", TTL: 300},
+	}
+	assert.Equal(t, map[string]string{
+		"*.example.org. A":         "ci",
+		"*.old.example.org. CNAME": "ci",
+	}, reg.Owners(current))
+
+	cs, _ := reg.Diff([]*luadns.Record{{Name: "*.new.example.org.", Type: "TXT", Content: "new", TTL: 300}}, nil, nil)
+	assert.Equal(t, "*.txt._owner.new.example.org.", cs.Changes[1].Desired.Name)
+}
+
+func TestRegistrySyncAdoptRelease(t *testing.T) {
+	server := fakeapi.New()
+	defer server.Close()
+
+	zone := server.AddZone("example.org", &luadns.Record{Name: "manual.example.org.", Type: "A", Content: "1.1.1.1", TTL: 300})
+	c := server.Client()
+	ctx := context.Background()
+	reg := &luadns.Registry{OwnerID: "ci"}
+	other := &luadns.Registry{OwnerID: "other"}
+
+	desired := []*luadns.Record{
+		{Name: "manual.example.org.", Type: "A", Content: "2.2.2.2", TTL: 300},
+		{Name: "www.example.org.", Type: "A", Content: "2.2.2.2", TTL: 300},
+	}
+	_, conflicts, err := reg.Sync(ctx, c, zone, desired, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, desired[:1], conflicts)
+	assert.Equal(t, map[string]string{"www.example.org. A": "ci"}, reg.Owners(server.Records(zone.ID)))
+
+	// Adopt the manual record set, then sync it.
+	assert.NoError(t, reg.Adopt(ctx, c, zone, "manual.example.org.", "A"))
+	assert.EqualError(t, other.Adopt(ctx, c, zone, "manual.example.org", "a"), "Record set manual.example.org. A is owned by ci")
+
+	_, conflicts, err = reg.Sync(ctx, c, zone, desired, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, conflicts)
+
+	// Released record sets are kept but no longer deleted by sync.
+	assert.NoError(t, reg.Release(ctx, c, zone, "manual.example.org.", "A"))
+	assert.EqualError(t, reg.Release(ctx, c, zone, "manual.example.org.", "A"), "Record set manual.example.org. A is not owned")
+
+	_, _, err = reg.Sync(ctx, c, zone, nil, nil)
+	assert.NoError(t, err)
+
+	records := []string{}
+	for _, r := range server.Records(zone.ID) {
+		if !r.IsGenerated(zone.Name) {
+			records = append(records, r.String())
+		}
+	}
+	assert.Equal(t, []string{"manual.example.org. 300 IN A 2.2.2.2"}, records)
+}
@@ -0,0 +1,273 @@
+package luadns
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBulkConcurrency is the number of zones changed concurrently.
+const DefaultBulkConcurrency = 4
+
+// FindReplaceOptions represents options used by FindReplace.
+//
+// Records are matched by type and by `Match` or `Pattern`. The new content is
+// returned by `Replace`, or built from `Replacement`: with `Pattern` every
+// match is replaced (supporting $1 expansions), otherwise the whole content
+// is replaced.
+type FindReplaceOptions struct {
+	Types       []string             // record types to match, all types when empty
+	Match       func(r *Record) bool // content predicate
+	Pattern     *regexp.Regexp       // content regular expression
+	Replace     func(r *Record) string
+	Replacement string
+	Zones       []string // zones to scan, all zones when empty
+}
+
+// ZoneChanges represents changes of a single zone.
+type ZoneChanges struct {
+	ZoneID  int64     `json:"zone_id"`
+	Zone    string    `json:"zone"`
+	Changes ChangeSet `json:"changes"`
+}
+
+// BulkChanges represents changes across zones, they're saved in rollback files.
+type BulkChanges struct {
+	CreatedAt time.Time      `json:"created_at"`
+	Zones     []*ZoneChanges `json:"zones"`
+}
+
+// BulkApplyOptions represents options used by ApplyBulkChanges.
+type BulkApplyOptions struct {
+	Concurrency  int           // zones changed concurrently, defaults to DefaultBulkConcurrency
+	Interval     time.Duration // minimum delay between zone changes
+	RollbackFile string        // file receiving the inverse changes, written before applying
+}
+
+// BulkResult represents the outcome of changing a zone.
+type BulkResult struct {
+	Zone    string
+	Results []*ChangeResult
+	Err     error
+}
+
+// FindReplace scans zones for records matching `opts` and returns the changes
+// replacing their content, as a preview to apply with ApplyBulkChanges.
+// Records generated by LuaDNS are never matched.
+func (c *Client) FindReplace(ctx context.Context, opts *FindReplaceOptions) (*BulkChanges, error) {
+	if opts.Match == nil && opts.Pattern == nil {
+		return nil, errors.New("find and replace requires a content predicate or pattern")
+	}
+
+	zones, err := c.ListAllZones(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	bulk := &BulkChanges{CreatedAt: time.Now().UTC(), Zones: []*ZoneChanges{}}
+	for _, z := range zones {
+		if len(opts.Zones) > 0 && !containsZone(opts.Zones, z.Name) {
+			continue
+		}
+
+		records, err := c.ListAllRecords(ctx, z)
+		if err != nil {
+			return nil, err
+		}
+
+		cs := findReplace(z, records, opts)
+		if !cs.Empty() {
+			bulk.Zones = append(bulk.Zones, &ZoneChanges{ZoneID: z.ID, Zone: z.Name, Changes: cs})
+		}
+	}
+
+	return bulk, nil
+}
+
+// findReplace returns the changes of a zone, records kept in changed
+// (name, type) sets are listed as unchanged.
+func findReplace(zone *Zone, records []*Record, opts *FindReplaceOptions) ChangeSet {
+	cs := ChangeSet{Changes: []*Change{}}
+	changed := map[*Record]bool{}
+	touched := map[string]bool{}
+
+	for _, r := range records {
+		if r.IsGenerated(zone.Name) || (len(opts.Types) > 0 && !hasType(opts.Types, r.Type)) {
+			continue
+		}
+		if opts.Match != nil && !opts.Match(r) {
+			continue
+		}
+		if opts.Pattern != nil && !opts.Pattern.MatchString(r.Content) {
+			continue
+		}
+
+		var content string
+		switch {
+		case opts.Replace != nil:
+			content = opts.Replace(r)
+		case opts.Pattern != nil:
+			content = opts.Pattern.ReplaceAllString(r.Content, opts.Replacement)
+		default:
+			content = opts.Replacement
+		}
+		if content == r.Content {
+			continue
+		}
+
+		desired := *r
+		desired.ID = 0
+		desired.Content = content
+		cs.Changes = append(cs.Changes, &Change{Action: ChangeUpdate, Desired: &desired, Current: r})
+		changed[r] = true
+		touched[setKey(r)] = true
+	}
+
+	for _, r := range records {
+		if touched[setKey(r)] && !changed[r] {
+			cs.Unchanged = append(cs.Unchanged, r)
+		}
+	}
+	return cs
+}
+
+// containsZone reports whether `name` is listed in `zones`.
+func containsZone(zones []string, name string) bool {
+	for _, z := range zones {
+		if strings.EqualFold(Fqdn(z), Fqdn(name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyBulkChanges applies changes across zones with bounded concurrency,
+// workers pause when the API requests quota is exceeded. When a rollback file
+// is requested the inverse changes are saved before applying.
+//
+// A result is returned for every zone, the first error is returned.
+func (c *Client) ApplyBulkChanges(ctx context.Context, bulk *BulkChanges, opts *BulkApplyOptions) ([]*BulkResult, error) {
+	if opts == nil {
+		opts = &BulkApplyOptions{}
+	}
+
+	if opts.RollbackFile != "" {
+		if err := bulk.Rollback().WriteFile(opts.RollbackFile); err != nil {
+			return nil, err
+		}
+	}
+
+	workers := opts.Concurrency
+	if workers <= 0 {
+		workers = DefaultBulkConcurrency
+	}
+	lc := &limitedClient{c: c, l: &rateLimiter{interval: opts.Interval}}
+
+	results := make([]*BulkResult, len(bulk.Zones))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				zc := bulk.Zones[i]
+				res := &BulkResult{Zone: zc.Zone}
+				res.Results, res.Err = applyChanges(ctx, lc, &Zone{ID: zc.ZoneID, Name: zc.Zone}, zc.Changes)
+				results[i] = res
+			}
+		}()
+	}
+	for i := range bulk.Zones {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	for _, res := range results {
+		if res.Err != nil {
+			return results, res.Err
+		}
+	}
+	return results, nil
+}
+
+// Rollback returns the changes reverting `bulk`.
+func (bulk *BulkChanges) Rollback() *BulkChanges {
+	rollback := &BulkChanges{CreatedAt: time.Now().UTC(), Zones: []*ZoneChanges{}}
+	for _, zc := range bulk.Zones {
+		cs := ChangeSet{Changes: []*Change{}, Unchanged: zc.Changes.Unchanged}
+		for _, ch := range zc.Changes.Changes {
+			inverse := &Change{Action: ch.Action, Desired: ch.Current, Current: ch.Desired}
+			switch ch.Action {
+			case ChangeCreate:
+				inverse.Action = ChangeDelete
+			case ChangeDelete:
+				inverse.Action = ChangeCreate
+			}
+			cs.Changes = append(cs.Changes, inverse)
+		}
+		rollback.Zones = append(rollback.Zones, &ZoneChanges{ZoneID: zc.ZoneID, Zone: zc.Zone, Changes: cs})
+	}
+	return rollback
+}
+
+// WriteDiff writes the changes of every zone as a unified diff.
+func (bulk *BulkChanges) WriteDiff(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, zc := range bulk.Zones {
+		bw.WriteString("=== " + zc.Zone + "\n")
+		if err := zc.Changes.WriteDiff(bw); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// Write writes the changes as indented JSON.
+func (bulk *BulkChanges) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(bulk)
+}
+
+// WriteFile writes the changes to a file.
+func (bulk *BulkChanges) WriteFile(filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	err = bulk.Write(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// ReadBulkChanges reads changes written by BulkChanges.Write.
+func ReadBulkChanges(r io.Reader) (*BulkChanges, error) {
+	var bulk BulkChanges
+	if err := json.NewDecoder(r).Decode(&bulk); err != nil {
+		return nil, err
+	}
+	return &bulk, nil
+}
+
+// ReadBulkChangesFile reads a file written by BulkChanges.WriteFile, such as a rollback file.
+func ReadBulkChangesFile(filename string) (*BulkChanges, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadBulkChanges(f)
+}
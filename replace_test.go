@@ -0,0 +1,98 @@
+package luadns_test
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/luadns/luadns-go"
+	"github.com/luadns/luadns-go/internal/fakeapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindReplace(t *testing.T) {
+	server := fakeapi.New()
+	defer server.Close()
+
+	org := server.AddZone("example.org",
+		&luadns.Record{Name: "example.org.", Type: "A", Content: "1.1.1.1", TTL: 300},
+		&luadns.Record{Name: "example.org.", Type: "A", Content: "2.2.2.2", TTL: 300},
+		&luadns.Record{Name: "example.org.", Type: "TXT", Content: "1.1.1.1", TTL: 300},
+	)
+	net := server.AddZone("example.net",
+		&luadns.Record{Name: "www.example.net.", Type: "A", Content: "1.1.1.1", TTL: 300},
+		&luadns.Record{Name: "example.net.", Type: "MX", Content: "10 mx.old-provider.com.", TTL: 300},
+	)
+	c := server.Client()
+	ctx := context.Background()
+
+	bulk, err := c.FindReplace(ctx, &luadns.FindReplaceOptions{
+		Types:       []string{"A"},
+		Match:       func(r *luadns.Record) bool { return r.Content == "1.1.1.1" },
+		Replacement: "3.3.3.3",
+	})
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	assert.NoError(t, bulk.WriteDiff(&buf))
+	assert.Equal(t, `=== example.org
+--- current
++++ desired
+-example.org. 300 IN A 1.1.1.1
++example.org. 300 IN A 3.3.3.3
+=== example.net
+--- current
++++ desired
+-www.example.net. 300 IN A 1.1.1.1
++www.example.net. 300 IN A 3.3.3.3
+`, buf.String())
+
+	rollback := filepath.Join(t.TempDir(), "rollback.json")
+	results, err := c.ApplyBulkChanges(ctx, bulk, &luadns.BulkApplyOptions{Concurrency: 2, Interval: time.Millisecond, RollbackFile: rollback})
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+
+	contents := func(zoneID int64) []string {
+		list := []string{}
+		for _, r := range server.Records(zoneID)[3:] {
+			list = append(list, r.Type+" "+r.Content)
+		}
+		return list
+	}
+	assert.ElementsMatch(t, []string{"A 3.3.3.3", "A 2.2.2.2", "TXT 1.1.1.1"}, contents(org.ID))
+	assert.ElementsMatch(t, []string{"A 3.3.3.3", "MX 10 mx.old-provider.com."}, contents(net.ID))
+
+	// Revert using the rollback file.
+	undo, err := luadns.ReadBulkChangesFile(rollback)
+	assert.NoError(t, err)
+	_, err = c.ApplyBulkChanges(ctx, undo, nil)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"A 1.1.1.1", "A 2.2.2.2", "TXT 1.1.1.1"}, contents(org.ID))
+	assert.ElementsMatch(t, []string{"A 1.1.1.1", "MX 10 mx.old-provider.com."}, contents(net.ID))
+}
+
+func TestFindReplacePattern(t *testing.T) {
+	server := fakeapi.New()
+	defer server.Close()
+
+	server.AddZone("example.org", &luadns.Record{Name: "example.org.", Type: "MX", Content: "10 mx1.old-provider.com.", TTL: 300})
+	server.AddZone("example.net", &luadns.Record{Name: "example.net.", Type: "MX", Content: "10 mx1.old-provider.com.", TTL: 300})
+	c := server.Client()
+	ctx := context.Background()
+
+	bulk, err := c.FindReplace(ctx, &luadns.FindReplaceOptions{
+		Types:       []string{"mx"},
+		Pattern:     regexp.MustCompile(`mx(\d)\.old-provider\.com\.$`),
+		Replacement: "mx$1.new-provider.com.",
+		Zones:       []string{"example.net."},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, bulk.Zones, 1)
+	assert.Equal(t, "10 mx1.new-provider.com.", bulk.Zones[0].Changes.Changes[0].Desired.Content)
+
+	_, err = c.FindReplace(ctx, &luadns.FindReplaceOptions{Replacement: "x"})
+	assert.EqualError(t, err, "find and replace requires a content predicate or pattern")
+}
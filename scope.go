@@ -0,0 +1,356 @@
+package luadns
+
+import (
+	"context"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// zonesRefreshInterval is the minimum time between zone list refreshes
+// triggered by unknown zone IDs.
+const zonesRefreshInterval = time.Minute
+
+// Policy represents the operations allowed to a ScopedClient.
+type Policy struct {
+	Zones       []string // allowed zone names, all zones when empty
+	Names       []string // allowed record name patterns (path.Match syntax, case insensitive), all names when empty
+	Types       []string // allowed record types, all types when empty
+	MaxTTL      uint32   // maximum record TTL, unlimited when zero
+	AllowDelete bool     // permit record deletions
+}
+
+// ErrPolicyViolation represents an operation rejected locally by a
+// ScopedClient policy, the API isn't called.
+type ErrPolicyViolation struct {
+	Op     string // operation, example: "create record"
+	Zone   string
+	Name   string
+	Type   string
+	Reason string
+}
+
+func (e *ErrPolicyViolation) Error() string {
+	msg := "Policy denies " + e.Op
+	if e.Name != "" {
+		msg += " " + e.Name
+		if e.Type != "" {
+			msg += " " + e.Type
+		}
+	}
+	if e.Zone != "" {
+		msg += " in zone " + e.Zone
+	}
+	return msg + ": " + e.Reason
+}
+
+// ScopedClient wraps a Client and enforces a policy, it limits automation
+// using account-wide API keys to a subset of zones and records.
+//
+// Only zone reads and record operations are exposed. Zones are resolved by ID
+// from the account zones (the list is cached, unknown IDs refresh it at most
+// once per minute), so the names of passed zones are never trusted. Updates
+// and deletions by ID read the current record to check it's in scope too.
+type ScopedClient struct {
+	c      *Client
+	policy Policy
+
+	mu        sync.Mutex
+	zones     map[int64]*Zone
+	refreshed time.Time // last zone list refresh
+}
+
+// NewScopedClient returns a client restricted to `policy`.
+func NewScopedClient(c *Client, policy Policy) *ScopedClient {
+	return &ScopedClient{c: c, policy: policy}
+}
+
+// Policy returns the enforced policy.
+func (s *ScopedClient) Policy() Policy {
+	return s.policy
+}
+
+// ListAllZones returns the allowed zones.
+func (s *ScopedClient) ListAllZones(ctx context.Context) ([]*Zone, error) {
+	zones, err := s.c.ListAllZones(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := []*Zone{}
+	for _, z := range zones {
+		if s.zoneAllowed(z.Name) {
+			allowed = append(allowed, z)
+		}
+	}
+	s.cacheZones(zones)
+	return allowed, nil
+}
+
+// GetZone returns an allowed zone, its records are limited to those allowed
+// by the policy.
+func (s *ScopedClient) GetZone(ctx context.Context, zoneID int64) (*Zone, error) {
+	if _, err := s.zone(ctx, "get zone", &Zone{ID: zoneID}); err != nil {
+		return nil, err
+	}
+
+	zone, err := s.c.GetZone(ctx, zoneID)
+	if err != nil {
+		return nil, err
+	}
+
+	records := []*Record{}
+	for _, r := range zone.Records {
+		if s.checkRecord("", zone, r.Name, r.Type, r.TTL, false) == nil {
+			records = append(records, r)
+		}
+	}
+	zone.Records = records
+	return zone, nil
+}
+
+// ListAllRecords returns the zone records allowed by the policy.
+func (s *ScopedClient) ListAllRecords(ctx context.Context, zone *Zone) ([]*Record, error) {
+	z, err := s.zone(ctx, "list records", zone)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := s.c.ListAllRecords(ctx, z)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := []*Record{}
+	for _, r := range records {
+		if s.checkRecord("", z, r.Name, r.Type, r.TTL, false) == nil {
+			allowed = append(allowed, r)
+		}
+	}
+	return allowed, nil
+}
+
+// GetRecord returns a zone record allowed by the policy.
+func (s *ScopedClient) GetRecord(ctx context.Context, zone *Zone, recordID int64) (*Record, error) {
+	z, err := s.zone(ctx, "get record", zone)
+	if err != nil {
+		return nil, err
+	}
+	return s.current(ctx, "get record", z, recordID)
+}
+
+// CreateRecord creates a zone record allowed by the policy.
+func (s *ScopedClient) CreateRecord(ctx context.Context, zone *Zone, attrs *Record) (*Record, error) {
+	z, err := s.zone(ctx, "create record", zone)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkRecord("create record", z, attrs.Name, attrs.Type, attrs.TTL, true); err != nil {
+		return nil, err
+	}
+	return s.c.CreateRecord(ctx, z, attrs)
+}
+
+// UpdateRecord updates a zone record, both the current and the new record
+// must be allowed by the policy.
+func (s *ScopedClient) UpdateRecord(ctx context.Context, zone *Zone, recordID int64, attrs *Record) (*Record, error) {
+	z, err := s.zone(ctx, "update record", zone)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkRecord("update record", z, attrs.Name, attrs.Type, attrs.TTL, true); err != nil {
+		return nil, err
+	}
+	if _, err := s.current(ctx, "update record", z, recordID); err != nil {
+		return nil, err
+	}
+	return s.c.UpdateRecord(ctx, z, recordID, attrs)
+}
+
+// DeleteRecord deletes a zone record when the policy permits deletions.
+func (s *ScopedClient) DeleteRecord(ctx context.Context, zone *Zone, recordID int64) (*Record, error) {
+	z, err := s.zone(ctx, "delete record", zone)
+	if err != nil {
+		return nil, err
+	}
+	if !s.policy.AllowDelete {
+		return nil, &ErrPolicyViolation{Op: "delete record", Zone: z.Name, Reason: "deletions not allowed"}
+	}
+	if _, err := s.current(ctx, "delete record", z, recordID); err != nil {
+		return nil, err
+	}
+	return s.c.DeleteRecord(ctx, z, recordID)
+}
+
+// CreateManyRecords creates zone records, all of them must be allowed by the policy.
+func (s *ScopedClient) CreateManyRecords(ctx context.Context, zone *Zone, recs []*RR) ([]*Record, error) {
+	z, err := s.checkRRs(ctx, "create records", zone, recs, true)
+	if err != nil {
+		return nil, err
+	}
+	return s.c.CreateManyRecords(ctx, z, recs)
+}
+
+// UpdateManyRecords replaces zone record sets, all of them must be allowed by the policy.
+func (s *ScopedClient) UpdateManyRecords(ctx context.Context, zone *Zone, recs []*RR) ([]*Record, error) {
+	z, err := s.checkRRs(ctx, "update records", zone, recs, true)
+	if err != nil {
+		return nil, err
+	}
+	return s.c.UpdateManyRecords(ctx, z, recs)
+}
+
+// DeleteManyRecords deletes zone records when the policy permits deletions.
+// With a types policy every RR must have an allowed type, so wildcard RRs
+// don't delete records out of scope.
+func (s *ScopedClient) DeleteManyRecords(ctx context.Context, zone *Zone, recs []*RR) ([]*Record, error) {
+	z, err := s.zone(ctx, "delete records", zone)
+	if err != nil {
+		return nil, err
+	}
+	if !s.policy.AllowDelete {
+		return nil, &ErrPolicyViolation{Op: "delete records", Zone: z.Name, Reason: "deletions not allowed"}
+	}
+	if _, err := s.checkRRs(ctx, "delete records", z, recs, false); err != nil {
+		return nil, err
+	}
+	return s.c.DeleteManyRecords(ctx, z, recs)
+}
+
+// ApplyChanges applies a change set (see Client.ApplyChanges), every change
+// is checked before the first API call.
+func (s *ScopedClient) ApplyChanges(ctx context.Context, zone *Zone, changes ChangeSet) ([]*ChangeResult, error) {
+	const op = "apply changes"
+
+	z, err := s.zone(ctx, op, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ch := range changes.Changes {
+		if ch.Action == ChangeDelete && !s.policy.AllowDelete {
+			return nil, &ErrPolicyViolation{Op: op, Zone: z.Name, Name: ch.Current.Name, Type: ch.Current.Type, Reason: "deletions not allowed"}
+		}
+		if ch.Desired != nil {
+			if err := s.checkRecord(op, z, ch.Desired.Name, ch.Desired.Type, ch.Desired.TTL, true); err != nil {
+				return nil, err
+			}
+		}
+		if ch.Current != nil {
+			if err := s.checkRecord(op, z, ch.Current.Name, ch.Current.Type, ch.Current.TTL, false); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return applyChanges(ctx, s, z, changes)
+}
+
+// zone returns the account zone matching the ID of `zone` when allowed.
+func (s *ScopedClient) zone(ctx context.Context, op string, zone *Zone) (*Zone, error) {
+	s.mu.Lock()
+	z, ok := s.zones[zone.ID]
+	stale := time.Since(s.refreshed) >= zonesRefreshInterval
+	s.mu.Unlock()
+
+	if !ok && stale {
+		zones, err := s.c.ListAllZones(ctx)
+		if err != nil {
+			return nil, err
+		}
+		s.cacheZones(zones)
+
+		s.mu.Lock()
+		z, ok = s.zones[zone.ID]
+		s.mu.Unlock()
+	}
+
+	if !ok {
+		return nil, &ErrPolicyViolation{Op: op, Zone: strconv.FormatInt(zone.ID, 10), Reason: "zone not found"}
+	}
+	if !s.zoneAllowed(z.Name) {
+		return nil, &ErrPolicyViolation{Op: op, Zone: z.Name, Reason: "zone not allowed"}
+	}
+	return z, nil
+}
+
+func (s *ScopedClient) cacheZones(zones []*Zone) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.zones = map[int64]*Zone{}
+	s.refreshed = time.Now()
+	for _, z := range zones {
+		s.zones[z.ID] = z
+	}
+}
+
+func (s *ScopedClient) zoneAllowed(name string) bool {
+	return len(s.policy.Zones) == 0 || containsZone(s.policy.Zones, name)
+}
+
+// current returns a zone record, it fails when the record is out of scope.
+func (s *ScopedClient) current(ctx context.Context, op string, zone *Zone, recordID int64) (*Record, error) {
+	r, err := s.c.GetRecord(ctx, zone, recordID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkRecord(op, zone, r.Name, r.Type, r.TTL, false); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// checkRRs checks the zone and RRs of a bulk operation.
+func (s *ScopedClient) checkRRs(ctx context.Context, op string, zone *Zone, recs []*RR, write bool) (*Zone, error) {
+	z, err := s.zone(ctx, op, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rr := range recs {
+		if !write && rr.Type == "" && len(s.policy.Types) > 0 {
+			return nil, &ErrPolicyViolation{Op: op, Zone: z.Name, Name: rr.Name, Reason: "type required"}
+		}
+		if err := s.checkRecord(op, z, rr.Name, rr.Type, rr.TTL, write); err != nil {
+			return nil, err
+		}
+	}
+	return z, nil
+}
+
+// checkRecord checks a record name, type and TTL (when written) against the policy.
+func (s *ScopedClient) checkRecord(op string, zone *Zone, name, typ string, ttl uint32, write bool) error {
+	fail := func(reason string) error {
+		return &ErrPolicyViolation{Op: op, Zone: zone.Name, Name: name, Type: typ, Reason: reason}
+	}
+
+	fqdn := strings.ToLower(Fqdn(name))
+	origin := strings.ToLower(Fqdn(zone.Name))
+	if fqdn != origin && !strings.HasSuffix(fqdn, "."+origin) {
+		return fail("name outside zone")
+	}
+	if len(s.policy.Names) > 0 && !matchName(s.policy.Names, fqdn) {
+		return fail("name not allowed")
+	}
+	if len(s.policy.Types) > 0 && !hasType(s.policy.Types, typ) {
+		return fail("type not allowed")
+	}
+	if write && s.policy.MaxTTL > 0 && (ttl == 0 || ttl > s.policy.MaxTTL) {
+		return fail("TTL must be between 1 and " + strconv.FormatUint(uint64(s.policy.MaxTTL), 10))
+	}
+	return nil
+}
+
+// matchName reports whether a fully qualified name matches one of the
+// patterns, malformed patterns never match.
+func matchName(patterns []string, fqdn string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(strings.ToLower(Fqdn(p)), fqdn); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,157 @@
+package luadns_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/luadns/luadns-go"
+	"github.com/luadns/luadns-go/internal/fakeapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScopedClient(t *testing.T) {
+	server := fakeapi.New()
+	defer server.Close()
+
+	org := server.AddZone("example.org",
+		&luadns.Record{Name: "www.example.org.", Type: "A", Content: "1.1.1.1", TTL: 300},
+		&luadns.Record{Name: "_acme-challenge.www.example.org.", Type: "TXT", Content: "old", TTL: 60},
+	)
+	net := server.AddZone("example.net")
+
+	s := luadns.NewScopedClient(server.Client(), luadns.Policy{
+		Zones:       []string{"example.org"},
+		Names:       []string{"_acme-challenge.*"},
+		Types:       []string{"TXT"},
+		MaxTTL:      300,
+		AllowDelete: true,
+	})
+	ctx := context.Background()
+
+	zones, err := s.ListAllZones(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, zones, 1) {
+		assert.Equal(t, "example.org", zones[0].Name)
+	}
+
+	records, err := s.ListAllRecords(ctx, org)
+	assert.NoError(t, err)
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, "_acme-challenge.www.example.org.", records[0].Name)
+	}
+
+	zone, err := s.GetZone(ctx, org.ID)
+	assert.NoError(t, err)
+	assert.Len(t, zone.Records, 1)
+
+	r, err := s.CreateRecord(ctx, org, &luadns.Record{Name: "_acme-challenge.example.org.", Type: "TXT", Content: "token", TTL: 60})
+	assert.NoError(t, err)
+
+	_, err = s.UpdateRecord(ctx, org, r.ID, &luadns.Record{Name: r.Name, Type: "TXT", Content: "new", TTL: 60})
+	assert.NoError(t, err)
+
+	_, err = s.UpdateManyRecords(ctx, org, []*luadns.RR{{Name: "_acme-challenge.www.example.org.", Type: "TXT", Content: "a", TTL: 60}})
+	assert.NoError(t, err)
+
+	_, err = s.DeleteRecord(ctx, org, r.ID)
+	assert.NoError(t, err)
+
+	// Zones are resolved by ID, names aren't trusted.
+	server.ResetRequests()
+	_, err = s.CreateRecord(ctx, &luadns.Zone{ID: net.ID, Name: "example.org"}, &luadns.Record{Name: "_acme-challenge.example.org.", Type: "TXT", Content: "token", TTL: 60})
+	assert.EqualError(t, err, "Policy denies create record in zone example.net: zone not allowed")
+	assert.Empty(t, server.Requests())
+}
+
+func TestScopedClientViolations(t *testing.T) {
+	server := fakeapi.New()
+	defer server.Close()
+
+	org := server.AddZone("example.org",
+		&luadns.Record{Name: "www.example.org.", Type: "A", Content: "1.1.1.1", TTL: 300},
+		&luadns.Record{Name: "_acme-challenge.example.org.", Type: "TXT", Content: "token", TTL: 60},
+	)
+	www := server.Records(org.ID)[3]
+	challenge := server.Records(org.ID)[4]
+
+	s := luadns.NewScopedClient(server.Client(), luadns.Policy{
+		Zones:  []string{"example.org"},
+		Names:  []string{"_acme-challenge.*"},
+		Types:  []string{"TXT"},
+		MaxTTL: 300,
+	})
+	ctx := context.Background()
+
+	_, err := s.ListAllZones(ctx)
+	assert.NoError(t, err)
+	server.ResetRequests()
+
+	txt := func(name string, ttl uint32) *luadns.Record {
+		return &luadns.Record{Name: name, Type: "TXT", Content: "token", TTL: ttl}
+	}
+
+	tests := []struct {
+		name string
+		fn   func() error
+		err  string
+	}{
+		{"name not allowed", func() error {
+			_, err := s.CreateRecord(ctx, org, txt("www.example.org.", 60))
+			return err
+		}, "Policy denies create record www.example.org. TXT in zone example.org: name not allowed"},
+		{"name outside zone", func() error {
+			_, err := s.CreateRecord(ctx, org, txt("_acme-challenge.example.net.", 60))
+			return err
+		}, "Policy denies create record _acme-challenge.example.net. TXT in zone example.org: name outside zone"},
+		{"type not allowed", func() error {
+			_, err := s.CreateManyRecords(ctx, org, []*luadns.RR{{Name: "_acme-challenge.example.org.", Type: "A", Content: "1.1.1.1", TTL: 60}})
+			return err
+		}, "Policy denies create records _acme-challenge.example.org. A in zone example.org: type not allowed"},
+		{"ttl too large", func() error {
+			_, err := s.UpdateManyRecords(ctx, org, []*luadns.RR{{Name: "_acme-challenge.example.org.", Type: "TXT", Content: "token", TTL: 3600}})
+			return err
+		}, "Policy denies update records _acme-challenge.example.org. TXT in zone example.org: TTL must be between 1 and 300"},
+		{"ttl missing", func() error {
+			_, err := s.CreateRecord(ctx, org, txt("_acme-challenge.example.org.", 0))
+			return err
+		}, "Policy denies create record _acme-challenge.example.org. TXT in zone example.org: TTL must be between 1 and 300"},
+		{"delete record", func() error {
+			_, err := s.DeleteRecord(ctx, org, challenge.ID)
+			return err
+		}, "Policy denies delete record in zone example.org: deletions not allowed"},
+		{"delete records", func() error {
+			_, err := s.DeleteManyRecords(ctx, org, []*luadns.RR{{Name: "_acme-challenge.example.org."}})
+			return err
+		}, "Policy denies delete records in zone example.org: deletions not allowed"},
+		{"delete records named zone", func() error {
+			_, err := s.DeleteManyRecords(ctx, &luadns.Zone{ID: org.ID, Name: "example.com"}, []*luadns.RR{{Name: "_acme-challenge.example.org."}})
+			return err
+		}, "Policy denies delete records in zone example.org: deletions not allowed"},
+		{"delete changes", func() error {
+			_, err := s.ApplyChanges(ctx, org, luadns.ChangeSet{Changes: []*luadns.Change{{Action: luadns.ChangeDelete, Current: challenge}}})
+			return err
+		}, "Policy denies apply changes _acme-challenge.example.org. TXT in zone example.org: deletions not allowed"},
+		{"zone not found", func() error {
+			_, err := s.GetZone(ctx, 999)
+			return err
+		}, "Policy denies get zone in zone 999: zone not found"},
+	}
+	for _, tt := range tests {
+		err := tt.fn()
+		assert.IsType(t, &luadns.ErrPolicyViolation{}, err, tt.name)
+		assert.EqualError(t, err, tt.err, tt.name)
+	}
+	// Violations don't call the API, unknown zones don't refresh a recent zone list.
+	assert.Empty(t, server.Requests())
+
+	// Records are read to check updates by ID.
+	_, err = s.UpdateRecord(ctx, org, www.ID, txt("_acme-challenge.example.org.", 60))
+	assert.EqualError(t, err, "Policy denies update record www.example.org. A in zone example.org: name not allowed")
+	_, err = s.GetRecord(ctx, org, www.ID)
+	assert.EqualError(t, err, "Policy denies get record www.example.org. A in zone example.org: name not allowed")
+
+	// Wildcard deletions require a type.
+	s = luadns.NewScopedClient(server.Client(), luadns.Policy{Types: []string{"TXT"}, AllowDelete: true})
+	_, err = s.DeleteManyRecords(ctx, org, []*luadns.RR{{Name: "_acme-challenge.example.org."}})
+	assert.EqualError(t, err, "Policy denies delete records _acme-challenge.example.org. in zone example.org: type required")
+}
@@ -0,0 +1,272 @@
+package luadns
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SnapshotVersion is the snapshot archive format version.
+const SnapshotVersion = 1
+
+// DefaultRestoreConcurrency is the number of zones restored concurrently.
+const DefaultRestoreConcurrency = 4
+
+// Snapshot represents all zones of an account with their records.
+//
+// Snapshots are stored as JSON lines, a header line holding the version and
+// creation time followed by a line per zone (name, tags, template ID and records).
+type Snapshot struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	Zones     []*Zone   `json:"-"`
+}
+
+// RestoreOptions represents options used by Restore.
+type RestoreOptions struct {
+	Zone        string // restore only this zone
+	DryRun      bool   // compute changes without applying them
+	Concurrency int    // zones restored concurrently, defaults to DefaultRestoreConcurrency
+}
+
+// RestoreResult represents the outcome of a zone restore.
+type RestoreResult struct {
+	Zone        string
+	Created     bool      // zone created (or to be created on dry run)
+	ZoneUpdated bool      // zone tags or template restored
+	Changes     ChangeSet // record changes against the live state
+	Results     []*ChangeResult
+	Err         error
+}
+
+// Snapshot captures every zone (name, tags, template ID) and all records.
+func (c *Client) Snapshot(ctx context.Context) (*Snapshot, error) {
+	limiter := &rateLimiter{}
+
+	var zones []*Zone
+	err := limiter.do(ctx, func() (err error) {
+		zones, err = c.ListAllZones(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Snapshot{Version: SnapshotVersion, CreatedAt: time.Now().UTC()}
+	for _, z := range zones {
+		var records []*Record
+		err := limiter.do(ctx, func() (err error) {
+			records, err = c.ListAllRecords(ctx, z)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		s.Zones = append(s.Zones, &Zone{Name: z.Name, Tags: z.Tags, TemplateID: z.TemplateID, Records: records})
+	}
+
+	return s, nil
+}
+
+// Restore rebuilds zones stored in a snapshot: missing zones are created,
+// zone tags and templates are restored and records are changed to match the
+// snapshot. Zones are restored concurrently, workers pause when the API
+// requests quota is exceeded and retry the failed call.
+//
+// A result is returned for every restored zone, the first error is returned.
+func (c *Client) Restore(ctx context.Context, s *Snapshot, opts *RestoreOptions) ([]*RestoreResult, error) {
+	if opts == nil {
+		opts = &RestoreOptions{}
+	}
+	limiter := &rateLimiter{}
+	lc := &limitedClient{c: c, l: limiter}
+
+	var zones []*Zone
+	err := limiter.do(ctx, func() (err error) {
+		zones, err = c.ListAllZones(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	live := map[string]*Zone{}
+	for _, z := range zones {
+		live[strings.ToLower(Fqdn(z.Name))] = z
+	}
+
+	results := []*RestoreResult{}
+	for _, z := range s.Zones {
+		if opts.Zone == "" || strings.EqualFold(Fqdn(opts.Zone), Fqdn(z.Name)) {
+			results = append(results, &RestoreResult{Zone: z.Name})
+		}
+	}
+	if opts.Zone != "" && len(results) == 0 {
+		return nil, fmt.Errorf("zone %s not found in snapshot", opts.Zone)
+	}
+
+	workers := opts.Concurrency
+	if workers <= 0 {
+		workers = DefaultRestoreConcurrency
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				res := results[i]
+				res.Err = restoreZone(ctx, lc, s.zone(res.Zone), live[strings.ToLower(Fqdn(res.Zone))], res, opts.DryRun)
+			}
+		}()
+	}
+	for i := range results {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	for _, res := range results {
+		if res.Err != nil {
+			return results, res.Err
+		}
+	}
+	return results, nil
+}
+
+// restoreZone restores a single zone, `current` is nil when the zone doesn't
+// exist.
+func restoreZone(ctx context.Context, c *limitedClient, zone, current *Zone, res *RestoreResult, dryRun bool) error {
+	attrs := &Zone{Name: zone.Name, Tags: zone.Tags, TemplateID: zone.TemplateID}
+
+	switch {
+	case current == nil && dryRun:
+		res.Created = true
+	case current == nil:
+		created, err := c.CreateZone(ctx, attrs)
+		if err != nil {
+			return err
+		}
+		res.Created = true
+		current = created
+	case current.TemplateID != zone.TemplateID || !sameTags(current.Tags, zone.Tags):
+		res.ZoneUpdated = true
+		if !dryRun {
+			if _, err := c.UpdateZone(ctx, current.ID, attrs); err != nil {
+				return err
+			}
+		}
+	}
+
+	var records []*Record
+	if current != nil {
+		var err error
+		if records, err = c.ListAllRecords(ctx, current); err != nil {
+			return err
+		}
+	}
+
+	res.Changes = Diff(zone.Records, records, &DiffOptions{Zone: zone.Name})
+	if dryRun || res.Changes.Empty() {
+		return nil
+	}
+
+	var err error
+	res.Results, err = applyChanges(ctx, c, current, res.Changes)
+	return err
+}
+
+// zone returns the snapshot zone named `name`.
+func (s *Snapshot) zone(name string) *Zone {
+	for _, z := range s.Zones {
+		if z.Name == name {
+			return z
+		}
+	}
+	return nil
+}
+
+// sameTags reports whether both tag lists are equal, nil and empty lists are equal.
+func sameTags(a, b []string) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// Write writes the snapshot as JSON lines.
+func (s *Snapshot) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	if err := enc.Encode(s); err != nil {
+		return err
+	}
+	for _, z := range s.Zones {
+		if err := enc.Encode(z); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// WriteFile writes the snapshot to a file.
+func (s *Snapshot) WriteFile(filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	err = s.Write(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// ReadSnapshot reads a snapshot written by Snapshot.Write.
+func ReadSnapshot(r io.Reader) (*Snapshot, error) {
+	dec := json.NewDecoder(r)
+
+	var s Snapshot
+	if err := dec.Decode(&s); err != nil {
+		return nil, err
+	}
+	if s.Version != SnapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d", s.Version)
+	}
+
+	for {
+		var z Zone
+		err := dec.Decode(&z)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		s.Zones = append(s.Zones, &z)
+	}
+
+	return &s, nil
+}
+
+// ReadSnapshotFile reads a snapshot file written by Snapshot.WriteFile.
+func ReadSnapshotFile(filename string) (*Snapshot, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadSnapshot(f)
+}
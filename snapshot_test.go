@@ -0,0 +1,139 @@
+package luadns_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/luadns/luadns-go"
+	"github.com/luadns/luadns-go/internal/fakeapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	server := fakeapi.New()
+	defer server.Close()
+
+	org := server.AddZone("example.org",
+		&luadns.Record{Name: "www.example.org.", Type: "A", Content: "1.1.1.1", TTL: 300},
+		&luadns.Record{Name: "example.org.", Type: "MX", Content: "10 mail.example.org.", TTL: 300},
+	)
+	net := server.AddZone("example.net", &luadns.Record{Name: "example.net.", Type: "TXT", Content: "hello", TTL: 300})
+	c := server.Client()
+	ctx := context.Background()
+
+	_, err := c.UpdateZone(ctx, org.ID, &luadns.Zone{Name: org.Name, Tags: []string{"prod"}})
+	assert.NoError(t, err)
+
+	snapshot, err := c.Snapshot(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, snapshot.Zones, 2)
+
+	// Round trip through JSON lines.
+	var buf bytes.Buffer
+	assert.NoError(t, snapshot.Write(&buf))
+	assert.Equal(t, 3, strings.Count(buf.String(), "\n"))
+	snapshot, err = luadns.ReadSnapshot(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"prod"}, snapshot.Zones[0].Tags)
+
+	// Break the account.
+	www := server.Records(org.ID)[3]
+	server.Touch(org.ID, www.ID, "2.2.2.2")
+	_, err = c.UpdateZone(ctx, org.ID, &luadns.Zone{Name: org.Name})
+	assert.NoError(t, err)
+	_, err = c.DeleteZone(ctx, net.ID)
+	assert.NoError(t, err)
+
+	// Dry run reports changes without applying them.
+	server.ResetRequests()
+	results, err := c.Restore(ctx, snapshot, &luadns.RestoreOptions{DryRun: true})
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+	assert.True(t, results[0].ZoneUpdated)
+	assert.Equal(t, "--- current\n+++ desired\n-www.example.org. 300 IN A 2.2.2.2\n+www.example.org. 300 IN A 1.1.1.1\n", results[0].Changes.String())
+	assert.True(t, results[1].Created)
+	assert.Len(t, results[1].Changes.Changes, 1)
+	for _, req := range server.Requests() {
+		assert.True(t, strings.HasPrefix(req, "GET "), req)
+	}
+
+	// Restore pauses on rate limits and retries.
+	var limited int32
+	server.Fail = func(r *http.Request) int {
+		if r.Method == http.MethodPost && atomic.AddInt32(&limited, 1) == 1 {
+			return http.StatusTooManyRequests
+		}
+		return 0
+	}
+	results, err = c.Restore(ctx, snapshot, &luadns.RestoreOptions{Concurrency: 2})
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+
+	restored, err := c.Snapshot(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, restored.Zones, 2)
+	for i, z := range restored.Zones {
+		assert.Equal(t, snapshot.Zones[i].Name, z.Name)
+		assert.Equal(t, snapshot.Zones[i].Tags, z.Tags)
+		assert.True(t, luadns.Diff(snapshot.Zones[i].Records, z.Records, &luadns.DiffOptions{Zone: z.Name}).Empty())
+	}
+}
+
+func TestRestoreZoneFilter(t *testing.T) {
+	server := fakeapi.New()
+	defer server.Close()
+
+	c := server.Client()
+	ctx := context.Background()
+	snapshot := &luadns.Snapshot{Version: luadns.SnapshotVersion, Zones: []*luadns.Zone{
+		{Name: "example.org", Records: []*luadns.Record{{Name: "example.org.", Type: "A", Content: "1.1.1.1", TTL: 300}}},
+		{Name: "example.net"},
+	}}
+
+	results, err := c.Restore(ctx, snapshot, &luadns.RestoreOptions{Zone: "example.org."})
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+	assert.Len(t, server.Zones(), 1)
+
+	_, err = c.Restore(ctx, snapshot, &luadns.RestoreOptions{Zone: "example.com"})
+	assert.EqualError(t, err, "zone example.com not found in snapshot")
+}
+
+func TestRestoreRetriesFailedCall(t *testing.T) {
+	server := fakeapi.New()
+	defer server.Close()
+
+	c := server.Client()
+	ctx := context.Background()
+	snapshot := &luadns.Snapshot{Version: luadns.SnapshotVersion, Zones: []*luadns.Zone{
+		{Name: "example.org", Records: []*luadns.Record{{Name: "www.example.org.", Type: "A", Content: "1.1.1.1", TTL: 300}}},
+	}}
+
+	// Only the rate limited call is retried, the zone is created once.
+	var limited int32
+	server.Fail = func(r *http.Request) int {
+		if strings.HasSuffix(r.URL.Path, "/records/create_many") && atomic.AddInt32(&limited, 1) == 1 {
+			return http.StatusTooManyRequests
+		}
+		return 0
+	}
+	_, err := c.Restore(ctx, snapshot, nil)
+	assert.NoError(t, err)
+
+	zones := server.Zones()
+	assert.Len(t, zones, 1)
+	zoneID := strconv.FormatInt(zones[0].ID, 10)
+	assert.Equal(t, []string{
+		"GET /zones",
+		"POST /zones",
+		"GET /zones/" + zoneID + "/records",
+		"POST /zones/" + zoneID + "/records/create_many",
+		"POST /zones/" + zoneID + "/records/create_many",
+	}, server.Requests())
+	assert.Len(t, server.Records(zones[0].ID), 4)
+}
@@ -0,0 +1,265 @@
+package luadns
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// rollbackTimeout bounds the time spent replaying inverse operations.
+const rollbackTimeout = time.Minute
+
+// TxError represents a failed transaction, it holds the original error and
+// the errors returned while rolling back completed steps.
+type TxError struct {
+	Err            error
+	RollbackErrors []error
+}
+
+func (e *TxError) Error() string {
+	if len(e.RollbackErrors) == 0 {
+		return e.Err.Error() + " (rolled back)"
+	}
+
+	errs := []string{}
+	for _, err := range e.RollbackErrors {
+		errs = append(errs, err.Error())
+	}
+	return e.Err.Error() + " (rollback failed: " + strings.Join(errs, "; ") + ")"
+}
+
+func (e *TxError) Unwrap() error {
+	return e.Err
+}
+
+// undoFunc reverts a completed step.
+type undoFunc func(ctx context.Context) error
+
+// Tx represents a sequence of record operations which can be rolled back.
+// Each successful operation records its inverse, built from the records
+// returned by the API (or read before the operation for updates).
+type Tx struct {
+	c    *Client
+	undo []undoFunc
+}
+
+// Begin starts a new transaction.
+func (c *Client) Begin() *Tx {
+	return &Tx{c: c}
+}
+
+// Transaction runs `fn` in a transaction, completed steps are rolled back in
+// reverse order when `fn` returns an error. The returned error is a *TxError.
+func (c *Client) Transaction(ctx context.Context, fn func(tx *Tx) error) error {
+	tx := c.Begin()
+
+	if err := fn(tx); err != nil {
+		return tx.Rollback(ctx, err)
+	}
+
+	tx.Commit()
+	return nil
+}
+
+// Commit discards recorded inverse operations.
+func (tx *Tx) Commit() {
+	tx.undo = nil
+}
+
+// Rollback replays inverse operations in reverse order and returns a *TxError
+// wrapping the original error `cause`.
+//
+// Inverse operations still run when `ctx` is cancelled (the usual reason of
+// a failed transaction), they are bounded by a one minute timeout instead.
+func (tx *Tx) Rollback(ctx context.Context, cause error) error {
+	txErr := &TxError{Err: cause}
+
+	ctx, cancel := context.WithTimeout(detachedContext{ctx}, rollbackTimeout)
+	defer cancel()
+
+	for i := len(tx.undo) - 1; i >= 0; i-- {
+		if err := tx.undo[i](ctx); err != nil {
+			txErr.RollbackErrors = append(txErr.RollbackErrors, err)
+		}
+	}
+	tx.undo = nil
+
+	return txErr
+}
+
+// ApplyChanges applies a change set (see Client.ApplyChanges) recording inverse operations.
+func (tx *Tx) ApplyChanges(ctx context.Context, zone *Zone, changes ChangeSet) ([]*ChangeResult, error) {
+	return applyChanges(ctx, tx, zone, changes)
+}
+
+// ListAllRecords returns the zone records, reads have no inverse operation.
+func (tx *Tx) ListAllRecords(ctx context.Context, zone *Zone) ([]*Record, error) {
+	return tx.c.ListAllRecords(ctx, zone)
+}
+
+// CreateRecord creates a record, the inverse operation deletes it.
+func (tx *Tx) CreateRecord(ctx context.Context, zone *Zone, attrs *Record) (*Record, error) {
+	record, err := tx.c.CreateRecord(ctx, zone, attrs)
+	if err != nil {
+		return nil, err
+	}
+
+	tx.undo = append(tx.undo, func(ctx context.Context) error {
+		_, err := tx.c.DeleteRecord(ctx, zone, record.ID)
+		return err
+	})
+	return record, nil
+}
+
+// UpdateRecord updates a record, the inverse operation restores the previous record.
+func (tx *Tx) UpdateRecord(ctx context.Context, zone *Zone, recordID int64, attrs *Record) (*Record, error) {
+	previous, err := tx.c.GetRecord(ctx, zone, recordID)
+	if err != nil {
+		return nil, err
+	}
+
+	record, err := tx.c.UpdateRecord(ctx, zone, recordID, attrs)
+	if err != nil {
+		return nil, err
+	}
+
+	tx.undo = append(tx.undo, func(ctx context.Context) error {
+		_, err := tx.c.UpdateRecord(ctx, zone, recordID, previous)
+		return err
+	})
+	return record, nil
+}
+
+// DeleteRecord deletes a record, the inverse operation creates it again.
+func (tx *Tx) DeleteRecord(ctx context.Context, zone *Zone, recordID int64) (*Record, error) {
+	record, err := tx.c.DeleteRecord(ctx, zone, recordID)
+	if err != nil {
+		return nil, err
+	}
+
+	tx.undo = append(tx.undo, func(ctx context.Context) error {
+		_, err := tx.c.CreateRecord(ctx, zone, &Record{Name: record.Name, Type: record.Type, Content: record.Content, TTL: record.TTL})
+		return err
+	})
+	return record, nil
+}
+
+// CreateManyRecords creates records, the inverse operation deletes created records.
+func (tx *Tx) CreateManyRecords(ctx context.Context, zone *Zone, recs []*RR) ([]*Record, error) {
+	records, err := tx.c.CreateManyRecords(ctx, zone, recs)
+	if err != nil {
+		return nil, err
+	}
+
+	tx.undo = append(tx.undo, func(ctx context.Context) error {
+		return tx.deleteExact(ctx, zone, records)
+	})
+	return records, nil
+}
+
+// DeleteManyRecords deletes records, the inverse operation creates deleted records.
+func (tx *Tx) DeleteManyRecords(ctx context.Context, zone *Zone, recs []*RR) ([]*Record, error) {
+	records, err := tx.c.DeleteManyRecords(ctx, zone, recs)
+	if err != nil {
+		return nil, err
+	}
+
+	tx.undo = append(tx.undo, func(ctx context.Context) error {
+		return tx.create(ctx, zone, records)
+	})
+	return records, nil
+}
+
+// UpdateManyRecords replaces (name, type) record sets, the inverse operation
+// restores the sets read before the update and deletes the records it
+// created in new sets.
+func (tx *Tx) UpdateManyRecords(ctx context.Context, zone *Zone, recs []*RR) ([]*Record, error) {
+	current, err := tx.c.ListAllRecords(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	sets := map[string]bool{}
+	for _, rr := range recs {
+		sets[setKey(&Record{Name: rr.Name, Type: rr.Type})] = true
+	}
+	previous := []*Record{}
+	for _, r := range current {
+		if sets[setKey(r)] {
+			previous = append(previous, r)
+		}
+	}
+
+	// Find (name, type) sets created by the update.
+	added := sets
+	for _, r := range previous {
+		delete(added, setKey(r))
+	}
+
+	records, err := tx.c.UpdateManyRecords(ctx, zone, recs)
+	if err != nil {
+		return nil, err
+	}
+
+	tx.undo = append(tx.undo, func(ctx context.Context) error {
+		if len(previous) > 0 {
+			rrs := []*RR{}
+			for _, r := range previous {
+				rrs = append(rrs, exactRR(r))
+			}
+			if _, err := tx.c.UpdateManyRecords(ctx, zone, rrs); err != nil {
+				return err
+			}
+		}
+
+		// Only records created by the update are deleted from new sets.
+		for _, r := range records {
+			if added[setKey(r)] {
+				if _, err := tx.c.DeleteRecord(ctx, zone, r.ID); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	return records, nil
+}
+
+func (tx *Tx) deleteExact(ctx context.Context, zone *Zone, records []*Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	rrs := []*RR{}
+	for _, r := range records {
+		rrs = append(rrs, exactRR(r))
+	}
+	_, err := tx.c.DeleteManyRecords(ctx, zone, rrs)
+	return err
+}
+
+func (tx *Tx) create(ctx context.Context, zone *Zone, records []*Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	rrs := []*RR{}
+	for _, r := range records {
+		rrs = append(rrs, exactRR(r))
+	}
+	_, err := tx.c.CreateManyRecords(ctx, zone, rrs)
+	return err
+}
+
+// detachedContext keeps the values of a context but not its cancellation.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+
+func (c detachedContext) Value(key any) any {
+	return c.parent.Value(key)
+}
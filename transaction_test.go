@@ -0,0 +1,112 @@
+package luadns_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/luadns/luadns-go"
+	"github.com/luadns/luadns-go/internal/fakeapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransactionRollback(t *testing.T) {
+	server := fakeapi.New()
+	defer server.Close()
+
+	zone := server.AddZone("example.org",
+		&luadns.Record{Name: "example.org.", Type: "A", Content: "1.1.1.1", TTL: 3600},
+		&luadns.Record{Name: "example.org.", Type: "A", Content: "2.2.2.2", TTL: 3600},
+		&luadns.Record{Name: "old.example.org.", Type: "TXT", Content: "old", TTL: 3600},
+	)
+	before := server.Records(zone.ID)
+	c := server.Client()
+	ctx := context.Background()
+
+	desired := []*luadns.Record{
+		{Name: "example.org.", Type: "A", Content: "3.3.3.3", TTL: 3600},
+		{Name: "new.example.org.", Type: "A", Content: "invalid", TTL: 3600},
+	}
+	changes := luadns.Diff(desired, before, &luadns.DiffOptions{Zone: zone.Name})
+
+	err := c.Transaction(ctx, func(tx *luadns.Tx) error {
+		_, err := tx.ApplyChanges(ctx, zone, changes)
+		return err
+	})
+	assert.EqualError(t, err, "Invalid data for content: invalid IPv4 address (rolled back)")
+
+	var txErr *luadns.TxError
+	assert.True(t, errors.As(err, &txErr))
+	assert.IsType(t, &luadns.BadRequestError{}, txErr.Err)
+
+	diff := luadns.Diff(before, server.Records(zone.ID), &luadns.DiffOptions{Zone: zone.Name})
+	assert.True(t, diff.Empty(), diff.String())
+}
+
+func TestTransactionRollbackErrors(t *testing.T) {
+	server := fakeapi.New()
+	defer server.Close()
+
+	zone := server.AddZone("example.org", &luadns.Record{Name: "www.example.org.", Type: "A", Content: "1.1.1.1", TTL: 3600})
+	record := server.Records(zone.ID)[3]
+	c := server.Client()
+	ctx := context.Background()
+
+	err := c.Transaction(ctx, func(tx *luadns.Tx) error {
+		if _, err := tx.DeleteRecord(ctx, zone, record.ID); err != nil {
+			return err
+		}
+		if _, err := tx.CreateManyRecords(ctx, zone, []*luadns.RR{{Name: "a.example.org.", Type: "TXT", Content: "a"}}); err != nil {
+			return err
+		}
+
+		server.Fail = func(r *http.Request) int { return http.StatusForbidden }
+		_, err := tx.CreateRecord(ctx, zone, &luadns.Record{Name: "b.example.org.", Type: "TXT", Content: "b"})
+		return err
+	})
+	assert.EqualError(t, err, "Forbidden: rejected (rollback failed: Forbidden: rejected; Forbidden: rejected)")
+}
+
+func TestTransactionCommit(t *testing.T) {
+	server := fakeapi.New()
+	defer server.Close()
+
+	zone := server.AddZone("example.org", &luadns.Record{Name: "www.example.org.", Type: "A", Content: "1.1.1.1", TTL: 3600})
+	c := server.Client()
+	ctx := context.Background()
+
+	err := c.Transaction(ctx, func(tx *luadns.Tx) error {
+		_, err := tx.UpdateManyRecords(ctx, zone, []*luadns.RR{
+			{Name: "www.example.org.", Type: "A", Content: "2.2.2.2", TTL: 3600},
+			{Name: "api.example.org.", Type: "A", Content: "3.3.3.3", TTL: 3600},
+		})
+		return err
+	})
+	assert.NoError(t, err)
+	assert.Len(t, server.Records(zone.ID), 5)
+
+	// Rolling back an update restores replaced sets and removes added sets.
+	tx := c.Begin()
+	_, err = tx.UpdateManyRecords(ctx, zone, []*luadns.RR{
+		{Name: "www.example.org.", Type: "A", Content: "4.4.4.4", TTL: 3600},
+		{Name: "mail.example.org.", Type: "A", Content: "5.5.5.5", TTL: 3600},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, server.Records(zone.ID), 6)
+
+	// Records added by others are kept, rollback runs with a cancelled context.
+	_, err = c.CreateRecord(ctx, zone, &luadns.Record{Name: "mail.example.org.", Type: "A", Content: "6.6.6.6", TTL: 3600})
+	assert.NoError(t, err)
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	err = tx.Rollback(cancelled, errors.New("abort"))
+	assert.EqualError(t, err, "abort (rolled back)")
+
+	records := server.Records(zone.ID)
+	if assert.Len(t, records, 6) {
+		assert.Equal(t, "6.6.6.6", records[4].Content)
+		assert.Equal(t, "2.2.2.2", records[5].Content)
+	}
+}
@@ -1,6 +1,9 @@
 package luadns
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 type Zone struct {
 	ID         int64     `json:"id,omitempty"`
@@ -11,3 +14,22 @@ type Zone struct {
 	CreatedAt  time.Time `json:"created_at,omitempty"`
 	UpdatedAt  time.Time `json:"updated_at,omitempty"`
 }
+
+// FindZone returns the zone holding `name` (longest match), nil when `name`
+// isn't in any of `zones`. Names are compared case insensitively, with or
+// without trailing dot.
+func FindZone(zones []*Zone, name string) *Zone {
+	name = strings.ToLower(Fqdn(name))
+
+	var found *Zone
+	for _, z := range zones {
+		origin := strings.ToLower(Fqdn(z.Name))
+		if name != origin && !strings.HasSuffix(name, "."+origin) {
+			continue
+		}
+		if found == nil || len(origin) > len(Fqdn(found.Name)) {
+			found = z
+		}
+	}
+	return found
+}
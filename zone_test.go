@@ -0,0 +1,32 @@
+package luadns_test
+
+import (
+	"testing"
+
+	"github.com/luadns/luadns-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindZone(t *testing.T) {
+	zones := []*luadns.Zone{{Name: "example.org"}, {Name: "sub.example.org."}, {Name: "example.com"}}
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"example.org", "example.org"},
+		{"www.example.org.", "example.org"},
+		{"WWW.Sub.Example.org", "sub.example.org."},
+		{"_acme-challenge.a.sub.example.org.", "sub.example.org."},
+		{"notexample.org", ""},
+		{"example.net.", ""},
+	}
+	for _, tt := range tests {
+		zone := luadns.FindZone(zones, tt.name)
+		if tt.want == "" {
+			assert.Nil(t, zone, tt.name)
+		} else if assert.NotNil(t, zone, tt.name) {
+			assert.Equal(t, tt.want, zone.Name, tt.name)
+		}
+	}
+}
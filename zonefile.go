@@ -0,0 +1,171 @@
+package luadns
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ZoneFileMode controls how a class of records is written to a zone file.
+type ZoneFileMode int
+
+const (
+	ZoneFileInclude ZoneFileMode = iota // write records as regular entries
+	ZoneFileComment                     // write records commented out
+	ZoneFileOmit                        // skip records
+)
+
+// ZoneFileOptions represents options used when exporting zone files.
+type ZoneFileOptions struct {
+	TTL       uint32       // $TTL directive, defaults to the most used record TTL
+	Generated ZoneFileMode // SOA and apex NS records managed by LuaDNS
+	Extended  ZoneFileMode // LuaDNS only types (ALIAS, FORWARD, REDIRECT, SLAVE)
+}
+
+// ExportZoneFile writes zone records to `w` as a RFC 1035 master file.
+func ExportZoneFile(ctx context.Context, c *Client, zone *Zone, w io.Writer, opts *ZoneFileOptions) error {
+	records, err := c.ListAllRecords(ctx, zone)
+	if err != nil {
+		return err
+	}
+
+	return WriteZoneFile(w, zone, records, opts)
+}
+
+// WriteZoneFile writes supplied records to `w` as a RFC 1035 master file
+// using $ORIGIN, $TTL directives, relative names and aligned columns.
+func WriteZoneFile(w io.Writer, zone *Zone, records []*Record, opts *ZoneFileOptions) error {
+	if opts == nil {
+		opts = &ZoneFileOptions{}
+	}
+	origin := Fqdn(zone.Name)
+
+	type line struct {
+		comment bool
+		name    string // owner name, prefixed by "; " when commented
+		ttl     string
+		typ     string
+		content string
+	}
+
+	lines := []line{}
+	for _, r := range sortZoneRecords(origin, records) {
+		mode := ZoneFileInclude
+		if r.IsGenerated(origin) {
+			mode = opts.Generated
+		} else if IsExtendedType(r.Type) {
+			mode = opts.Extended
+		}
+		if mode == ZoneFileOmit {
+			continue
+		}
+
+		lines = append(lines, line{
+			comment: mode == ZoneFileComment,
+			name:    RelativeName(r.Name, origin),
+			ttl:     strconv.FormatUint(uint64(r.TTL), 10),
+			typ:     r.Type,
+			content: zoneFileContent(r.Type, r.Content),
+		})
+	}
+
+	ttl := opts.TTL
+	if ttl == 0 {
+		ttl = DefaultTTL(records)
+	}
+
+	nameWidth, ttlWidth, typeWidth := 0, 0, 0
+	for i, l := range lines {
+		if l.comment {
+			lines[i].name = "; " + l.name
+		}
+		nameWidth = maxInt(nameWidth, len(lines[i].name))
+		ttlWidth = maxInt(ttlWidth, len(l.ttl))
+		typeWidth = maxInt(typeWidth, len(l.typ))
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "$ORIGIN %s\n", origin)
+	if ttl != 0 {
+		fmt.Fprintf(bw, "$TTL %d\n", ttl)
+	}
+	bw.WriteString("\n")
+
+	for _, l := range lines {
+		fmt.Fprintf(bw, "%-*s %*s IN %-*s %s\n", nameWidth, l.name, ttlWidth, l.ttl, typeWidth, l.typ, l.content)
+	}
+
+	return bw.Flush()
+}
+
+// sortZoneRecords returns a copy of records ordered as expected in a zone
+// file: SOA first, apex records next, then by name and type.
+func sortZoneRecords(origin string, records []*Record) []*Record {
+	sorted := make([]*Record, len(records))
+	copy(sorted, records)
+
+	rank := func(r *Record) int {
+		switch {
+		case r.Type == TypeSOA:
+			return 0
+		case strings.EqualFold(Fqdn(r.Name), origin):
+			return 1
+		default:
+			return 2
+		}
+	}
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if ra, rb := rank(a), rank(b); ra != rb {
+			return ra < rb
+		}
+		if na, nb := strings.ToLower(a.Name), strings.ToLower(b.Name); na != nb {
+			return na < nb
+		}
+		return a.Type < b.Type
+	})
+
+	return sorted
+}
+
+// zoneFileContent formats record content using master file syntax.
+func zoneFileContent(typ, content string) string {
+	switch typ {
+	case TypeTXT, TypeSPF:
+		return quoteTXT(content)
+	}
+	return content
+}
+
+// quoteTXT quotes TXT content splitting it into 255 bytes character strings,
+// quotes and backslashes are escaped. Content is always treated as raw text,
+// importing the zone file joins the strings back.
+func quoteTXT(s string) string {
+	parts := []string{}
+	for {
+		n := len(s)
+		if n > 255 {
+			n = 255
+		}
+		chunk := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s[:n])
+		parts = append(parts, `"`+chunk+`"`)
+		s = s[n:]
+		if s == "" {
+			break
+		}
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,492 @@
+package luadns
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// importChunkSize is the default number of records sent in a single request.
+const importChunkSize = 100
+
+// ZoneFileError represents a syntax error found at a specific zone file line.
+type ZoneFileError struct {
+	File    string
+	Line    int
+	Message string
+}
+
+func (e *ZoneFileError) Error() string {
+	if e.File == "" {
+		return "line " + strconv.Itoa(e.Line) + ": " + e.Message
+	}
+	return e.File + ":" + strconv.Itoa(e.Line) + ": " + e.Message
+}
+
+// ZoneFileErrors represents a list of errors found while parsing a zone file.
+type ZoneFileErrors []*ZoneFileError
+
+func (e ZoneFileErrors) Error() string {
+	errs := []string{}
+	for _, err := range e {
+		errs = append(errs, err.Error())
+	}
+	return strings.Join(errs, "; ")
+}
+
+// ParseZoneFileOptions represents options used when parsing zone files.
+type ParseZoneFileOptions struct {
+	Filename       string // file name used in errors and to resolve $INCLUDE paths
+	TTL            uint32 // TTL used when neither $TTL nor an explicit TTL are set
+	IncludeAllowed bool   // allow $INCLUDE directives
+}
+
+// ImportZoneFileOptions represents options used when importing zone files.
+type ImportZoneFileOptions struct {
+	ParseZoneFileOptions
+	ChunkSize int // number of records per CreateManyRecords call, defaults to 100
+}
+
+// ImportZoneFile parses a RFC 1035 master file and creates the records into
+// `zone` using CreateManyRecords calls. Records managed by LuaDNS (SOA, apex NS)
+// are skipped. Nothing is created when the file contains errors.
+func ImportZoneFile(ctx context.Context, c *Client, zone *Zone, r io.Reader, opts *ImportZoneFileOptions) ([]*Record, error) {
+	if opts == nil {
+		opts = &ImportZoneFileOptions{}
+	}
+
+	rrs, err := ParseZoneFile(r, zone.Name, &opts.ParseZoneFileOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	origin := Fqdn(zone.Name)
+	recs := []*RR{}
+	for _, rr := range rrs {
+		if (&Record{Name: rr.Name, Type: rr.Type}).IsGenerated(origin) {
+			continue
+		}
+		recs = append(recs, rr)
+	}
+
+	return createInChunks(ctx, c, zone, recs, opts.ChunkSize)
+}
+
+// createInChunks creates records using CreateManyRecords calls of at most
+// `size` records, records created before a failure are returned.
+func createInChunks(ctx context.Context, c *Client, zone *Zone, recs []*RR, size int) ([]*Record, error) {
+	if size <= 0 {
+		size = importChunkSize
+	}
+
+	records := []*Record{}
+	for len(recs) > 0 {
+		n := size
+		if n > len(recs) {
+			n = len(recs)
+		}
+
+		created, err := c.CreateManyRecords(ctx, zone, recs[:n])
+		if err != nil {
+			return records, err
+		}
+		records = append(records, created...)
+		recs = recs[n:]
+	}
+
+	return records, nil
+}
+
+// ParseZoneFile parses a BIND style master file into RRs using fully
+// qualified names. It supports $ORIGIN, $TTL, $INCLUDE directives, multi-line
+// entries using parentheses, relative names and `@`.
+//
+// Parsing continues after invalid entries, all errors are returned as ZoneFileErrors.
+func ParseZoneFile(r io.Reader, origin string, opts *ParseZoneFileOptions) ([]*RR, error) {
+	if opts == nil {
+		opts = &ParseZoneFileOptions{}
+	}
+
+	p := &zoneParser{
+		opts:   opts,
+		origin: Fqdn(origin),
+		ttl:    opts.TTL,
+	}
+	p.parse(r, opts.Filename, 0)
+
+	if len(p.errs) > 0 {
+		return p.rrs, p.errs
+	}
+	return p.rrs, nil
+}
+
+// maxIncludeDepth limits nested $INCLUDE directives.
+const maxIncludeDepth = 8
+
+type zoneToken struct {
+	text   string
+	quoted bool
+}
+
+type zoneEntry struct {
+	line   int
+	blank  bool // entry starts with whitespace, owner is inherited
+	tokens []zoneToken
+}
+
+type zoneParser struct {
+	opts    *ParseZoneFileOptions
+	origin  string
+	ttl     uint32 // $TTL or last explicit TTL
+	hasTTL  bool   // $TTL directive seen
+	owner   string // previous owner name
+	rrs     []*RR
+	errs    ZoneFileErrors
+	curFile string
+}
+
+func (p *zoneParser) errorf(line int, format string, args ...any) {
+	p.errs = append(p.errs, &ZoneFileError{File: p.curFile, Line: line, Message: fmt.Sprintf(format, args...)})
+}
+
+func (p *zoneParser) parse(r io.Reader, filename string, depth int) {
+	prevFile := p.curFile
+	p.curFile = filename
+	defer func() { p.curFile = prevFile }()
+
+	entries, err := scanZoneEntries(r)
+	for _, e := range entries {
+		p.parseEntry(e, depth)
+	}
+	if err != nil {
+		err.File = filename
+		p.errs = append(p.errs, err)
+	}
+}
+
+func (p *zoneParser) parseEntry(e *zoneEntry, depth int) {
+	tokens := e.tokens
+
+	if !e.blank && strings.HasPrefix(tokens[0].text, "$") {
+		p.parseDirective(e, depth)
+		return
+	}
+
+	owner := p.owner
+	if !e.blank {
+		owner = AbsoluteName(tokens[0].text, p.origin)
+		tokens = tokens[1:]
+	}
+	if owner == "" {
+		p.errorf(e.line, "missing owner name")
+		return
+	}
+	p.owner = owner
+
+	ttl, explicitTTL := p.ttl, false
+	for len(tokens) > 0 {
+		tok := tokens[0].text
+		if strings.EqualFold(tok, "IN") {
+			tokens = tokens[1:]
+			continue
+		}
+		if isZoneClass(tok) {
+			p.errorf(e.line, "unsupported class %s", tok)
+			return
+		}
+		if v, err := parseTTL(tok); err == nil && !explicitTTL {
+			ttl, explicitTTL = v, true
+			tokens = tokens[1:]
+			continue
+		}
+		break
+	}
+
+	if len(tokens) == 0 {
+		p.errorf(e.line, "missing record type")
+		return
+	}
+	typ := strings.ToUpper(tokens[0].text)
+	rdata := tokens[1:]
+	if len(rdata) == 0 {
+		p.errorf(e.line, "missing %s record data", typ)
+		return
+	}
+
+	if explicitTTL && !p.hasTTL {
+		p.ttl = ttl
+	}
+
+	content, err := p.content(typ, rdata)
+	if err != nil {
+		p.errorf(e.line, "%s", err)
+		return
+	}
+
+	p.rrs = append(p.rrs, &RR{Name: owner, Type: typ, Content: content, TTL: ttl})
+}
+
+func (p *zoneParser) parseDirective(e *zoneEntry, depth int) {
+	args := e.tokens[1:]
+
+	switch strings.ToUpper(e.tokens[0].text) {
+	case "$ORIGIN":
+		if len(args) != 1 {
+			p.errorf(e.line, "$ORIGIN requires one argument")
+			return
+		}
+		p.origin = AbsoluteName(args[0].text, p.origin)
+	case "$TTL":
+		if len(args) != 1 {
+			p.errorf(e.line, "$TTL requires one argument")
+			return
+		}
+		ttl, err := parseTTL(args[0].text)
+		if err != nil {
+			p.errorf(e.line, "invalid TTL %q", args[0].text)
+			return
+		}
+		p.ttl, p.hasTTL = ttl, true
+	case "$INCLUDE":
+		if len(args) < 1 || len(args) > 2 {
+			p.errorf(e.line, "$INCLUDE requires a file name and an optional origin")
+			return
+		}
+		if !p.opts.IncludeAllowed {
+			p.errorf(e.line, "$INCLUDE is not allowed")
+			return
+		}
+		if depth >= maxIncludeDepth {
+			p.errorf(e.line, "too many nested $INCLUDE directives")
+			return
+		}
+
+		name := args[0].text
+		if !filepath.IsAbs(name) && p.curFile != "" {
+			name = filepath.Join(filepath.Dir(p.curFile), name)
+		}
+		f, err := os.Open(name)
+		if err != nil {
+			p.errorf(e.line, "%s", err)
+			return
+		}
+		defer f.Close()
+
+		// Origin and owner changes are scoped to the included file.
+		origin, owner := p.origin, p.owner
+		if len(args) == 2 {
+			p.origin = AbsoluteName(args[1].text, p.origin)
+		}
+		p.parse(f, name, depth+1)
+		p.origin, p.owner = origin, owner
+	default:
+		p.errorf(e.line, "unknown directive %s", e.tokens[0].text)
+	}
+}
+
+// content converts rdata tokens to the content format used by the API.
+func (p *zoneParser) content(typ string, rdata []zoneToken) (string, error) {
+	switch typ {
+	case TypeTXT, TypeSPF:
+		parts := []string{}
+		for _, t := range rdata {
+			s, err := unescapeZoneText(t.text)
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, s)
+		}
+		return strings.Join(parts, ""), nil
+	}
+
+	fields := make([]string, len(rdata))
+	for i, t := range rdata {
+		fields[i] = t.text
+		if t.quoted {
+			fields[i] = `"` + t.text + `"`
+		}
+	}
+
+	// Expand relative domain names found in record data.
+	idx := -1
+	switch typ {
+	case TypeCNAME, TypeNS, TypePTR, TypeALIAS, "DNAME":
+		idx = 0
+	case TypeMX:
+		idx = 1
+	case TypeSRV:
+		idx = 3
+	}
+	if idx >= 0 {
+		if idx >= len(fields) {
+			return "", fmt.Errorf("invalid %s record data", typ)
+		}
+		fields[idx] = AbsoluteName(fields[idx], p.origin)
+	}
+
+	return strings.Join(fields, " "), nil
+}
+
+// scanZoneEntries splits master file contents into entries, joining lines
+// enclosed in parentheses and removing comments.
+func scanZoneEntries(r io.Reader) ([]*zoneEntry, *ZoneFileError) {
+	entries := []*zoneEntry{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var cur *zoneEntry
+	parens, lineNo := 0, 0
+
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+
+		if parens == 0 {
+			cur = &zoneEntry{line: lineNo, blank: line != "" && (line[0] == ' ' || line[0] == '\t')}
+		}
+
+		for i := 0; i < len(line); {
+			switch c := line[i]; {
+			case c == ';':
+				i = len(line)
+			case c == ' ' || c == '\t' || c == '\r':
+				i++
+			case c == '(':
+				parens++
+				i++
+			case c == ')':
+				if parens == 0 {
+					return entries, &ZoneFileError{Line: lineNo, Message: "unbalanced parentheses"}
+				}
+				parens--
+				i++
+			case c == '"':
+				j := i + 1
+				for j < len(line) && line[j] != '"' {
+					if line[j] == '\\' {
+						j++
+					}
+					j++
+				}
+				if j >= len(line) {
+					return entries, &ZoneFileError{Line: lineNo, Message: "unterminated quoted string"}
+				}
+				cur.tokens = append(cur.tokens, zoneToken{text: line[i+1 : j], quoted: true})
+				i = j + 1
+			default:
+				j := i
+				for j < len(line) && !strings.ContainsRune(" \t\r;()\"", rune(line[j])) {
+					if line[j] == '\\' {
+						j++
+					}
+					j++
+				}
+				if j > len(line) {
+					j = len(line)
+				}
+				cur.tokens = append(cur.tokens, zoneToken{text: line[i:j]})
+				i = j
+			}
+		}
+
+		if parens == 0 && len(cur.tokens) > 0 {
+			entries = append(entries, cur)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return entries, &ZoneFileError{Line: lineNo, Message: err.Error()}
+	}
+	if parens != 0 {
+		return entries, &ZoneFileError{Line: cur.line, Message: "unbalanced parentheses"}
+	}
+
+	return entries, nil
+}
+
+// parseTTL parses a TTL value using seconds or BIND units (1w2d3h4m5s).
+func parseTTL(s string) (uint32, error) {
+	if n, err := strconv.ParseUint(s, 10, 32); err == nil {
+		return uint32(n), nil
+	}
+
+	var total, n uint64
+	digits := false
+	for _, c := range strings.ToLower(s) {
+		if c >= '0' && c <= '9' {
+			n = n*10 + uint64(c-'0')
+			digits = true
+			continue
+		}
+		if !digits {
+			return 0, fmt.Errorf("invalid TTL %q", s)
+		}
+		switch c {
+		case 's':
+		case 'm':
+			n *= 60
+		case 'h':
+			n *= 3600
+		case 'd':
+			n *= 86400
+		case 'w':
+			n *= 604800
+		default:
+			return 0, fmt.Errorf("invalid TTL %q", s)
+		}
+		total += n
+		n, digits = 0, false
+	}
+	if digits || total > 1<<32-1 || s == "" {
+		return 0, fmt.Errorf("invalid TTL %q", s)
+	}
+
+	return uint32(total), nil
+}
+
+func isZoneClass(s string) bool {
+	switch strings.ToUpper(s) {
+	case "CH", "CS", "HS", "ANY":
+		return true
+	}
+	return false
+}
+
+// unescapeZoneText resolves `\X` and `\DDD` escapes used in character strings.
+func unescapeZoneText(s string) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		if i >= len(s) {
+			return "", fmt.Errorf("invalid escape in %q", s)
+		}
+		if s[i] >= '0' && s[i] <= '9' {
+			if i+3 > len(s) {
+				return "", fmt.Errorf("invalid escape in %q", s)
+			}
+			n, err := strconv.ParseUint(s[i:i+3], 10, 8)
+			if err != nil {
+				return "", fmt.Errorf("invalid escape in %q", s)
+			}
+			b.WriteByte(byte(n))
+			i += 2
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+
+	return b.String(), nil
+}
@@ -0,0 +1,101 @@
+package luadns_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/luadns/luadns-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseZoneFile(t *testing.T) {
+	f, err := os.Open("testdata/zones/example.org.zone")
+	assert.NoError(t, err)
+	defer f.Close()
+
+	rrs, err := luadns.ParseZoneFile(f, "example.org", &luadns.ParseZoneFileOptions{
+		Filename:       "testdata/zones/example.org.zone",
+		IncludeAllowed: true,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []*luadns.RR{
+		{Name: "example.org.", Type: "SOA", Content: "ns1.example.net. hostmaster.example.org. 2023082501 1200 120 604800 3600", TTL: 3600},
+		{Name: "example.org.", Type: "NS", Content: "ns1.example.net.", TTL: 3600},
+		{Name: "example.org.", Type: "NS", Content: "ns2.example.net.", TTL: 3600},
+		{Name: "example.org.", Type: "A", Content: "1.1.1.1", TTL: 86400},
+		{Name: "example.org.", Type: "MX", Content: "5 mail.example.org.", TTL: 3600},
+		{Name: "www.example.org.", Type: "CNAME", Content: "example.org.", TTL: 3600},
+		{Name: "mail.example.org.", Type: "A", Content: "2.2.2.2", TTL: 300},
+		{Name: "_sip._udp.example.org.", Type: "SRV", Content: "0 0 5060 sip.example.com.", TTL: 3600},
+		{Name: "example.org.", Type: "TXT", Content: "v=spf1 a mx ~all", TTL: 3600},
+		{Name: "sub.example.org.", Type: "NS", Content: "ns1.sub.example.org.", TTL: 3600},
+		{Name: "dev.example.org.", Type: "A", Content: "4.4.4.4", TTL: 3600},
+		{Name: "api.dev.example.org.", Type: "AAAA", Content: "::1", TTL: 3600},
+		{Name: "api.example.org.", Type: "A", Content: "3.3.3.3", TTL: 3600},
+	}, rrs)
+}
+
+func TestParseZoneFileErrors(t *testing.T) {
+	input := strings.Join([]string{
+		"$TTL 300",
+		"www A",
+		"foo CH A 1.1.1.1",
+		"bar A 2.2.2.2",
+		"$INCLUDE other.zone",
+		"$FOO",
+	}, "\n")
+
+	rrs, err := luadns.ParseZoneFile(strings.NewReader(input), "example.org", nil)
+	assert.EqualError(t, err, "line 2: missing A record data; line 3: unsupported class CH; "+
+		"line 5: $INCLUDE is not allowed; line 6: unknown directive $FOO")
+	assert.Equal(t, []*luadns.RR{{Name: "bar.example.org.", Type: "A", Content: "2.2.2.2", TTL: 300}}, rrs)
+}
+
+func TestParseZoneFileRoundTrip(t *testing.T) {
+	records := []*luadns.Record{
+		{Name: "example.org.", Type: "A", Content: "1.1.1.1", TTL: 86400},
+		{Name: "example.org.", Type: "TXT", Content: `say "hello" \o/ ` + strings.Repeat("x", 300), TTL: 300},
+		{Name: "www.example.org.", Type: "ALIAS", Content: "example.net.", TTL: 300},
+	}
+
+	var buf bytes.Buffer
+	err := luadns.WriteZoneFile(&buf, &luadns.Zone{Name: "example.org"}, records, nil)
+	assert.NoError(t, err)
+
+	rrs, err := luadns.ParseZoneFile(&buf, "example.org", nil)
+	assert.NoError(t, err)
+	assert.Len(t, rrs, len(records))
+	for i, r := range records {
+		assert.Equal(t, &luadns.RR{Name: r.Name, Type: r.Type, Content: r.Content, TTL: r.TTL}, rrs[i])
+	}
+}
+
+func TestImportZoneFile(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		sendHTTPFixture(t, "/zones/5/records/create_many", w, r)
+	}))
+	defer server.Close()
+
+	f, err := os.Open("testdata/zones/example.org.zone")
+	assert.NoError(t, err)
+	defer f.Close()
+
+	c := luadns.NewClient("joe@example.com", "password", luadns.SetBaseURL(server.URL))
+	records, err := luadns.ImportZoneFile(context.Background(), c, &luadns.Zone{ID: 5, Name: "example.org"}, f, &luadns.ImportZoneFileOptions{
+		ParseZoneFileOptions: luadns.ParseZoneFileOptions{
+			Filename:       "testdata/zones/example.org.zone",
+			IncludeAllowed: true,
+		},
+		ChunkSize: 4,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls) // 10 records without SOA and apex NS
+	assert.Len(t, records, 3)
+}
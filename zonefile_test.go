@@ -0,0 +1,104 @@
+package luadns_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/luadns/luadns-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportZoneFile(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sendHTTPFixture(t, "/zones/5/records.index", w, r)
+	}))
+	defer server.Close()
+
+	c := luadns.NewClient("joe@example.com", "password", luadns.SetBaseURL(server.URL))
+	zone := &luadns.Zone{ID: 5, Name: "example.org"}
+
+	var buf bytes.Buffer
+	err := luadns.ExportZoneFile(context.Background(), c, zone, &buf, &luadns.ZoneFileOptions{Generated: luadns.ZoneFileComment})
+	assert.NoError(t, err)
+
+	expected := strings.Join([]string{
+		"$ORIGIN example.org.",
+		"$TTL 86400",
+		"",
+		"; @        3600 IN SOA   ns1.luadns.net. hostmaster.luadns.net. 1692975563 1200 120 604800 3600",
+		"@         86400 IN A     1.1.1.1",
+		"@         86400 IN MX    5 aspmx.l.google.com.",
+		"; @       86400 IN NS    ns1.luadns.net.",
+		"; @       86400 IN NS    ns2.luadns.net.",
+		"; @       86400 IN NS    ns3.luadns.net.",
+		"; @       86400 IN NS    ns4.luadns.net.",
+		`@         86400 IN TXT   "v=spf1 a mx include:_spf.google.com ~all"`,
+		"_sip._udp 86400 IN SRV   0 0 5060 sip.example.com.",
+		"mail      86400 IN CNAME ghs.google.com.",
+		"www       86400 IN CNAME example.org.",
+		"",
+	}, "\n")
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestWriteZoneFileOmitRecords(t *testing.T) {
+	zone := &luadns.Zone{Name: "example.org"}
+	records := []*luadns.Record{
+		{Name: "example.org.", Type: "NS", Content: "ns1.luadns.net.", TTL: 86400},
+		{Name: "example.org.", Type: "ALIAS", Content: "example.net.", TTL: 300},
+		{Name: "ns1.example.org.", Type: "NS", Content: "ns1.example.net.", TTL: 300},
+		{Name: "long.example.org.", Type: "TXT", Content: strings.Repeat("a", 256) + `"`, TTL: 300},
+	}
+
+	var buf bytes.Buffer
+	err := luadns.WriteZoneFile(&buf, zone, records, &luadns.ZoneFileOptions{
+		TTL:       3600,
+		Generated: luadns.ZoneFileOmit,
+		Extended:  luadns.ZoneFileOmit,
+	})
+	assert.NoError(t, err)
+
+	expected := strings.Join([]string{
+		"$ORIGIN example.org.",
+		"$TTL 3600",
+		"",
+		`long 300 IN TXT "` + strings.Repeat("a", 255) + `" "a\""`,
+		"ns1  300 IN NS  ns1.example.net.",
+		"",
+	}, "\n")
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestWriteZoneFileQuotedTXT(t *testing.T) {
+	zone := &luadns.Zone{Name: "example.org"}
+	records := []*luadns.Record{
+		{Name: "a.example.org.", Type: "TXT", Content: `"foo bar`, TTL: 300},
+		{Name: "b.example.org.", Type: "TXT", Content: `"a" b"c`, TTL: 300},
+		{Name: "c.example.org.", Type: "TXT", Content: `"` + strings.Repeat("a", 255) + `\`, TTL: 300},
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, luadns.WriteZoneFile(&buf, zone, records, &luadns.ZoneFileOptions{TTL: 300}))
+	assert.Equal(t, strings.Join([]string{
+		"$ORIGIN example.org.",
+		"$TTL 300",
+		"",
+		`a 300 IN TXT "\"foo bar"`,
+		`b 300 IN TXT "\"a\" b\"c"`,
+		`c 300 IN TXT "\"` + strings.Repeat("a", 254) + `" "a\\"`,
+		"",
+	}, "\n"), buf.String())
+
+	// Content is kept when the zone file is imported.
+	rrs, err := luadns.ParseZoneFile(&buf, "example.org", nil)
+	assert.NoError(t, err)
+	if assert.Len(t, rrs, 3) {
+		for i, rr := range rrs {
+			assert.Equal(t, records[i].Content, rr.Content)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+// Package zonespec implements a declarative, versioned zone specification
+// format used to describe zones and their records as code.
+//
+// Specs are written in YAML or JSON (JSON documents are valid YAML, both are
+// loaded by the same functions). Names are relative to the zone unless they
+// end with a dot, `@` is the zone apex.
+//
+// Version 1 format:
+//
+//	version: 1
+//	zones:
+//	  - name: example.org          # zone name (required)
+//	    tags: [production]         # zone tags
+//	    template_id: 12            # LuaDNS template ID
+//	    ttl: 3600                  # default TTL for record sets
+//	    records:
+//	      - name: "@"              # owner name (required)
+//	        type: A                # record type (required)
+//	        ttl: 300               # optional TTL, defaults to zone TTL
+//	        values: [1.1.1.1, 2.2.2.2]
+//	      - name: www
+//	        type: CNAME
+//	        value: "@"             # `value` is a shortcut for a single value
+//	      - name: "@"
+//	        type: MX
+//	        values:
+//	          - {priority: 10, host: mail}
+//	          - {priority: 20, host: mx.example.net.}
+//	      - name: _sip._udp
+//	        type: SRV
+//	        value: {priority: 0, weight: 5, port: 5060, target: sip}
+//	      - name: "@"
+//	        type: CAA
+//	        value: {flags: 0, tag: issue, value: letsencrypt.org}
+//	      - name: "@"
+//	        type: TXT
+//	        value: "v=spf1 mx ~all"
+//
+// MX, SRV and CAA values are typed mappings, they can also be written using
+// the zone file syntax (`10 mail`). Other types use string values. SOA and
+// apex NS records are managed by LuaDNS and can't be declared.
+package zonespec
@@ -0,0 +1,114 @@
+package zonespec
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+
+	api "github.com/luadns/luadns-go"
+)
+
+// FromAccount builds a spec describing every zone of the account.
+func FromAccount(ctx context.Context, c *api.Client) (*Spec, error) {
+	zones, err := c.ListAllZones(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	spec := &Spec{Version: Version, Zones: []*ZoneSpec{}}
+	for _, zone := range zones {
+		records, err := c.ListAllRecords(ctx, zone)
+		if err != nil {
+			return nil, err
+		}
+		spec.Zones = append(spec.Zones, FromZone(zone, records))
+	}
+
+	return spec, nil
+}
+
+// FromZone builds a zone spec from a zone and its records. Records managed by
+// LuaDNS (SOA, apex NS) are skipped, records sharing name, type and TTL are
+// grouped in record sets.
+func FromZone(zone *api.Zone, records []*api.Record) *ZoneSpec {
+	origin := api.Fqdn(strings.ToLower(zone.Name))
+
+	recs := []*api.Record{}
+	for _, r := range records {
+		if !r.IsGenerated(origin) {
+			recs = append(recs, r)
+		}
+	}
+	sort.SliceStable(recs, func(i, j int) bool {
+		a, b := recs[i], recs[j]
+		if na, nb := api.RelativeName(a.Name, origin), api.RelativeName(b.Name, origin); na != nb {
+			return na == "@" || (nb != "@" && na < nb)
+		}
+		return a.Type < b.Type
+	})
+
+	z := &ZoneSpec{
+		Name:       strings.TrimSuffix(zone.Name, "."),
+		Tags:       zone.Tags,
+		TemplateID: zone.TemplateID,
+		TTL:        api.DefaultTTL(recs),
+		Records:    []*RecordSet{},
+	}
+
+	index := map[string]*RecordSet{}
+	for _, r := range recs {
+		key := strings.ToLower(r.Name) + " " + r.Type + " " + strconv.FormatUint(uint64(r.TTL), 10)
+		rs, ok := index[key]
+		if !ok {
+			rs = &RecordSet{Name: api.RelativeName(r.Name, origin), Type: r.Type}
+			if r.TTL != z.TTL {
+				rs.TTL = r.TTL
+			}
+			index[key] = rs
+			z.Records = append(z.Records, rs)
+		}
+		rs.Values = append(rs.Values, contentValue(origin, r.Type, r.Content))
+	}
+
+	// Use the `value` shortcut for single values.
+	for _, rs := range z.Records {
+		if len(rs.Values) == 1 {
+			rs.Value, rs.Values = rs.Values[0], nil
+		}
+	}
+
+	return z
+}
+
+// contentValue converts API record content to a spec value.
+func contentValue(origin, typ, content string) *Value {
+	f := strings.Fields(content)
+
+	switch typ {
+	case api.TypeCNAME, api.TypeNS, api.TypePTR, api.TypeALIAS:
+		return &Value{Text: api.RelativeName(content, origin)}
+	case api.TypeMX:
+		if len(f) == 2 && isUint(f[0], 16) {
+			return &Value{Priority: uint16p(f[0]), Host: api.RelativeName(f[1], origin)}
+		}
+	case api.TypeSRV:
+		if len(f) == 4 && isUint(f[0], 16) && isUint(f[1], 16) && isUint(f[2], 16) {
+			return &Value{Priority: uint16p(f[0]), Weight: uint16p(f[1]), Port: uint16p(f[2]), Target: api.RelativeName(f[3], origin)}
+		}
+	case api.TypeCAA:
+		if len(f) >= 3 && isUint(f[0], 8) {
+			flags := uint8(*uint16p(f[0]))
+			value := strings.TrimSpace(strings.SplitN(content, f[1], 2)[1])
+			return &Value{Flags: &flags, Tag: f[1], Value: strings.Trim(value, `"`)}
+		}
+	}
+
+	return &Value{Text: content}
+}
+
+func uint16p(s string) *uint16 {
+	n, _ := strconv.ParseUint(s, 10, 16)
+	v := uint16(n)
+	return &v
+}
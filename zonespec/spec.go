@@ -0,0 +1,357 @@
+package zonespec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Version is the latest spec format version.
+const Version = 1
+
+// Spec represents a zone specification document.
+type Spec struct {
+	Version int         `yaml:"version" json:"version"`
+	Zones   []*ZoneSpec `yaml:"zones" json:"zones"`
+
+	file string
+}
+
+// ZoneSpec represents a zone and its record sets.
+type ZoneSpec struct {
+	Name       string       `yaml:"name" json:"name"`
+	Tags       []string     `yaml:"tags,omitempty" json:"tags,omitempty"`
+	TemplateID int64        `yaml:"template_id,omitempty" json:"template_id,omitempty"`
+	TTL        uint32       `yaml:"ttl,omitempty" json:"ttl,omitempty"`
+	Records    []*RecordSet `yaml:"records,omitempty" json:"records,omitempty"`
+
+	pos position
+}
+
+// RecordSet represents records sharing the same name, type and TTL.
+type RecordSet struct {
+	Name   string   `yaml:"name" json:"name"`
+	Type   string   `yaml:"type" json:"type"`
+	TTL    uint32   `yaml:"ttl,omitempty" json:"ttl,omitempty"`
+	Value  *Value   `yaml:"value,omitempty" json:"value,omitempty"`
+	Values []*Value `yaml:"values,omitempty" json:"values,omitempty"`
+
+	pos position
+}
+
+// Value represents a record value, either a string or a typed value for MX,
+// SRV and CAA records.
+type Value struct {
+	Text     string  // string value or zone file syntax
+	Priority *uint16 // MX, SRV
+	Weight   *uint16 // SRV
+	Port     *uint16 // SRV
+	Host     string  // MX
+	Target   string  // SRV
+	Flags    *uint8  // CAA
+	Tag      string  // CAA
+	Value    string  // CAA
+
+	pos position
+}
+
+// typedValue is the mapping form of Value.
+type typedValue struct {
+	Priority *uint16 `yaml:"priority,omitempty" json:"priority,omitempty"`
+	Weight   *uint16 `yaml:"weight,omitempty" json:"weight,omitempty"`
+	Port     *uint16 `yaml:"port,omitempty" json:"port,omitempty"`
+	Host     string  `yaml:"host,omitempty" json:"host,omitempty"`
+	Target   string  `yaml:"target,omitempty" json:"target,omitempty"`
+	Flags    *uint8  `yaml:"flags,omitempty" json:"flags,omitempty"`
+	Tag      string  `yaml:"tag,omitempty" json:"tag,omitempty"`
+	Value    string  `yaml:"value,omitempty" json:"value,omitempty"`
+}
+
+type position struct {
+	line   int
+	column int
+}
+
+// Error represents an invalid spec entry found at a specific location.
+type Error struct {
+	File    string
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *Error) Error() string {
+	loc := strconv.Itoa(e.Line)
+	if e.Column > 0 {
+		loc += ":" + strconv.Itoa(e.Column)
+	}
+	if e.File != "" {
+		loc = e.File + ":" + loc
+	}
+	return loc + ": " + e.Message
+}
+
+// Errors represents a list of spec errors.
+type Errors []*Error
+
+func (e Errors) Error() string {
+	errs := []string{}
+	for _, err := range e {
+		errs = append(errs, err.Error())
+	}
+	return strings.Join(errs, "; ")
+}
+
+// LoadFile loads and validates a YAML or JSON spec file.
+func LoadFile(filename string) (*Spec, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Load(f, filename)
+}
+
+// Load loads and validates a YAML or JSON spec, `filename` is used in errors.
+func Load(r io.Reader, filename string) (*Spec, error) {
+	var doc yaml.Node
+	if err := yaml.NewDecoder(r).Decode(&doc); err != nil {
+		if err == io.EOF {
+			return nil, Errors{{File: filename, Line: 1, Column: 1, Message: "empty document"}}
+		}
+		return nil, decodeError(filename, err)
+	}
+
+	errs := Errors{}
+	checkFields(filename, &doc, &errs)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	spec := &Spec{file: filename}
+	if err := doc.Decode(spec); err != nil {
+		return nil, decodeError(filename, err)
+	}
+
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+
+	return spec, nil
+}
+
+// fields lists allowed mapping keys by nesting level.
+var fields = map[string][]string{
+	"spec":   {"version", "zones"},
+	"zone":   {"name", "tags", "template_id", "ttl", "records"},
+	"record": {"name", "type", "ttl", "value", "values"},
+	"value":  {"priority", "weight", "port", "host", "target", "flags", "tag", "value"},
+}
+
+// checkFields reports unknown mapping keys in the document.
+func checkFields(filename string, doc *yaml.Node, errs *Errors) {
+	check := func(node *yaml.Node, kind string) {
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			if !contains(fields[kind], key.Value) {
+				*errs = append(*errs, &Error{File: filename, Line: key.Line, Column: key.Column, Message: "unknown field " + strconv.Quote(key.Value)})
+			}
+		}
+	}
+	get := func(node *yaml.Node, key string) *yaml.Node {
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				return node.Content[i+1]
+			}
+		}
+		return nil
+	}
+	items := func(node *yaml.Node) []*yaml.Node {
+		if node == nil || node.Kind != yaml.SequenceNode {
+			return nil
+		}
+		return node.Content
+	}
+
+	if len(doc.Content) == 0 {
+		return
+	}
+	root := doc.Content[0]
+	check(root, "spec")
+
+	for _, zone := range items(get(root, "zones")) {
+		check(zone, "zone")
+		for _, rs := range items(get(zone, "records")) {
+			check(rs, "record")
+			if v := get(rs, "value"); v != nil {
+				check(v, "value")
+			}
+			for _, v := range items(get(rs, "values")) {
+				check(v, "value")
+			}
+		}
+	}
+}
+
+var lineRe = regexp.MustCompile(`^line (\d+): (.*)$`)
+
+// decodeError converts YAML decoder errors to spec errors.
+func decodeError(filename string, err error) error {
+	terr, ok := err.(*yaml.TypeError)
+	if !ok {
+		msg := strings.TrimPrefix(err.Error(), "yaml: ")
+		line := 0
+		if m := lineRe.FindStringSubmatch(msg); m != nil {
+			line, _ = strconv.Atoi(m[1])
+			msg = m[2]
+		}
+		return Errors{{File: filename, Line: line, Message: msg}}
+	}
+
+	errs := Errors{}
+	for _, e := range terr.Errors {
+		line := 0
+		if m := lineRe.FindStringSubmatch(e); m != nil {
+			line, _ = strconv.Atoi(m[1])
+			e = m[2]
+		}
+		errs = append(errs, &Error{File: filename, Line: line, Message: e})
+	}
+	return errs
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler, it records the zone location.
+func (z *ZoneSpec) UnmarshalYAML(node *yaml.Node) error {
+	type plain ZoneSpec
+	if err := node.Decode((*plain)(z)); err != nil {
+		return err
+	}
+	z.pos = position{node.Line, node.Column}
+	return nil
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler, it records the record set location.
+func (rs *RecordSet) UnmarshalYAML(node *yaml.Node) error {
+	type plain RecordSet
+	if err := node.Decode((*plain)(rs)); err != nil {
+		return err
+	}
+	rs.pos = position{node.Line, node.Column}
+	return nil
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler, values are either scalars or
+// typed mappings.
+func (v *Value) UnmarshalYAML(node *yaml.Node) error {
+	v.pos = position{node.Line, node.Column}
+
+	if node.Kind == yaml.ScalarNode {
+		v.Text = node.Value
+		return nil
+	}
+
+	var tv typedValue
+	if err := node.Decode(&tv); err != nil {
+		return err
+	}
+	v.setTyped(tv)
+	return nil
+}
+
+// MarshalYAML implements yaml.Marshaler.
+func (v *Value) MarshalYAML() (any, error) {
+	if v.isTyped() {
+		return v.typed(), nil
+	}
+	return v.Text, nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (v *Value) MarshalJSON() ([]byte, error) {
+	if v.isTyped() {
+		return json.Marshal(v.typed())
+	}
+	return json.Marshal(v.Text)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (v *Value) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		var tv typedValue
+		if err := json.Unmarshal(data, &tv); err != nil {
+			return err
+		}
+		v.setTyped(tv)
+		return nil
+	}
+	return json.Unmarshal(data, &v.Text)
+}
+
+func (v *Value) isTyped() bool {
+	return v.Text == "" && (v.Priority != nil || v.Weight != nil || v.Port != nil || v.Host != "" ||
+		v.Target != "" || v.Flags != nil || v.Tag != "" || v.Value != "")
+}
+
+func (v *Value) typed() typedValue {
+	return typedValue{
+		Priority: v.Priority, Weight: v.Weight, Port: v.Port, Host: v.Host,
+		Target: v.Target, Flags: v.Flags, Tag: v.Tag, Value: v.Value,
+	}
+}
+
+func (v *Value) setTyped(tv typedValue) {
+	v.Priority, v.Weight, v.Port, v.Host = tv.Priority, tv.Weight, tv.Port, tv.Host
+	v.Target, v.Flags, v.Tag, v.Value = tv.Target, tv.Flags, tv.Tag, tv.Value
+}
+
+// values returns record set values (both `value` and `values`).
+func (rs *RecordSet) values() []*Value {
+	if rs.Value == nil {
+		return rs.Values
+	}
+	return append([]*Value{rs.Value}, rs.Values...)
+}
+
+// WriteYAML writes the spec as YAML.
+func (s *Spec) WriteYAML(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(s); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// WriteJSON writes the spec as indented JSON.
+func (s *Spec) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
+
+func (s *Spec) errorf(pos position, format string, args ...any) *Error {
+	return &Error{File: s.file, Line: pos.line, Column: pos.column, Message: fmt.Sprintf(format, args...)}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,154 @@
+package zonespec_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	api "github.com/luadns/luadns-go"
+	"github.com/luadns/luadns-go/zonespec"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadFileYAML(t *testing.T) {
+	spec, err := zonespec.LoadFile("testdata/example.yaml")
+	assert.NoError(t, err)
+
+	zones, err := spec.Build()
+	assert.NoError(t, err)
+	assert.Len(t, zones, 1)
+
+	zone := zones[0]
+	assert.Equal(t, "example.org", zone.Name)
+	assert.Equal(t, []string{"production"}, zone.Tags)
+	assert.Equal(t, []*api.Record{
+		{Name: "example.org.", Type: "A", Content: "1.1.1.1", TTL: 300},
+		{Name: "example.org.", Type: "A", Content: "2.2.2.2", TTL: 300},
+		{Name: "www.example.org.", Type: "CNAME", Content: "example.org.", TTL: 3600},
+		{Name: "example.org.", Type: "MX", Content: "10 mail.example.org.", TTL: 3600},
+		{Name: "example.org.", Type: "MX", Content: "20 mx.example.net.", TTL: 3600},
+		{Name: "_sip._udp.example.org.", Type: "SRV", Content: "0 5 5060 sip.example.org.", TTL: 3600},
+		{Name: "example.org.", Type: "CAA", Content: `0 issue "letsencrypt.org"`, TTL: 3600},
+		{Name: "example.org.", Type: "TXT", Content: "v=spf1 mx ~all", TTL: 3600},
+	}, zone.Records)
+}
+
+func TestLoadFileJSON(t *testing.T) {
+	spec, err := zonespec.LoadFile("testdata/example.json")
+	assert.NoError(t, err)
+
+	zones, err := spec.Build()
+	assert.NoError(t, err)
+	assert.Len(t, zones, 1)
+	assert.Equal(t, int64(12), zones[0].TemplateID)
+	assert.Equal(t, []*api.Record{
+		{Name: "example.net.", Type: "A", Content: "1.1.1.1", TTL: 300},
+		{Name: "example.net.", Type: "MX", Content: "10 mail.example.net.", TTL: 0},
+	}, zones[0].Records)
+}
+
+func TestLoadFileErrors(t *testing.T) {
+	_, err := zonespec.LoadFile("testdata/invalid.yaml")
+	assert.EqualError(t, err, strings.Join([]string{
+		`testdata/invalid.yaml:7:27: invalid IPv4 address "300.1.1.1"`,
+		`testdata/invalid.yaml:10:21: CNAME record "www" must have a single value`,
+		`testdata/invalid.yaml:11:9: apex NS records are managed by LuaDNS`,
+		`testdata/invalid.yaml:14:9: unsupported record type "BOGUS"`,
+		`testdata/invalid.yaml:17:5: duplicate zone "example.org"`,
+	}, "; "))
+}
+
+func TestLoadUnknownFields(t *testing.T) {
+	input := "version: 1\nzones:\n  - name: example.org\n    record: []\n"
+	_, err := zonespec.Load(strings.NewReader(input), "spec.yaml")
+	assert.EqualError(t, err, `spec.yaml:4:5: unknown field "record"`)
+
+	_, err = zonespec.Load(strings.NewReader("version: 2\n"), "spec.yaml")
+	assert.EqualError(t, err, "spec.yaml:1:1: unsupported version 2 (expected 1)")
+
+	_, err = zonespec.Load(strings.NewReader("version: one\n"), "spec.yaml")
+	assert.EqualError(t, err, "spec.yaml:1: cannot unmarshal !!str `one` into int")
+}
+
+func TestFromAccount(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/zones":
+			w.Write([]byte(`[{"id":5,"name":"example.org"}]`))
+		case "/zones/5/records":
+			w.Write([]byte(`[
+				{"name":"example.org.","type":"SOA","content":"ns1.luadns.net. hostmaster.luadns.net. 1 1200 120 604800 3600","ttl":3600},
+				{"name":"example.org.","type":"NS","content":"ns1.luadns.net.","ttl":86400},
+				{"name":"example.org.","type":"A","content":"1.1.1.1","ttl":3600},
+				{"name":"example.org.","type":"A","content":"2.2.2.2","ttl":3600},
+				{"name":"example.org.","type":"MX","content":"5 aspmx.l.google.com.","ttl":3600},
+				{"name":"www.example.org.","type":"CNAME","content":"example.org.","ttl":300}
+			]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	c := api.NewClient("joe@example.com", "password", api.SetBaseURL(server.URL))
+	spec, err := zonespec.FromAccount(context.Background(), c)
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	assert.NoError(t, spec.WriteYAML(&buf))
+	assert.Equal(t, strings.Join([]string{
+		"version: 1",
+		"zones:",
+		"  - name: example.org",
+		"    ttl: 3600",
+		"    records:",
+		"      - name: '@'",
+		"        type: A",
+		"        values:",
+		"          - 1.1.1.1",
+		"          - 2.2.2.2",
+		"      - name: '@'",
+		"        type: MX",
+		"        value:",
+		"          priority: 5",
+		"          host: aspmx.l.google.com.",
+		"      - name: www",
+		"        type: CNAME",
+		"        ttl: 300",
+		"        value: '@'",
+		"",
+	}, "\n"), buf.String())
+
+	// The emitted spec loads back to the same records.
+	loaded, err := zonespec.Load(&buf, "spec.yaml")
+	assert.NoError(t, err)
+	zones, err := loaded.Build()
+	assert.NoError(t, err)
+	assert.Len(t, zones[0].Records, 4)
+
+	buf.Reset()
+	assert.NoError(t, spec.WriteJSON(&buf))
+	assert.Contains(t, buf.String(), `"value": {`+"\n"+`            "priority": 5,`)
+}
+
+func TestFromZoneRoundTrip(t *testing.T) {
+	records := []*api.Record{
+		{Name: "example.org.", Type: "SLAVE", Content: "1.2.3.4", TTL: 3600},
+		{Name: "www.example.org.", Type: "REDIRECT", Content: "https://example.org/", TTL: 3600},
+	}
+	spec := &zonespec.Spec{Version: zonespec.Version, Zones: []*zonespec.ZoneSpec{
+		zonespec.FromZone(&api.Zone{Name: "example.org"}, records),
+	}}
+
+	var buf bytes.Buffer
+	assert.NoError(t, spec.WriteYAML(&buf))
+	loaded, err := zonespec.Load(&buf, "spec.yaml")
+	assert.NoError(t, err)
+	zones, err := loaded.Build()
+	assert.NoError(t, err)
+	assert.Equal(t, records, zones[0].Records)
+}
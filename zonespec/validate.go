@@ -0,0 +1,234 @@
+package zonespec
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	api "github.com/luadns/luadns-go"
+)
+
+// supportedTypes lists record types accepted in specs.
+var supportedTypes = []string{
+	api.TypeA, api.TypeAAAA, api.TypeALIAS, api.TypeCAA, api.TypeCNAME, api.TypeDS, api.TypeFORWARD,
+	api.TypeMX, api.TypeNS, api.TypePTR, api.TypeREDIRECT, api.TypeSLAVE, api.TypeSPF, api.TypeSRV,
+	api.TypeSSHFP, api.TypeTLSA, api.TypeTXT,
+}
+
+// Validate checks the spec and returns Errors listing every invalid entry.
+func (s *Spec) Validate() error {
+	errs := Errors{}
+
+	if s.Version != Version {
+		errs = append(errs, &Error{File: s.file, Line: 1, Column: 1, Message: fmt.Sprintf("unsupported version %d (expected %d)", s.Version, Version)})
+	}
+
+	seen := map[string]bool{}
+	for _, z := range s.Zones {
+		name := strings.ToLower(strings.TrimSuffix(z.Name, "."))
+		switch {
+		case name == "":
+			errs = append(errs, s.errorf(z.pos, "zone name is required"))
+			continue
+		case !validName(name):
+			errs = append(errs, s.errorf(z.pos, "invalid zone name %q", z.Name))
+			continue
+		case seen[name]:
+			errs = append(errs, s.errorf(z.pos, "duplicate zone %q", z.Name))
+		}
+		seen[name] = true
+
+		for _, rs := range z.Records {
+			if _, err := s.records(z, rs); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Build converts the spec to API zones, each zone holding its records.
+func (s *Spec) Build() ([]*api.Zone, error) {
+	zones := []*api.Zone{}
+	for _, z := range s.Zones {
+		zone, err := s.zone(z)
+		if err != nil {
+			return nil, err
+		}
+		zones = append(zones, zone)
+	}
+	return zones, nil
+}
+
+func (s *Spec) zone(z *ZoneSpec) (*api.Zone, error) {
+	zone := &api.Zone{
+		Name:       strings.TrimSuffix(z.Name, "."),
+		Tags:       z.Tags,
+		TemplateID: z.TemplateID,
+		Records:    []*api.Record{},
+	}
+
+	for _, rs := range z.Records {
+		records, err := s.records(z, rs)
+		if err != nil {
+			return nil, err
+		}
+		zone.Records = append(zone.Records, records...)
+	}
+
+	return zone, nil
+}
+
+// records converts a record set to API records.
+func (s *Spec) records(z *ZoneSpec, rs *RecordSet) ([]*api.Record, *Error) {
+	origin := api.Fqdn(strings.ToLower(z.Name))
+	typ := strings.ToUpper(rs.Type)
+
+	switch {
+	case rs.Name == "":
+		return nil, s.errorf(rs.pos, "record name is required")
+	case rs.Name != "@" && !validName(strings.TrimSuffix(rs.Name, ".")):
+		return nil, s.errorf(rs.pos, "invalid record name %q", rs.Name)
+	case typ == "":
+		return nil, s.errorf(rs.pos, "record type is required")
+	case typ == api.TypeSOA:
+		return nil, s.errorf(rs.pos, "SOA records are managed by LuaDNS")
+	case !contains(supportedTypes, typ):
+		return nil, s.errorf(rs.pos, "unsupported record type %q", rs.Type)
+	}
+
+	name := api.AbsoluteName(strings.ToLower(rs.Name), origin)
+	if name != origin && !strings.HasSuffix(name, "."+origin) {
+		return nil, s.errorf(rs.pos, "record name %q is outside of zone %s", rs.Name, z.Name)
+	}
+	if typ == api.TypeNS && name == origin {
+		return nil, s.errorf(rs.pos, "apex NS records are managed by LuaDNS")
+	}
+
+	values := rs.values()
+	switch {
+	case len(values) == 0:
+		return nil, s.errorf(rs.pos, "%s record %q has no values", typ, rs.Name)
+	case typ == api.TypeCNAME && len(values) > 1:
+		return nil, s.errorf(values[1].pos, "CNAME record %q must have a single value", rs.Name)
+	}
+
+	ttl := rs.TTL
+	if ttl == 0 {
+		ttl = z.TTL
+	}
+
+	records := []*api.Record{}
+	for _, v := range values {
+		content, err := valueContent(origin, typ, v)
+		if err != nil {
+			return nil, s.errorf(v.pos, "%s", err)
+		}
+		records = append(records, &api.Record{Name: name, Type: typ, Content: content, TTL: ttl})
+	}
+
+	return records, nil
+}
+
+// valueContent converts a value to the record content format used by the API.
+func valueContent(origin, typ string, v *Value) (string, error) {
+	if v.isTyped() {
+		switch typ {
+		case api.TypeMX:
+			if v.Priority == nil || v.Host == "" {
+				return "", fmt.Errorf("MX value requires priority and host")
+			}
+			return fmt.Sprintf("%d %s", *v.Priority, api.AbsoluteName(v.Host, origin)), nil
+		case api.TypeSRV:
+			if v.Port == nil || v.Target == "" {
+				return "", fmt.Errorf("SRV value requires port and target")
+			}
+			return fmt.Sprintf("%d %d %d %s", deref16(v.Priority), deref16(v.Weight), *v.Port, api.AbsoluteName(v.Target, origin)), nil
+		case api.TypeCAA:
+			if v.Tag == "" || v.Value == "" {
+				return "", fmt.Errorf("CAA value requires tag and value")
+			}
+			var flags uint8
+			if v.Flags != nil {
+				flags = *v.Flags
+			}
+			return fmt.Sprintf("%d %s %q", flags, v.Tag, v.Value), nil
+		default:
+			return "", fmt.Errorf("%s records require string values", typ)
+		}
+	}
+
+	text := v.Text
+	if text == "" {
+		return "", fmt.Errorf("empty %s value", typ)
+	}
+
+	switch typ {
+	case api.TypeA:
+		if ip := net.ParseIP(text); ip == nil || ip.To4() == nil {
+			return "", fmt.Errorf("invalid IPv4 address %q", text)
+		}
+	case api.TypeAAAA:
+		if ip := net.ParseIP(text); ip == nil || ip.To4() != nil {
+			return "", fmt.Errorf("invalid IPv6 address %q", text)
+		}
+	case api.TypeCNAME, api.TypeNS, api.TypePTR, api.TypeALIAS:
+		if text != "@" && !validName(strings.TrimSuffix(text, ".")) {
+			return "", fmt.Errorf("invalid %s target %q", typ, text)
+		}
+		return api.AbsoluteName(text, origin), nil
+	case api.TypeMX:
+		f := strings.Fields(text)
+		if len(f) != 2 || !isUint(f[0], 16) {
+			return "", fmt.Errorf("invalid MX value %q", text)
+		}
+		return f[0] + " " + api.AbsoluteName(f[1], origin), nil
+	case api.TypeSRV:
+		f := strings.Fields(text)
+		if len(f) != 4 || !isUint(f[0], 16) || !isUint(f[1], 16) || !isUint(f[2], 16) {
+			return "", fmt.Errorf("invalid SRV value %q", text)
+		}
+		return strings.Join(f[:3], " ") + " " + api.AbsoluteName(f[3], origin), nil
+	}
+
+	return text, nil
+}
+
+// validName checks a domain name (without trailing dot) syntax.
+func validName(name string) bool {
+	if name == "" || len(name) > 253 {
+		return false
+	}
+	for i, label := range strings.Split(name, ".") {
+		if label == "" || len(label) > 63 {
+			return false
+		}
+		if label == "*" && i == 0 {
+			continue
+		}
+		for _, c := range label {
+			ok := c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_'
+			if !ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func isUint(s string, bits int) bool {
+	_, err := strconv.ParseUint(s, 10, bits)
+	return err == nil
+}
+
+func deref16(n *uint16) uint16 {
+	if n == nil {
+		return 0
+	}
+	return *n
+}